@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/bimi"
+)
+
+func TestCheckBIMI(t *testing.T) {
+	checker := newStubChecker(startStubDNS(t))
+	checker.BIMI = &bimi.Checker{Client: startStubHTTPS(t, "bimi.secure.example.test")}
+
+	tests := []struct {
+		domain   string
+		hasBIMI  bool
+		ready    bool
+		problems []string
+		errors   []string
+	}{
+		{domain: "secure.example.test", hasBIMI: true, ready: true},
+		{
+			domain:  "dmarc-none.example.test",
+			hasBIMI: true,
+			problems: []string{
+				`version="", SVG Tiny PS requires version="1.2"`,
+				`baseProfile="", SVG Tiny PS requires baseProfile="tiny-ps"`,
+				"the <svg> element must not have the x attribute",
+				"the <svg> element must not have the y attribute",
+				"the viewBox is 200x100, the logo should be square",
+				"event handler attribute onload found, scripts are not allowed",
+				"<script> found, scripts are not allowed",
+				"<image> found, embedded or linked raster images are not allowed",
+				`external reference "https://cdn.example.net/logo.png" found, only references inside the document are allowed`,
+				"the <svg> element needs a <title> child with the name of the brand",
+			},
+			errors: []string{"BIMI requires DMARC p=quarantine or p=reject, the domain has p=none"},
+		},
+		{domain: "nospf.example.test"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.domain, func(t *testing.T) {
+			report := checker.check(context.Background(), test.domain)
+
+			if report.HasBIMI != test.hasBIMI {
+				t.Fatalf("HasBIMI = %t, want %t", report.HasBIMI, test.hasBIMI)
+			}
+
+			if !test.hasBIMI {
+				return
+			}
+
+			if report.BIMI.Ready() != test.ready || report.BIMI.Logo == nil {
+				t.Fatalf("BIMI = %+v, want ready: %t and a logo", report.BIMI, test.ready)
+			}
+
+			if !reflect.DeepEqual(report.BIMI.Logo.Problems, test.problems) {
+				t.Errorf("BIMI logo problems = %q, want %q", report.BIMI.Logo.Problems, test.problems)
+			}
+
+			if !reflect.DeepEqual(report.BIMI.Errors, test.errors) {
+				t.Errorf("BIMI errors = %q, want %q", report.BIMI.Errors, test.errors)
+			}
+		})
+	}
+}
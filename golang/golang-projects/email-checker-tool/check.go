@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/bimi"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dkim"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dmarc"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dnscache"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/mailbox"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/mtasts"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/reputation"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/score"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/smtpprobe"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/spf"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/tlsrpt"
+)
+
+// Checker runs the DNS checks of a domain. It is safe for concurrent use
+// by multiple goroutines.
+type Checker struct {
+	// Resolver is used for every lookup. A nil Resolver means the system resolver.
+	Resolver resolver.Resolver
+	// Timeout is the maximum duration of a single lookup. Zero means no timeout.
+	Timeout time.Duration
+	// Limiter caps the number of lookups per second. A nil Limiter means no limit.
+	Limiter *RateLimiter
+	// SPFCheckIP is the sending IP address the SPF record is evaluated for. Nil skips the evaluation.
+	SPFCheckIP net.IP
+	// SMTP probes the MX hosts of the domain. Nil skips the probe.
+	SMTP *smtpprobe.Prober
+	// Mailbox verifies the addresses given instead of domains. Nil only checks their domain.
+	Mailbox *mailbox.Verifier
+	// DKIMSelectors are the selectors probed by the DKIM check. Nil skips the check.
+	DKIMSelectors []string
+	// SPFSender is the MAIL FROM address used by the SPF evaluation, postmaster@<domain> when empty.
+	SPFSender string
+	// Cache answers the lookups it already knows. Nil sends every lookup to the resolver.
+	Cache *dnscache.Cache
+	// MTASTS fetches and validates the MTA-STS policy of the domain. Nil skips the check.
+	MTASTS *mtasts.Checker
+	// BIMI validates the BIMI record and logo of the domain. Nil skips the check.
+	BIMI *bimi.Checker
+	// DNSSEC asks the resolver whether the answers are authenticated. The Resolver
+	// must then be a *resolver.Client talking to a validating resolver.
+	DNSSEC bool
+	// Reputation checks the reverse DNS and the blocklist entries of the MX hosts. Nil skips the check.
+	Reputation *reputation.Checker
+	// Scoring grades the findings of every domain. Nil skips the score.
+	Scoring *score.Policy
+	// Retries is the number of times a lookup that timed out or failed temporarily is sent again.
+	Retries int
+	// RetryBackoff is the wait before the first retry, it doubles with every retry.
+	RetryBackoff time.Duration
+}
+
+// resolver returns the Resolver used for the lookups. Every lookup that is not
+// answered by the cache waits for the rate limiter and is bounded by the lookup timeout.
+func (c *Checker) resolver() resolver.Resolver {
+	next := c.Resolver
+	if next == nil {
+		next = resolver.System{}
+	}
+
+	limited := &limitedResolver{checker: c, next: next}
+	if c.Cache == nil {
+		return limited
+	}
+
+	// Lets the cache see the records, and so their TTL, when the resolver returns them
+	if querier, ok := next.(dnscache.Querier); ok {
+		return c.Cache.Wrap(&limitedQuerier{limitedResolver: limited, querier: querier})
+	}
+
+	return c.Cache.Wrap(limited)
+}
+
+// lookupContext waits for the rate limiter and returns the context a single lookup should use
+func (c *Checker) lookupContext(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if err := c.Limiter.Wait(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	if c.Timeout <= 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	return ctx, cancel, nil
+}
+
+// retry runs the lookup until it gets an answer or a failure that is not temporary, at most
+// Retries more times. Every attempt waits for the rate limiter and has its own timeout, and
+// the wait between two attempts doubles from RetryBackoff.
+func (c *Checker) retry(ctx context.Context, lookup func(ctx context.Context) error) error {
+	backoff := c.RetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		lookupCtx, cancel, err := c.lookupContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = lookup(lookupCtx)
+		cancel()
+
+		if err == nil || attempt >= c.Retries || !resolver.Retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		// The jitter keeps the workers that failed together from retrying together
+		wait := backoff
+		if backoff > 0 {
+			wait += time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+
+		backoff *= 2
+	}
+}
+
+// limitedResolver applies the rate limit and timeout of the checker to every lookup
+type limitedResolver struct {
+	checker *Checker
+	next    resolver.Resolver
+}
+
+func (l *limitedResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	var records []*net.MX
+
+	err := l.checker.retry(ctx, func(ctx context.Context) (err error) {
+		records, err = l.next.LookupMX(ctx, name)
+		return err
+	})
+
+	return records, err
+}
+
+func (l *limitedResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	var records []string
+
+	err := l.checker.retry(ctx, func(ctx context.Context) (err error) {
+		records, err = l.next.LookupTXT(ctx, name)
+		return err
+	})
+
+	return records, err
+}
+
+func (l *limitedResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	var records []net.IP
+
+	err := l.checker.retry(ctx, func(ctx context.Context) (err error) {
+		records, err = l.next.LookupIP(ctx, network, host)
+		return err
+	})
+
+	return records, err
+}
+
+func (l *limitedResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	var records []string
+
+	err := l.checker.retry(ctx, func(ctx context.Context) (err error) {
+		records, err = l.next.LookupAddr(ctx, addr)
+		return err
+	})
+
+	return records, err
+}
+
+// check checks the input, which is either a domain or an email address
+func (c *Checker) check(ctx context.Context, input string) DomainReport {
+	if strings.Contains(input, "@") {
+		return c.checkAddress(ctx, input)
+	}
+
+	return c.checkDomain(ctx, input)
+}
+
+// limitedQuerier is a limitedResolver for resolvers that also return the records themselves
+type limitedQuerier struct {
+	*limitedResolver
+	querier dnscache.Querier
+}
+
+func (l *limitedQuerier) Lookup(ctx context.Context, name string, qtype uint16) ([]dns.RR, error) {
+	var records []dns.RR
+
+	err := l.checker.retry(ctx, func(ctx context.Context) (err error) {
+		records, err = l.querier.Lookup(ctx, name, qtype)
+		return err
+	})
+
+	return records, err
+}
+
+// checkAddress validates the syntax of the address, checks its domain and then
+// asks the MX hosts whether the mailbox exists.
+func (c *Checker) checkAddress(ctx context.Context, input string) DomainReport {
+	address, err := mailbox.ParseAddress(input)
+	if err != nil {
+		return DomainReport{Address: input, Mailbox: mailbox.Invalid(input, err)}
+	}
+
+	report := c.checkDomain(ctx, address.Domain)
+	report.Address = address.String()
+
+	if c.Mailbox == nil {
+		return report
+	}
+
+	dns := c.resolver()
+
+	mxRecords, err := dns.LookupMX(ctx, address.Domain)
+
+	switch class := resolver.Classify(err); {
+	case class == resolver.ClassNXDomain:
+		report.Mailbox = &mailbox.Result{Address: report.Address, Status: mailbox.StatusUndeliverable, Reason: "the domain does not exist"}
+		return report
+	case resolver.Failed(class):
+		report.Mailbox = &mailbox.Result{Address: report.Address, Status: mailbox.StatusUnknown, Reason: "could not look up the MX records: " + err.Error()}
+		return report
+	}
+
+	// The MX hosts are resolved with the same resolver as the domain
+	verifier := *c.Mailbox
+	if verifier.Prober.Resolver == nil {
+		prober := *verifier.Prober
+		prober.Resolver = dns
+		verifier.Prober = &prober
+	}
+
+	report.Mailbox = verifier.Verify(ctx, address, mxRecords)
+	return report
+}
+
+// checkDomain looks up the MX, SPF, DMARC, MTA-STS, TLS-RPT and BIMI records of the domain and returns
+// the result as a DomainReport. A failed lookup is recorded in the report's Errors.
+func (c *Checker) checkDomain(ctx context.Context, domain string) DomainReport {
+	report := DomainReport{Domain: domain}
+	dns := c.resolver()
+
+	// Look up for the domain MX record
+	mxRecords, err := dns.LookupMX(ctx, domain)
+	report.addLookup(LookupMX, domain, err)
+
+	for _, mx := range mxRecords {
+		report.MXRecords = append(report.MXRecords, mx.Host)
+	}
+
+	if len(mxRecords) > 0 {
+		report.HasMX = true
+	}
+
+	if c.SMTP != nil && report.HasMX {
+		// The MX hosts are resolved with the same resolver as the domain
+		prober := *c.SMTP
+		if prober.Resolver == nil {
+			prober.Resolver = dns
+		}
+
+		report.SMTP = prober.ProbeAll(ctx, mxRecords)
+	}
+
+	if c.Reputation != nil && report.HasMX {
+		reputationChecker := *c.Reputation
+		reputationChecker.Resolver = dns
+
+		report.Reputation = reputationChecker.Check(ctx, report.MXRecords)
+	}
+
+	txtRecords, err := dns.LookupTXT(ctx, domain)
+	report.addLookup(LookupSPF, domain, err)
+
+	// record is a single item of txtRecords
+	for _, record := range txtRecords {
+		// Looking for spf1 record
+		if spf.IsSPF(record) {
+			report.HasSPF = true
+			report.SPFRecord = record
+			break
+		}
+	}
+
+	spfChecker := &spf.Checker{Resolver: dns}
+
+	if report.HasSPF {
+		report.SPF = spfChecker.Analyze(ctx, domain)
+	}
+
+	// Evaluates the record for the sending IP address when one is given
+	if c.SPFCheckIP != nil {
+		sender := c.SPFSender
+		if sender == "" {
+			sender = "postmaster@" + domain
+		}
+
+		verdict := spfChecker.CheckHost(ctx, c.SPFCheckIP, domain, sender, domain)
+		report.SPFResult = &verdict
+	}
+
+	dmarcDomain := "_dmarc." + domain
+	dmarcRecords, err := dns.LookupTXT(ctx, dmarcDomain)
+	report.addLookup(LookupDMARC, dmarcDomain, err)
+
+	for _, record := range dmarcRecords {
+		// Looking for dmarc record
+		if dmarc.IsDMARC(record) {
+			report.HasDMARC = true
+			report.DMARCRecord = record
+			break
+		}
+	}
+
+	linter := &dmarc.Linter{Resolver: dns}
+	report.DMARC = linter.Lint(ctx, domain, dmarcRecords)
+
+	if c.MTASTS != nil {
+		stsChecker := *c.MTASTS
+		stsChecker.Resolver = dns
+
+		report.MTASTS = stsChecker.Check(ctx, domain, report.MXRecords)
+		report.HasMTASTS = report.MTASTS != nil && report.MTASTS.Record != nil
+	}
+
+	report.TLSRPT = tlsrpt.Check(ctx, dns, domain)
+	report.HasTLSRPT = report.TLSRPT != nil && report.TLSRPT.Record != nil
+
+	if c.BIMI != nil {
+		bimiChecker := *c.BIMI
+		bimiChecker.Resolver = dns
+
+		var dmarcRecord *dmarc.Record
+		if report.DMARC != nil {
+			dmarcRecord = report.DMARC.Record
+		}
+
+		report.BIMI = bimiChecker.Check(ctx, domain, dmarcRecord)
+		report.HasBIMI = report.BIMI != nil && report.BIMI.Record != nil
+	}
+
+	if c.DNSSEC {
+		report.DNSSEC = c.checkDNSSEC(ctx, domain)
+	}
+
+	if len(c.DKIMSelectors) > 0 {
+		dkimChecker := &dkim.Checker{Resolver: dns, Selectors: c.DKIMSelectors}
+		report.DKIM = dkimChecker.Check(ctx, domain)
+		report.HasDKIM = len(report.DKIM.Selectors) > 0
+	}
+
+	if c.Scoring != nil {
+		report.Score = c.Scoring.Score(c.scoreChecks(report))
+	}
+
+	return report
+}
+
+// checkDNSSEC tells whether the answers of the MX, SPF and DMARC lookups are authenticated
+func (c *Checker) checkDNSSEC(ctx context.Context, domain string) []RecordSecurity {
+	client, ok := c.Resolver.(*resolver.Client)
+	if !ok {
+		return nil
+	}
+
+	queries := []RecordSecurity{
+		{Lookup: LookupMX, Query: domain, Type: "MX"},
+		{Lookup: LookupSPF, Query: domain, Type: "TXT"},
+		{Lookup: LookupDMARC, Query: "_dmarc." + domain, Type: "TXT"},
+	}
+
+	for i := range queries {
+		query := &queries[i]
+
+		lookupCtx, cancel, err := c.lookupContext(ctx)
+		if err != nil {
+			query.Status = resolver.SecurityIndeterminate
+			query.Error = err.Error()
+			continue
+		}
+
+		query.Status, err = client.Security(lookupCtx, query.Query, dns.StringToType[query.Type])
+		cancel()
+
+		if err != nil {
+			query.Error = err.Error()
+		}
+	}
+
+	return queries
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dnsstub"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+func TestCheckDomain(t *testing.T) {
+	checker := newStubChecker(startStubDNS(t))
+
+	tests := []struct {
+		domain      string
+		mxRecords   []string
+		spfRecord   string
+		dmarcRecord string
+		lookups     map[string]string
+		// errors are the lookups that end up in the errors, a missing _dmarc record is not one
+		errors []string
+	}{
+		{
+			domain:      "secure.example.test",
+			mxRecords:   []string{"mx1.secure.example.test.", "mx2.secure.example.test."},
+			spfRecord:   "v=spf1 ip4:192.0.2.0/24 include:_spf.secure.example.test -all",
+			dmarcRecord: "v=DMARC1; p=reject; pct=100; rua=mailto:dmarc@secure.example.test",
+			lookups:     map[string]string{LookupMX: resolver.ClassOK, LookupSPF: resolver.ClassOK, LookupDMARC: resolver.ClassOK},
+		},
+		{
+			domain:    "nospf.example.test",
+			mxRecords: []string{"mail.nospf.example.test."},
+			lookups:   map[string]string{LookupMX: resolver.ClassOK, LookupSPF: resolver.ClassOK, LookupDMARC: resolver.ClassNXDomain},
+		},
+		{
+			// The strings of the TXT record are joined, and the DMARC record is reached through a CNAME
+			domain:      "long.example.test",
+			spfRecord:   "v=spf1 ip4:192.0.2.1 ip4:192.0.2.2 ip4:192.0.2.3 ip4:192.0.2.4 ip4:192.0.2.5 ip4:192.0.2.6 ip4:192.0.2.7 ip4:192.0.2.8 ip4:192.0.2.9 ip4:192.0.2.10 ip4:192.0.2.11 ip4:192.0.2.12 ip4:192.0.2.13 ip4:192.0.2.14 ip4:192.0.2.15 ip4:192.0.2.16 ip4:192.0.2.17 -all",
+			dmarcRecord: "v=DMARC1; p=reject; pct=100; rua=mailto:dmarc@secure.example.test",
+			lookups:     map[string]string{LookupMX: resolver.ClassNotFound, LookupSPF: resolver.ClassOK, LookupDMARC: resolver.ClassOK},
+		},
+		{
+			domain:  "missing.example.test",
+			lookups: map[string]string{LookupMX: resolver.ClassNXDomain, LookupSPF: resolver.ClassNXDomain, LookupDMARC: resolver.ClassNXDomain},
+			errors:  []string{LookupMX, LookupSPF},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.domain, func(t *testing.T) {
+			report := checker.check(context.Background(), test.domain)
+
+			if !reflect.DeepEqual(report.MXRecords, test.mxRecords) {
+				t.Errorf("MXRecords = %q, want %q", report.MXRecords, test.mxRecords)
+			}
+
+			if report.HasMX != (len(test.mxRecords) > 0) {
+				t.Errorf("HasMX = %v, want %v", report.HasMX, len(test.mxRecords) > 0)
+			}
+
+			if report.SPFRecord != test.spfRecord || report.HasSPF != (test.spfRecord != "") {
+				t.Errorf("SPFRecord = %q (HasSPF %v), want %q", report.SPFRecord, report.HasSPF, test.spfRecord)
+			}
+
+			if report.DMARCRecord != test.dmarcRecord || report.HasDMARC != (test.dmarcRecord != "") {
+				t.Errorf("DMARCRecord = %q (HasDMARC %v), want %q", report.DMARCRecord, report.HasDMARC, test.dmarcRecord)
+			}
+
+			if !reflect.DeepEqual(report.Lookups, test.lookups) {
+				t.Errorf("Lookups = %v, want %v", report.Lookups, test.lookups)
+			}
+
+			var errors []string
+			for _, err := range report.Errors {
+				errors = append(errors, err.Lookup)
+			}
+
+			if !reflect.DeepEqual(errors, test.errors) {
+				t.Errorf("Errors = %v, want the lookups %v", report.Errors, test.errors)
+			}
+		})
+	}
+}
+
+func TestCheckDomainOverTCP(t *testing.T) {
+	server := startStubDNS(t)
+	checker := newStubChecker(server)
+	checker.Resolver = resolver.NewNameserver(server.Addr(), "tcp", checker.Timeout)
+
+	report := checker.check(context.Background(), "secure.example.test")
+	if !report.HasMX || !report.HasSPF || !report.HasDMARC {
+		t.Errorf("check() over TCP = HasMX %v, HasSPF %v, HasDMARC %v, want every record", report.HasMX, report.HasSPF, report.HasDMARC)
+	}
+}
+
+func TestCheckDNSSEC(t *testing.T) {
+	tests := []struct {
+		name          string
+		authenticated bool
+		status        map[string]string
+	}{
+		{
+			name:   "unsigned",
+			status: map[string]string{LookupMX: resolver.SecurityInsecure, LookupSPF: resolver.SecurityInsecure, LookupDMARC: resolver.SecurityBogus},
+		},
+		{
+			name:          "validated",
+			authenticated: true,
+			status:        map[string]string{LookupMX: resolver.SecuritySecure, LookupSPF: resolver.SecuritySecure, LookupDMARC: resolver.SecurityBogus},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := startStubDNS(t, func(zone *dnsstub.Zone) {
+				zone.SetAuthenticated(test.authenticated)
+				zone.AddBogus("_dmarc.secure.example.test")
+			})
+
+			checker := newStubChecker(server)
+			checker.DNSSEC = true
+
+			report := checker.check(context.Background(), "secure.example.test")
+
+			status := make(map[string]string)
+			for _, record := range report.DNSSEC {
+				status[record.Lookup] = record.Status
+			}
+
+			if !reflect.DeepEqual(status, test.status) {
+				t.Errorf("DNSSEC = %v, want %v", status, test.status)
+			}
+		})
+	}
+}
@@ -1,3 +1,16 @@
 module github.com/rmarasigan/freecodecamp/email-checker-tool
 
 go 1.17
+
+require (
+	github.com/miekg/dns v1.1.50
+	golang.org/x/net v0.0.0-20210726213435-c6fcb2dbf985
+)
+
+require (
+	golang.org/x/mod v0.4.2 // indirect
+	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
+	golang.org/x/text v0.3.6 // indirect
+	golang.org/x/tools v0.1.6-0.20210726203631-07bc1bf47fb2 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+)
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/mailbox"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/smtptest"
+)
+
+func TestCheckMailbox(t *testing.T) {
+	checker := newStubChecker(startStubDNS(t))
+	checker.Mailbox = &mailbox.Verifier{Prober: startStubSMTP(t, smtptest.Config{Mailboxes: []string{"alice@secure.example.test"}})}
+
+	tests := []struct {
+		input  string
+		domain string
+		status string
+		reason string
+	}{
+		{input: "alice@secure.example.test", domain: "secure.example.test", status: mailbox.StatusDeliverable},
+		{input: "alice@SECURE.example.test", domain: "secure.example.test", status: mailbox.StatusDeliverable},
+		{input: "bob@secure.example.test", domain: "secure.example.test", status: mailbox.StatusUndeliverable, reason: "the MX host rejected the recipient: 550 5.1.1 No such user here"},
+		{input: "alice@missing.example.test", domain: "missing.example.test", status: mailbox.StatusUndeliverable, reason: "the domain does not exist"},
+		{input: "al ice@secure.example.test", status: mailbox.StatusUndeliverable, reason: `invalid syntax: the local part "al ice" contains ' ', which must be quoted`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			report := checker.check(context.Background(), test.input)
+
+			if report.Domain != test.domain || report.Mailbox == nil || report.Mailbox.Status != test.status || report.Mailbox.Reason != test.reason {
+				t.Errorf("check(%s) = %s, %+v, want %s, %s (%s)", test.input, report.Domain, report.Mailbox, test.domain, test.status, test.reason)
+			}
+		})
+	}
+}
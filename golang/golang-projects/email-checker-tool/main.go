@@ -1,72 +1,456 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/bimi"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dkim"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dnscache"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/input"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/mailbox"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/mtasts"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/reputation"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/score"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/smtpprobe"
 )
 
 func main() {
-	scanner := bufio.NewScanner(os.Stdin)
-	fmt.Printf("domain, hasMX, hasSPF, spfRecord, hasDMARC, dmarcRecord\n")
+	// The first argument can select a subcommand, the domains are checked otherwise
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "watch":
+			runWatch(os.Args[2:])
+			return
+		case "reports":
+			runReports(os.Args[2:])
+			return
+		}
+	}
+
+	os.Exit(runCheck(os.Args[1:]))
+}
+
+// Exit codes of the checks, the higher one wins when several apply. 1 is used for
+// the errors that stop the checks and 2 by the flag package.
+const (
+	// ExitNXDomain tells that at least one domain does not exist.
+	ExitNXDomain = 3
+	// ExitLookupFailed tells that at least one lookup failed, so the reports are incomplete.
+	ExitLookupFailed = 4
+)
 
-	for scanner.Scan() {
-		// Gets the input of the user
-		checkDomain(scanner.Text())
+// exitCode returns the exit code the report calls for. Only the MX lookup of the domain
+// tells that it does not exist, the other lookups are of names that may well be missing.
+func exitCode(report DomainReport) int {
+	for _, class := range report.Lookups {
+		if resolver.Failed(class) {
+			return ExitLookupFailed
+		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Printf("Error could not read from input: %v\n", err)
+	if report.Lookups[LookupMX] == resolver.ClassNXDomain {
+		return ExitNXDomain
 	}
+
+	return 0
 }
 
-func checkDomain(domain string) {
-	var hasMX, hasSPF, hasDMARC bool
-	var spfRecord, dmarcRecord string
+// runCheck checks every domain or email address read from the standard input and returns the exit code.
+// It returns instead of exiting on an error, so that the DNS cache is still saved.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("email-checker-tool", flag.ExitOnError)
+	format := flags.String("format", FormatCSV, "output format: csv, json, ndjson or table")
+	concurrency := flags.Int("concurrency", 10, "number of domains checked at the same time")
+	showProgress := flags.Bool("progress", true, "report the progress to stderr")
+	progressInterval := flags.Duration("progress-interval", 5*time.Second, "how often the progress is reported")
+	showSummary := flags.Bool("summary", false, "write a summary of the scores of every domain to stderr once done")
+	csvColumn := flags.String("csv-column", "", "read the input as CSV and check the domains of this column, a header name or a position starting at 1")
+	csvHeader := flags.Bool("csv-header", false, "skip the first row of the CSV input, implied when --csv-column is a name")
+	options := addCheckerFlags(flags)
+	flags.Parse(args)
 
-	// Look up for the domain MX record
-	mxRecord, err := net.LookupMX(domain)
+	output, err := NewReportWriter(*format, os.Stdout)
 	if err != nil {
 		log.Printf("Error %v\n", err)
+		return 1
+	}
+
+	checker := options.newChecker()
+	defer options.closeChecker(checker)
+
+	if *showSummary && checker.Scoring == nil {
+		log.Printf("Error --summary needs the scores, remove --score=false\n")
+		return 1
+	}
+
+	var progress *Progress
+	if *showProgress {
+		progress = NewProgress(os.Stderr, *progressInterval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reader := &input.Reader{
+		// Without mailbox verification, an address is only worth the checks of its domain
+		KeepAddresses: checker.Mailbox != nil,
+		Column:        *csvColumn,
+		Header:        *csvHeader,
+		OnInvalid:     logInvalidInput,
+	}
+
+	// The files are read in order, the standard input when there is none
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
 	}
 
-	if len(mxRecord) > 0 {
-		hasMX = true
+	domains := make(chan string)
+
+	go func() {
+		defer close(domains)
+
+		for _, path := range files {
+			if err := readInput(ctx, reader, path, domains); err != nil {
+				log.Printf("Error could not read the input: %v\n", err)
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+
+	var summary score.Summary
+	status := 0
+
+	write := func(report DomainReport) error {
+		if code := exitCode(report); code > status {
+			status = code
+		}
+
+		summary.Add(report.Domain, report.Score)
+		return output.WriteReport(report)
+	}
+
+	err = checkAll(ctx, checker, domains, *concurrency, progress, write)
+	progress.Stop()
+
+	if progress != nil && checker.Cache != nil {
+		hits, misses := checker.Cache.Stats()
+		fmt.Fprintf(os.Stderr, "dns cache: %d hits, %d misses\n", hits, misses)
 	}
 
-	txtRecords, err := net.LookupTXT(domain)
 	if err != nil {
-		log.Printf("Error %v\n", err)
+		log.Printf("Error could not write report: %v\n", err)
+		return 1
+	}
+
+	if err := output.Close(); err != nil {
+		log.Printf("Error could not write report: %v\n", err)
+		return 1
+	}
+
+	if *showSummary {
+		if err := summary.WriteText(os.Stderr); err != nil {
+			log.Printf("Error could not write the summary: %v\n", err)
+			return 1
+		}
+	}
+
+	return status
+}
+
+// readInput sends the normalized entries of the file, or of the standard input for "-", to domains
+func readInput(ctx context.Context, reader *input.Reader, path string, domains chan<- string) error {
+	src, source := io.Reader(os.Stdin), "stdin"
+
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		src, source = file, path
+	}
+
+	return reader.Read(src, source, func(entry string) error {
+		select {
+		case domains <- entry:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// logInvalidInput reports an entry of the input that is skipped
+func logInvalidInput(source string, line int, entry string, err error) {
+	log.Printf("Error skipping %s line %d: %v\n", source, line, err)
+}
+
+// checkerFlags are the flags that configure the checks, shared by every mode
+type checkerFlags struct {
+	qps           *int
+	timeout       *time.Duration
+	retries       *int
+	retryBackoff  *time.Duration
+	resolverSpec  *string
+	checkIP       *string
+	sender        *string
+	checkDKIM     *bool
+	dkimSelectors *string
+	probeSMTP     *bool
+	smtpPort      *string
+	smtpTimeout   *time.Duration
+	smtpCA        *string
+	heloName      *string
+	verifyMailbox *bool
+	mailFrom      *string
+	noCache       *bool
+	cacheFile     *string
+	cacheMinTTL   *time.Duration
+	cacheMaxTTL   *time.Duration
+	checkMTASTS   *bool
+	checkBIMI     *bool
+	dnssec        *bool
+	reputation    *bool
+	blocklists    *string
+	scoring       *bool
+	scorePolicy   *string
+	httpsTimeout  *time.Duration
+	httpsCA       *string
+	httpsConnect  *string
+}
+
+// addCheckerFlags defines the flags of the checks on the flag set
+func addCheckerFlags(flags *flag.FlagSet) *checkerFlags {
+	return &checkerFlags{
+		qps:           flags.Int("qps", 0, "maximum number of DNS queries per second, 0 means unlimited"),
+		timeout:       flags.Duration("timeout", 5*time.Second, "timeout of a single DNS lookup"),
+		retries:       flags.Int("retries", 2, "number of times a lookup that timed out or failed temporarily is sent again"),
+		retryBackoff:  flags.Duration("retry-backoff", 200*time.Millisecond, "wait before the first retry, doubled for every other retry"),
+		resolverSpec:  flags.String("resolver", "system", "DNS resolver: system, host[:port], udp://, tcp://, tls:// or https:// URL"),
+		checkIP:       flags.String("ip", "", "evaluate the SPF record for this sending IP address"),
+		sender:        flags.String("sender", "", "MAIL FROM address used with --ip, postmaster@<domain> by default"),
+		checkDKIM:     flags.Bool("dkim", true, "probe the DKIM selectors of every domain"),
+		dkimSelectors: flags.String("dkim-selectors", "", "comma separated DKIM selectors probed in addition to the common ones"),
+		probeSMTP:     flags.Bool("smtp", false, "connect to every MX host and test EHLO and STARTTLS"),
+		smtpPort:      flags.String("smtp-port", smtpprobe.DefaultPort, "port the MX hosts are probed on"),
+		smtpTimeout:   flags.Duration("smtp-timeout", 15*time.Second, "timeout of the whole SMTP dialogue with a single MX host"),
+		smtpCA:        flags.String("smtp-ca", "", "PEM file of the certificate authorities trusted instead of the system ones"),
+		heloName:      flags.String("helo", "localhost", "name sent with EHLO when probing the MX hosts"),
+		verifyMailbox: flags.Bool("verify", false, "ask the MX hosts whether the mailbox of the email addresses exists, which connects to their port 25"),
+		mailFrom:      flags.String("mail-from", "", "MAIL FROM address used to verify the mailboxes, the null sender by default"),
+		noCache:       flags.Bool("no-cache", false, "send every lookup to the resolver, without reading or writing the DNS cache"),
+		cacheFile:     flags.String("cache-file", defaultCacheFile(), "file the DNS cache is kept in between runs, empty keeps it in memory only"),
+		cacheMinTTL:   flags.Duration("cache-min-ttl", dnscache.DefaultMinTTL, "shortest time an answer is cached, whatever its TTL"),
+		cacheMaxTTL:   flags.Duration("cache-max-ttl", dnscache.DefaultMaxTTL, "longest time an answer is cached, whatever its TTL"),
+		checkMTASTS:   flags.Bool("mta-sts", true, "fetch and validate the MTA-STS policy of every domain"),
+		checkBIMI:     flags.Bool("bimi", true, "validate the BIMI record and logo of every domain"),
+		dnssec:        flags.Bool("dnssec", false, "tell whether the MX, SPF and DMARC answers are DNSSEC-secure, needs a validating nameserver as --resolver"),
+		reputation:    flags.Bool("reputation", false, "resolve the MX hosts, check their reverse DNS and query their addresses against DNS blocklists"),
+		blocklists:    flags.String("blocklists", strings.Join(reputation.DefaultBlocklists, ","), "comma separated DNS blocklists queried with --reputation, empty only checks the reverse DNS"),
+		scoring:       flags.Bool("score", true, "grade every domain from A to F and tell what to fix"),
+		scorePolicy:   flags.String("score-policy", "", "JSON file of the weights and grade thresholds of the score, the defaults when empty"),
+		httpsTimeout:  flags.Duration("https-timeout", 10*time.Second, "timeout of a single HTTPS request, e.g. to fetch an MTA-STS policy or a BIMI logo"),
+		httpsCA:       flags.String("https-ca", "", "PEM file of the certificate authorities trusted for HTTPS instead of the system ones"),
+		httpsConnect:  flags.String("https-connect", "", "host:port every HTTPS request is sent to instead, e.g. a local test server"),
 	}
+}
 
-	// record is a single item of txtRecords
-	for _, record := range txtRecords {
-		// Looking for spf1 record
-		if strings.HasPrefix(record, "v=spf1") {
-			hasSPF = true
-			spfRecord = record
-			break
+// newChecker builds the Checker configured by the flags. Its Limiter must be stopped once done.
+func (f *checkerFlags) newChecker() *Checker {
+	var spfCheckIP net.IP
+	if *f.checkIP != "" {
+		if spfCheckIP = net.ParseIP(*f.checkIP); spfCheckIP == nil {
+			log.Fatalf("Error invalid IP address %q\n", *f.checkIP)
 		}
 	}
 
-	dmarcRecords, err := net.LookupTXT("_dmarc." + domain)
+	if *f.qps > MaxRate {
+		log.Fatalf("Error --qps must be at most %d\n", MaxRate)
+	}
+
+	dnsResolver, err := resolver.New(*f.resolverSpec, *f.timeout)
 	if err != nil {
-		log.Printf("Error %v\n", err)
+		log.Fatalf("Error %v\n", err)
+	}
+
+	// The system resolver does not tell whether the answers were authenticated
+	if _, ok := dnsResolver.(*resolver.Client); *f.dnssec && !ok {
+		log.Fatalf("Error --dnssec needs a validating nameserver as --resolver, e.g. --resolver 1.1.1.1\n")
+	}
+
+	checker := &Checker{
+		DNSSEC:       *f.dnssec,
+		Resolver:     dnsResolver,
+		Timeout:      *f.timeout,
+		Retries:      *f.retries,
+		RetryBackoff: *f.retryBackoff,
+		Limiter:      NewRateLimiter(*f.qps),
+		SPFCheckIP:   spfCheckIP,
+		SPFSender:    *f.sender,
 	}
 
-	for _, record := range dmarcRecords {
-		// Looking for dmarc record
-		if strings.HasPrefix(record, "v=DMARC1") {
-			hasDMARC = true
-			dmarcRecord = record
-			break
+	prober := &smtpprobe.Prober{
+		HELOName: *f.heloName,
+		Port:     *f.smtpPort,
+		Timeout:  *f.smtpTimeout,
+	}
+
+	if *f.smtpCA != "" {
+		if prober.RootCAs, err = loadCertPool(*f.smtpCA); err != nil {
+			log.Fatalf("Error %v\n", err)
+		}
+	}
+
+	if *f.probeSMTP {
+		checker.SMTP = prober
+	}
+
+	if *f.verifyMailbox {
+		checker.Mailbox = &mailbox.Verifier{Prober: prober, From: *f.mailFrom}
+	}
+
+	if *f.checkDKIM {
+		checker.DKIMSelectors = append(splitList(*f.dkimSelectors), dkim.DefaultSelectors...)
+	}
+
+	client, err := f.newHTTPClient()
+	if err != nil {
+		log.Fatalf("Error %v\n", err)
+	}
+
+	if *f.checkMTASTS {
+		checker.MTASTS = &mtasts.Checker{Client: client}
+	}
+
+	if *f.checkBIMI {
+		checker.BIMI = &bimi.Checker{Client: client}
+	}
+
+	if *f.reputation {
+		checker.Reputation = &reputation.Checker{Blocklists: splitList(*f.blocklists)}
+	}
+
+	if *f.scoring {
+		checker.Scoring = score.DefaultPolicy()
+
+		if *f.scorePolicy != "" {
+			if checker.Scoring, err = score.LoadPolicy(*f.scorePolicy); err != nil {
+				log.Fatalf("Error %v\n", err)
+			}
+		}
+	}
+
+	if !*f.noCache {
+		checker.Cache = dnscache.New()
+		checker.Cache.MinTTL = *f.cacheMinTTL
+		checker.Cache.MaxTTL = *f.cacheMaxTTL
+		checker.Cache.Resolver = *f.resolverSpec
+
+		if *f.cacheFile != "" {
+			if err := checker.Cache.Load(*f.cacheFile); err != nil {
+				log.Printf("Error could not load the DNS cache, starting with an empty one: %v\n", err)
+			}
+		}
+	}
+
+	return checker
+}
+
+// newHTTPClient returns the client of the HTTPS requests
+func (f *checkerFlags) newHTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if *f.httpsCA != "" {
+		pool, err := loadCertPool(*f.httpsCA)
+		if err != nil {
+			return nil, err
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	// Like curl --connect-to, the certificate is still verified against the host of the URL
+	if *f.httpsConnect != "" {
+		connect := *f.httpsConnect
+		dialer := &net.Dialer{}
+
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, connect)
+		}
+	}
+
+	return &http.Client{Transport: transport, Timeout: *f.httpsTimeout}, nil
+}
+
+// closeChecker stops the rate limiter of the checker and persists its cache
+func (f *checkerFlags) closeChecker(checker *Checker) {
+	checker.Limiter.Stop()
+
+	if checker.Cache == nil || *f.cacheFile == "" {
+		return
+	}
+
+	if err := checker.Cache.Save(*f.cacheFile); err != nil {
+		log.Printf("Error could not save the DNS cache: %v\n", err)
+	}
+}
+
+// defaultCacheFile returns where the DNS cache is kept by default, in the user cache directory
+func defaultCacheFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "email-checker-tool", "dns-cache.json")
+}
+
+// splitList splits a comma separated flag value, dropping the empty items
+func splitList(value string) []string {
+	var items []string
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
 
-	fmt.Printf("hasMX %v hasSPF %v hasDMARC %v\n", hasMX, hasSPF, hasDMARC)
-	fmt.Printf("spfRecord %v\n", spfRecord)
-	fmt.Printf("dmarcRecord %v\n", dmarcRecord)
+	return items
+}
+
+// loadCertPool reads the PEM certificates of the file into a new pool
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+
+	return pool, nil
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		lookups map[string]string
+		code    int
+	}{
+		{
+			name:    "complete",
+			lookups: map[string]string{LookupMX: resolver.ClassOK, LookupSPF: resolver.ClassOK, LookupDMARC: resolver.ClassOK},
+			code:    0,
+		},
+		{
+			name:    "no dmarc record",
+			lookups: map[string]string{LookupMX: resolver.ClassOK, LookupSPF: resolver.ClassOK, LookupDMARC: resolver.ClassNXDomain},
+			code:    0,
+		},
+		{
+			name:    "no mx record",
+			lookups: map[string]string{LookupMX: resolver.ClassNotFound, LookupSPF: resolver.ClassNotFound, LookupDMARC: resolver.ClassNXDomain},
+			code:    0,
+		},
+		{
+			name:    "no domain",
+			lookups: map[string]string{LookupMX: resolver.ClassNXDomain, LookupSPF: resolver.ClassNXDomain, LookupDMARC: resolver.ClassNXDomain},
+			code:    ExitNXDomain,
+		},
+		{
+			name:    "timeout",
+			lookups: map[string]string{LookupMX: resolver.ClassOK, LookupSPF: resolver.ClassTimeout, LookupDMARC: resolver.ClassOK},
+			code:    ExitLookupFailed,
+		},
+		{
+			name:    "no domain and servfail",
+			lookups: map[string]string{LookupMX: resolver.ClassNXDomain, LookupSPF: resolver.ClassNXDomain, LookupDMARC: resolver.ClassTemporary},
+			code:    ExitLookupFailed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if code := exitCode(DomainReport{Lookups: test.lookups}); code != test.code {
+				t.Errorf("exitCode(%v) = %d, want %d", test.lookups, code, test.code)
+			}
+		})
+	}
+}
+
+func TestExitCodeOfCheck(t *testing.T) {
+	checker := newStubChecker(startStubDNS(t))
+
+	tests := []struct {
+		domain string
+		code   int
+	}{
+		{domain: "secure.example.test", code: 0},
+		// Neither the missing _dmarc subdomain nor the missing MX record is an error
+		{domain: "nospf.example.test", code: 0},
+		{domain: "long.example.test", code: 0},
+		{domain: "missing.example.test", code: ExitNXDomain},
+	}
+
+	for _, test := range tests {
+		t.Run(test.domain, func(t *testing.T) {
+			report := checker.check(context.Background(), test.domain)
+
+			if code := exitCode(report); code != test.code {
+				t.Errorf("exitCode(check(%s)) = %d, want %d (lookups %v)", test.domain, code, test.code, report.Lookups)
+			}
+		})
+	}
+}
+
+func TestRunCheckSavesCache(t *testing.T) {
+	server := startStubDNS(t)
+	cacheFile := filepath.Join(t.TempDir(), "dns-cache.json")
+
+	// The flags are only found invalid once the checker, and its cache, is created
+	args := []string{"--resolver", server.Addr(), "--cache-file", cacheFile, "--progress=false", "--summary", "--score=false", os.DevNull}
+
+	if code := runCheck(args); code != 1 {
+		t.Errorf("runCheck(%q) = %d, want 1", args, code)
+	}
+
+	if _, err := os.Stat(cacheFile); err != nil {
+		t.Errorf("the DNS cache was not saved on error: %v", err)
+	}
+}
+
+func TestCheckerFlagsVerify(t *testing.T) {
+	tests := []struct {
+		args   []string
+		verify bool
+	}{
+		// Probing the mailboxes connects to port 25 of the MX hosts, so it is opt-in
+		{args: nil, verify: false},
+		{args: []string{"--verify"}, verify: true},
+	}
+
+	for _, test := range tests {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		options := addCheckerFlags(flags)
+
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatalf("Parse(%q) = %v", test.args, err)
+		}
+
+		if *options.verifyMailbox != test.verify {
+			t.Errorf("--verify of %q = %v, want %v", test.args, *options.verifyMailbox, test.verify)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/mtasts"
+)
+
+func TestCheckMTASTS(t *testing.T) {
+	checker := newStubChecker(startStubDNS(t))
+	checker.MTASTS = &mtasts.Checker{Client: startStubHTTPS(t, "mta-sts.secure.example.test", "mta-sts.nospf.example.test", "mta-sts.long.example.test")}
+
+	tests := []struct {
+		domain      string
+		hasMTASTS   bool
+		mode        string
+		errors      []string
+		warnings    []string
+		hasTLSRPT   bool
+		tlsrptError []string
+	}{
+		{domain: "secure.example.test", hasMTASTS: true, mode: mtasts.ModeEnforce, hasTLSRPT: true},
+		{
+			domain:    "nospf.example.test",
+			hasMTASTS: true,
+			mode:      mtasts.ModeEnforce,
+			errors:    []string{"no mx pattern of the policy matches mail.nospf.example.test, senders refuse to deliver to them"},
+			warnings: []string{
+				"mx pattern mx.elsewhere.example.test matches none of the MX hosts",
+				"max_age=3600 is shorter than a day, a week or more is recommended",
+			},
+			tlsrptError: []string{`tls-rpt: invalid rua destination "ftp://reports.nospf.example.test", expected a mailto: or https: URI`},
+		},
+		{
+			// The record is invalid, and there is no policy
+			domain: "long.example.test",
+			errors: []string{"mta-sts: invalid id=missing-policy, expected 1 to 32 letters and digits", "could not fetch the policy: https://mta-sts.long.example.test/.well-known/mta-sts.txt answered 404 Not Found, expected 200 OK"},
+		},
+		{domain: "missing.example.test"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.domain, func(t *testing.T) {
+			report := checker.check(context.Background(), test.domain)
+
+			if report.HasMTASTS != test.hasMTASTS || report.HasTLSRPT != test.hasTLSRPT {
+				t.Fatalf("HasMtaSts = %t, HasTlsRpt = %t, want %t and %t", report.HasMTASTS, report.HasTLSRPT, test.hasMTASTS, test.hasTLSRPT)
+			}
+
+			if want := test.mode != "" || test.errors != nil; (report.MTASTS != nil) != want {
+				t.Fatalf("MTASTS = %+v, want a report: %t", report.MTASTS, want)
+			}
+
+			var mode string
+			var errors, warnings []string
+			if report.MTASTS != nil {
+				errors, warnings = report.MTASTS.Errors, report.MTASTS.Warnings
+
+				if report.MTASTS.Policy != nil {
+					mode = report.MTASTS.Policy.Mode
+				}
+			}
+
+			if mode != test.mode || !reflect.DeepEqual(errors, test.errors) || !reflect.DeepEqual(warnings, test.warnings) {
+				t.Errorf("MTASTS = mode %q, errors %q, warnings %q, want %q, %q and %q", mode, errors, warnings, test.mode, test.errors, test.warnings)
+			}
+
+			var tlsrptErrors []string
+			if report.TLSRPT != nil {
+				tlsrptErrors = report.TLSRPT.Errors
+			}
+
+			if !reflect.DeepEqual(tlsrptErrors, test.tlsrptError) {
+				t.Errorf("TLSRPT errors = %q, want %q", tlsrptErrors, test.tlsrptError)
+			}
+		})
+	}
+}
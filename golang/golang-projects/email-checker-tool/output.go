@@ -0,0 +1,424 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dkim"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/mailbox"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/reputation"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/smtpprobe"
+)
+
+// Supported values of the --format flag
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatTable  = "table"
+)
+
+// ReportWriter writes domain reports in a specific output format.
+// Close must be called once every report has been written.
+type ReportWriter interface {
+	WriteReport(report DomainReport) error
+	Close() error
+}
+
+// NewReportWriter returns the ReportWriter of the given format that writes to w.
+func NewReportWriter(format string, w io.Writer) (ReportWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatTable:
+		return newTableWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// reportHeader is the list of columns used by the csv and table formats
+var reportHeader = []string{"domain", "address", "mailbox", "hasMX", "smtp", "reverseDNS", "blocklists", "hasSPF", "spfRecord", "spfLookups", "spfResult", "spfErrors", "hasDMARC", "dmarcRecord", "dmarcPolicy", "dmarcErrors", "dmarcWarnings", "mtaStsMode", "mtaStsIssues", "tlsRpt", "bimiLogo", "bimiIssues", "hasDKIM", "dkimSelectors", "dkimIssues", "dnssec", "lookups", "score", "grade", "remediation", "errors"}
+
+// reportColumns converts the report into the columns of reportHeader
+func reportColumns(report DomainReport) []string {
+	var spfLookups, spfResult string
+	var spfErrors []string
+
+	if report.SPF != nil {
+		spfLookups = strconv.Itoa(report.SPF.Lookups)
+		spfErrors = report.SPF.AllErrors()
+	}
+
+	if report.SPFResult != nil {
+		spfResult = string(report.SPFResult.Result)
+		if report.SPFResult.Reason != "" {
+			spfResult += " (" + report.SPFResult.Reason + ")"
+		}
+	}
+
+	var dmarcPolicy string
+	var dmarcErrors, dmarcWarnings []string
+
+	if report.DMARC != nil {
+		if report.DMARC.Record != nil {
+			dmarcPolicy = report.DMARC.Record.Policy
+		}
+
+		dmarcErrors = report.DMARC.Errors
+		dmarcWarnings = report.DMARC.Warnings
+	}
+
+	var mtaSTSMode string
+	var mtaSTSIssues []string
+
+	if report.MTASTS != nil {
+		if report.MTASTS.Policy != nil {
+			mtaSTSMode = report.MTASTS.Policy.Mode
+		}
+
+		mtaSTSIssues = append(append(mtaSTSIssues, report.MTASTS.Errors...), report.MTASTS.Warnings...)
+	}
+
+	var tlsRPT string
+	if report.TLSRPT != nil {
+		if report.TLSRPT.Record != nil {
+			tlsRPT = strings.Join(report.TLSRPT.Record.ReportURIs, " ")
+		}
+
+		if len(report.TLSRPT.Errors) > 0 {
+			tlsRPT = strings.TrimSpace(tlsRPT + " (" + strings.Join(report.TLSRPT.Errors, "; ") + ")")
+		}
+	}
+
+	var bimiLogo string
+	var bimiIssues []string
+
+	if report.BIMI != nil {
+		if report.BIMI.Record != nil {
+			bimiLogo = report.BIMI.Record.Logo
+		}
+
+		bimiIssues = append(append(bimiIssues, report.BIMI.Errors...), report.BIMI.Warnings...)
+
+		if report.BIMI.Logo != nil {
+			bimiIssues = append(bimiIssues, report.BIMI.Logo.Problems...)
+		}
+	}
+
+	var dkimSelectors, dkimIssues []string
+
+	if report.DKIM != nil {
+		for _, selector := range report.DKIM.Selectors {
+			dkimSelectors = append(dkimSelectors, formatSelector(selector))
+
+			for _, issue := range append(append([]string{}, selector.Errors...), selector.Warnings...) {
+				dkimIssues = append(dkimIssues, selector.Selector+": "+issue)
+			}
+		}
+
+		dkimIssues = append(dkimIssues, report.DKIM.Errors...)
+	}
+
+	scoreColumns := make([]string, 3)
+	if report.Score != nil {
+		scoreColumns[0] = strconv.FormatFloat(report.Score.Score, 'f', 1, 64)
+		scoreColumns[1] = report.Score.Grade
+		scoreColumns[2] = strings.Join(report.Score.Remediation, "; ")
+	}
+
+	return []string{
+		report.Domain,
+		report.Address,
+		formatMailbox(report.Mailbox),
+		strconv.FormatBool(report.HasMX),
+		formatSMTP(report.SMTP),
+		formatReverseDNS(report.Reputation),
+		formatBlocklists(report.Reputation),
+		strconv.FormatBool(report.HasSPF),
+		report.SPFRecord,
+		spfLookups,
+		spfResult,
+		strings.Join(spfErrors, "; "),
+		strconv.FormatBool(report.HasDMARC),
+		report.DMARCRecord,
+		dmarcPolicy,
+		strings.Join(dmarcErrors, "; "),
+		strings.Join(dmarcWarnings, "; "),
+		mtaSTSMode,
+		strings.Join(mtaSTSIssues, "; "),
+		tlsRPT,
+		bimiLogo,
+		strings.Join(bimiIssues, "; "),
+		strconv.FormatBool(report.HasDKIM),
+		strings.Join(dkimSelectors, " "),
+		strings.Join(dkimIssues, "; "),
+		formatDNSSEC(report.DNSSEC),
+		formatLookups(report.Lookups),
+		scoreColumns[0],
+		scoreColumns[1],
+		scoreColumns[2],
+		formatErrors(report.Errors),
+	}
+}
+
+// formatMailbox describes the mailbox as "status (reason)"
+func formatMailbox(result *mailbox.Result) string {
+	if result == nil {
+		return ""
+	}
+
+	if result.Reason == "" {
+		return result.Status
+	}
+
+	return result.Status + " (" + result.Reason + ")"
+}
+
+// formatDNSSEC describes the status of every lookup as "lookup:status"
+func formatDNSSEC(records []RecordSecurity) string {
+	described := make([]string, 0, len(records))
+
+	for _, record := range records {
+		described = append(described, record.Lookup+":"+record.Status)
+	}
+
+	return strings.Join(described, " ")
+}
+
+// formatLookups describes the class of every lookup as "lookup:class", in the order they are made
+func formatLookups(lookups map[string]string) string {
+	var described []string
+
+	for _, lookup := range []string{LookupMX, LookupSPF, LookupDMARC} {
+		if class, ok := lookups[lookup]; ok {
+			described = append(described, lookup+":"+class)
+		}
+	}
+
+	return strings.Join(described, " ")
+}
+
+// formatSMTP describes every probed MX host as "host:status"
+func formatSMTP(results []smtpprobe.Result) string {
+	described := make([]string, 0, len(results))
+
+	for _, result := range results {
+		status := "plain"
+
+		switch {
+		case !result.Reachable:
+			status = "unreachable"
+		case result.TLS != nil && result.TLS.Certificate != nil:
+			validity := "invalid-cert"
+			if result.TLS.Certificate.Valid {
+				validity = "valid-cert"
+			}
+			status = fmt.Sprintf("starttls/%s/%dd", validity, result.TLS.Certificate.DaysLeft)
+		case result.STARTTLS:
+			status = "starttls-failed"
+		}
+
+		described = append(described, result.Host+":"+status)
+	}
+
+	return strings.Join(described, " ")
+}
+
+// formatReverseDNS describes the reverse DNS of every address of the MX hosts as "ip:status",
+// where the status is fcrdns, no-ptr, mismatch when the PTR names do not resolve back to it,
+// or unknown when the PTR lookup failed
+func formatReverseDNS(report *reputation.Report) string {
+	if report == nil {
+		return ""
+	}
+
+	var described []string
+	for _, host := range report.Hosts {
+		for _, address := range host.Addresses {
+			status := "fcrdns"
+
+			switch {
+			case address.PTRError != "":
+				status = "unknown"
+			case len(address.PTR) == 0:
+				status = "no-ptr"
+			case !address.FCrDNS:
+				status = "mismatch"
+			}
+
+			described = append(described, address.IP+":"+status)
+		}
+	}
+
+	return strings.Join(described, " ")
+}
+
+// formatBlocklists describes every listed address of the MX hosts as "ip:blocklist,blocklist"
+func formatBlocklists(report *reputation.Report) string {
+	if report == nil {
+		return ""
+	}
+
+	var described []string
+	for _, address := range report.Listed() {
+		blocklists := make([]string, 0, len(address.Listings))
+		for _, listing := range address.Listings {
+			blocklists = append(blocklists, listing.Blocklist)
+		}
+
+		described = append(described, address.IP+":"+strings.Join(blocklists, ","))
+	}
+
+	return strings.Join(described, " ")
+}
+
+// formatSelector describes a DKIM selector as "selector:type/bits"
+func formatSelector(selector dkim.Selector) string {
+	switch {
+	case selector.Key == nil:
+		return selector.Selector + ":invalid"
+	case selector.Key.Revoked():
+		return selector.Selector + ":revoked"
+	default:
+		return fmt.Sprintf("%s:%s/%d", selector.Selector, selector.Key.KeyType, selector.Key.Bits)
+	}
+}
+
+// formatErrors joins the lookup errors into a single line
+func formatErrors(errs []LookupError) string {
+	messages := make([]string, 0, len(errs))
+
+	for _, err := range errs {
+		messages = append(messages, fmt.Sprintf("%s: %s", err.Lookup, err.Error))
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// csvWriter writes one RFC 4180 record per report
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(reportHeader); err != nil {
+		return nil, err
+	}
+
+	return &csvWriter{writer: writer}, nil
+}
+
+func (c *csvWriter) WriteReport(report DomainReport) error {
+	if err := c.writer.Write(reportColumns(report)); err != nil {
+		return err
+	}
+
+	// Flush every record so that the output can be piped while still running
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// jsonWriter writes every report as a single JSON array
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonWriter) WriteReport(report DomainReport) error {
+	data, err := json.MarshalIndent(report, "  ", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Opens the array on the first report and separates the next ones
+	separator := ",\n  "
+	if j.count == 0 {
+		separator = "[\n  "
+	}
+	j.count++
+
+	_, err = fmt.Fprintf(j.w, "%s%s", separator, data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	// An empty input must still produce a valid JSON document
+	if j.count == 0 {
+		_, err := fmt.Fprintln(j.w, "[]")
+		return err
+	}
+
+	_, err := fmt.Fprintln(j.w, "\n]")
+	return err
+}
+
+// ndjsonWriter writes one JSON object per line
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonWriter) WriteReport(report DomainReport) error {
+	return n.encoder.Encode(report)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+// tableWriter writes the reports as aligned columns for humans to read
+type tableWriter struct {
+	writer *tabwriter.Writer
+}
+
+func newTableWriter(w io.Writer) (*tableWriter, error) {
+	table := &tableWriter{writer: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}
+
+	upper := make([]string, len(reportHeader))
+	for i, column := range reportHeader {
+		upper[i] = strings.ToUpper(column)
+	}
+
+	if err := table.writeRow(upper); err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+func (t *tableWriter) writeRow(columns []string) error {
+	for i, column := range columns {
+		// An empty cell would make the row hard to read
+		if column == "" {
+			columns[i] = "-"
+		}
+	}
+
+	_, err := fmt.Fprintln(t.writer, strings.Join(columns, "\t"))
+	return err
+}
+
+func (t *tableWriter) WriteReport(report DomainReport) error {
+	return t.writeRow(reportColumns(report))
+}
+
+func (t *tableWriter) Close() error {
+	// Columns can only be aligned once every row is known
+	return t.writer.Flush()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// writeReports writes the reports in the format and returns the output
+func writeReports(t *testing.T, format string, reports ...DomainReport) string {
+	t.Helper()
+
+	var output bytes.Buffer
+	writer, err := NewReportWriter(format, &output)
+	if err != nil {
+		t.Fatalf("NewReportWriter(%q) = %v", format, err)
+	}
+
+	for _, report := range reports {
+		if err := writer.WriteReport(report); err != nil {
+			t.Fatalf("WriteReport(%s) = %v", report.Domain, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	return output.String()
+}
+
+// outputReports are the reports written by the tests of the formats
+var outputReports = []DomainReport{
+	{
+		Domain:      "example.test",
+		HasMX:       true,
+		HasSPF:      true,
+		SPFRecord:   `v=spf1 include:"quoted" -all`,
+		HasDMARC:    true,
+		DMARCRecord: "v=DMARC1; p=reject, rua=mailto:dmarc@example.test",
+		Lookups:     map[string]string{LookupMX: resolver.ClassOK, LookupSPF: resolver.ClassOK, LookupDMARC: resolver.ClassOK},
+	},
+	{
+		Domain:  "a-much-longer-subdomain.example.test",
+		Lookups: map[string]string{LookupMX: resolver.ClassNXDomain},
+		Errors:  []LookupError{{Lookup: LookupMX, Query: "a-much-longer-subdomain.example.test", Error: "no such host", Class: resolver.ClassNXDomain}},
+	},
+}
+
+func TestCSVWriter(t *testing.T) {
+	output := writeReports(t, FormatCSV, outputReports...)
+
+	records, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+	if err != nil {
+		t.Fatalf("csv.ReadAll() = %v\n%s", err, output)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("csv records = %d, want the header and 2 reports\n%s", len(records), output)
+	}
+
+	if len(reportHeader) != 31 || !reflect.DeepEqual(records[0], reportHeader) {
+		t.Errorf("csv header = %q, want the %d columns %q", records[0], len(reportHeader), reportHeader)
+	}
+
+	for i, report := range outputReports {
+		if want := reportColumns(report); !reflect.DeepEqual(records[i+1], want) {
+			t.Errorf("csv record %d = %q, want %q", i+1, records[i+1], want)
+		}
+	}
+
+	// The fields with quotes or commas are quoted, the quotes doubled
+	for _, quoted := range []string{`"v=spf1 include:""quoted"" -all"`, `"v=DMARC1; p=reject, rua=mailto:dmarc@example.test"`} {
+		if !strings.Contains(output, ","+quoted+",") {
+			t.Errorf("csv output has no field %s\n%s", quoted, output)
+		}
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	if output := writeReports(t, FormatJSON); output != "[]\n" {
+		t.Errorf("json output of no report = %q, want %q", output, "[]\n")
+	}
+
+	output := writeReports(t, FormatJSON, outputReports...)
+
+	var reports []DomainReport
+	if err := json.Unmarshal([]byte(output), &reports); err != nil {
+		t.Fatalf("json.Unmarshal() = %v\n%s", err, output)
+	}
+
+	if len(reports) != 2 || reports[0].Domain != "example.test" || reports[1].Domain != "a-much-longer-subdomain.example.test" {
+		t.Errorf("json reports = %+v, want example.test and a-much-longer-subdomain.example.test", reports)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	if output := writeReports(t, FormatNDJSON); output != "" {
+		t.Errorf("ndjson output of no report = %q, want nothing", output)
+	}
+
+	output := writeReports(t, FormatNDJSON, outputReports...)
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) != len(outputReports) {
+		t.Fatalf("ndjson lines = %d, want one per report\n%s", len(lines), output)
+	}
+
+	for i, line := range lines {
+		var report DomainReport
+		if err := json.Unmarshal([]byte(line), &report); err != nil {
+			t.Errorf("ndjson line %d = %v\n%s", i+1, err, line)
+			continue
+		}
+
+		if report.Domain != outputReports[i].Domain {
+			t.Errorf("ndjson line %d domain = %q, want %q", i+1, report.Domain, outputReports[i].Domain)
+		}
+	}
+}
+
+func TestTableWriter(t *testing.T) {
+	output := writeReports(t, FormatTable, outputReports...)
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("table lines = %d, want the header and 2 reports\n%s", len(lines), output)
+	}
+
+	if !strings.HasPrefix(lines[0], "DOMAIN  ") || !strings.HasSuffix(lines[0], "  ERRORS") {
+		t.Errorf("table header = %q, want the upper case columns from DOMAIN to ERRORS", lines[0])
+	}
+
+	// Every column starts at the same offset on every line, the empty cells are shown as -
+	want := columnOffsets(lines[0])
+	if len(want) != len(reportHeader) {
+		t.Fatalf("table header has %d columns, want %d\n%s", len(want), len(reportHeader), lines[0])
+	}
+
+	for i, line := range lines[1:] {
+		if offsets := columnOffsets(line); !reflect.DeepEqual(offsets, want) {
+			t.Errorf("table line %d columns start at %v, want %v\n%s", i+2, offsets, want, output)
+		}
+	}
+
+	if fields := strings.Fields(lines[2]); len(fields) < 4 || strings.Join(fields[:4], " ") != "a-much-longer-subdomain.example.test - - false" {
+		t.Errorf("table line 3 = %q, want the empty address and mailbox shown as -", lines[2])
+	}
+}
+
+// columnOffsets returns where the columns of a table line start, the columns being
+// separated by at least two spaces
+func columnOffsets(line string) []int {
+	offsets := []int{0}
+
+	for i := 2; i < len(line); i++ {
+		if line[i] != ' ' && line[i-1] == ' ' && line[i-2] == ' ' {
+			offsets = append(offsets, i)
+		}
+	}
+
+	return offsets
+}
+
+func TestNewReportWriterUnknown(t *testing.T) {
+	if _, err := NewReportWriter("xml", &bytes.Buffer{}); err == nil || err.Error() != `unknown output format "xml"` {
+		t.Errorf(`NewReportWriter("xml") = %v, want the error unknown output format "xml"`, err)
+	}
+}
@@ -0,0 +1,173 @@
+package bimi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dmarc"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// DefaultSelector is the selector of the record mailbox providers look up
+const DefaultSelector = "default"
+
+// Resolver looks up the BIMI record.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Checker looks up the BIMI record of a domain and validates its logo.
+type Checker struct {
+	Resolver Resolver
+	// Client fetches the logos, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// Logo describes the SVG logo referenced by the l tag.
+type Logo struct {
+	URL         string `json:"url"`
+	ContentType string `json:"contentType,omitempty"`
+	Size        int    `json:"size"`
+	// Problems are the deviations from the SVG Tiny PS profile.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Report is the outcome of checking the BIMI record of a domain.
+type Report struct {
+	Record *Record `json:"record,omitempty"`
+	Logo   *Logo   `json:"logo,omitempty"`
+	// DMARCEnforced tells if the DMARC policy is strict enough for BIMI.
+	DMARCEnforced bool     `json:"dmarcEnforced"`
+	Errors        []string `json:"errors,omitempty"`
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+// Ready tells if mailbox providers can show the logo, apart from the certificate they may require.
+func (r *Report) Ready() bool {
+	return r.Record != nil && !r.Record.Declined() && r.DMARCEnforced && r.Logo != nil && len(r.Logo.Problems) == 0 && len(r.Errors) == 0
+}
+
+// Check looks up default._bimi.<domain>, verifies that the DMARC record is at enforcement
+// and validates the logo. It returns nil when the domain has no BIMI record.
+func (c *Checker) Check(ctx context.Context, domain string, dmarcRecord *dmarc.Record) *Report {
+	name := DefaultSelector + "._bimi." + strings.TrimSuffix(domain, ".")
+
+	txtRecords, err := c.Resolver.LookupTXT(ctx, name)
+	if err != nil {
+		if resolver.IsNotFound(err) {
+			return nil
+		}
+
+		return &Report{Errors: []string{fmt.Sprintf("%s: %v", name, err)}}
+	}
+
+	var bimiRecords []string
+	for _, txt := range txtRecords {
+		if IsRecord(txt) {
+			bimiRecords = append(bimiRecords, txt)
+		}
+	}
+
+	if len(bimiRecords) == 0 {
+		return nil
+	}
+
+	report := &Report{}
+
+	if len(bimiRecords) > 1 {
+		report.errorf("%d BIMI records found, mailbox providers ignore all of them when there is more than one", len(bimiRecords))
+	}
+
+	if report.Record, err = Parse(bimiRecords[0]); err != nil {
+		report.errorf("%v", err)
+		return report
+	}
+
+	if report.Record.Declined() {
+		report.warnf("the record declines to show a logo")
+		return report
+	}
+
+	report.checkDMARC(dmarcRecord)
+
+	if report.Record.Authority == "" {
+		report.warnf("no Verified Mark Certificate (a=), some mailbox providers only show certified logos")
+	}
+
+	if report.Record.Logo == "" {
+		report.errorf("l is empty, no logo to show")
+		return report
+	}
+
+	if report.Logo, err = c.fetchLogo(ctx, report.Record.Logo); err != nil {
+		report.errorf("could not fetch the logo: %v", err)
+	}
+
+	return report
+}
+
+func (r *Report) errorf(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r *Report) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// checkDMARC verifies that the DMARC policy is quarantine or reject for every message
+func (r *Report) checkDMARC(record *dmarc.Record) {
+	switch {
+	case record == nil:
+		r.errorf("BIMI requires a DMARC record at enforcement, the domain has none")
+	case record.Policy != dmarc.PolicyQuarantine && record.Policy != dmarc.PolicyReject:
+		r.errorf("BIMI requires DMARC p=quarantine or p=reject, the domain has p=%s", record.Policy)
+	case record.Percent != 100:
+		r.errorf("BIMI requires DMARC pct=100, the domain has pct=%d", record.Percent)
+	case record.SubdomainPolicy == dmarc.PolicyNone:
+		r.errorf("BIMI requires the subdomains to be at enforcement as well, the domain has sp=none")
+	default:
+		r.DMARCEnforced = true
+	}
+}
+
+// fetchLogo downloads the logo and validates it against the SVG Tiny PS profile
+func (c *Checker) fetchLogo(ctx context.Context, url string) (*Logo, error) {
+	client := http.DefaultClient
+	if c.Client != nil {
+		client = c.Client
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", url, response.Status)
+	}
+
+	// Reads a little more than allowed, so that a logo too large is reported with its size
+	data, err := io.ReadAll(io.LimitReader(response.Body, 4*MaxLogoSize))
+	if err != nil {
+		return nil, err
+	}
+
+	logo := &Logo{URL: url, ContentType: response.Header.Get("Content-Type"), Size: len(data)}
+
+	if mediaType, _, err := mime.ParseMediaType(logo.ContentType); err != nil || mediaType != "image/svg+xml" {
+		logo.Problems = append(logo.Problems, fmt.Sprintf("the logo is served as %q, expected image/svg+xml", logo.ContentType))
+	}
+
+	logo.Problems = append(logo.Problems, ValidateSVG(data)...)
+	return logo, nil
+}
@@ -0,0 +1,93 @@
+package bimi
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Record is a parsed BIMI assertion record (draft-brand-indicators-for-message-identification).
+type Record struct {
+	Raw string `json:"raw"`
+	// Logo is the https URL of the SVG logo, the l tag.
+	Logo string `json:"l"`
+	// Authority is the https URL of the Verified Mark Certificate, the a tag.
+	Authority string `json:"a,omitempty"`
+}
+
+// Declined tells if the domain publishes a record only to decline to show a logo,
+// with both l and a empty.
+func (r *Record) Declined() bool {
+	return r.Logo == "" && r.Authority == ""
+}
+
+// IsRecord tells if the TXT record is a BIMI record.
+func IsRecord(txt string) bool {
+	first := strings.TrimSpace(strings.SplitN(txt, ";", 2)[0])
+	return strings.ReplaceAll(first, " ", "") == "v=BIMI1"
+}
+
+// Parse parses a BIMI record and validates the URLs of its tags.
+func Parse(txt string) (*Record, error) {
+	record := &Record{Raw: txt}
+	seen := make(map[string]bool)
+
+	for i, field := range strings.Split(txt, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		j := strings.IndexByte(field, '=')
+		if j < 0 {
+			return nil, fmt.Errorf("bimi: invalid tag %q", field)
+		}
+
+		name, value := strings.ToLower(strings.TrimSpace(field[:j])), strings.TrimSpace(field[j+1:])
+		if seen[name] {
+			return nil, fmt.Errorf("bimi: tag %s appears more than once", name)
+		}
+		seen[name] = true
+
+		switch {
+		case i == 0 && (name != "v" || value != "BIMI1"):
+			return nil, fmt.Errorf("bimi: the record must start with v=BIMI1")
+		case name == "l":
+			record.Logo = value
+		case name == "a":
+			record.Authority = value
+		}
+	}
+
+	if !seen["l"] {
+		return nil, fmt.Errorf("bimi: the l tag is missing")
+	}
+
+	if record.Logo != "" {
+		if err := checkURL("l", record.Logo); err != nil {
+			return nil, err
+		}
+	}
+
+	if record.Authority != "" {
+		if err := checkURL("a", record.Authority); err != nil {
+			return nil, err
+		}
+	}
+
+	return record, nil
+}
+
+// checkURL validates the https URL of a tag
+func checkURL(tag, value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("bimi: invalid %s=%s: %v", tag, value, err)
+	}
+
+	if parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("bimi: invalid %s=%s, expected an https URL", tag, value)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package bimi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsRecord(t *testing.T) {
+	tests := []struct {
+		txt  string
+		want bool
+	}{
+		{txt: "v=BIMI1; l=https://example.com/logo.svg", want: true},
+		{txt: " v = BIMI1 ;l=", want: true},
+		{txt: "v=BIMI1", want: true},
+		{txt: "v=bimi1; l=https://example.com/logo.svg"},
+		{txt: "v=spf1 -all"},
+		{txt: "l=https://example.com/logo.svg; v=BIMI1"},
+	}
+
+	for _, test := range tests {
+		if got := IsRecord(test.txt); got != test.want {
+			t.Errorf("IsRecord(%q) = %t, want %t", test.txt, got, test.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		txt      string
+		want     *Record
+		declined bool
+	}{
+		{
+			txt:  "v=BIMI1; l=https://example.com/logo.svg",
+			want: &Record{Logo: "https://example.com/logo.svg"},
+		},
+		{
+			txt:  "v=BIMI1; L=https://example.com/logo.svg; A=https://example.com/vmc.pem; s=default;",
+			want: &Record{Logo: "https://example.com/logo.svg", Authority: "https://example.com/vmc.pem"},
+		},
+		{
+			txt:      "v=BIMI1; l=; a=;",
+			want:     &Record{},
+			declined: true,
+		},
+		{
+			txt:  "v=BIMI1; l=; a=https://example.com/vmc.pem",
+			want: &Record{Authority: "https://example.com/vmc.pem"},
+		},
+	}
+
+	for _, test := range tests {
+		record, err := Parse(test.txt)
+		if err != nil {
+			t.Errorf("Parse(%q) = %v", test.txt, err)
+			continue
+		}
+
+		test.want.Raw = test.txt
+		if !reflect.DeepEqual(record, test.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", test.txt, record, test.want)
+		}
+
+		if record.Declined() != test.declined {
+			t.Errorf("Parse(%q).Declined() = %t, want %t", test.txt, record.Declined(), test.declined)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		txt string
+		err string
+	}{
+		{txt: "l=https://example.com/logo.svg; v=BIMI1", err: "bimi: the record must start with v=BIMI1"},
+		{txt: "v=BIMI2; l=https://example.com/logo.svg", err: "bimi: the record must start with v=BIMI1"},
+		{txt: "v=BIMI1; l", err: `bimi: invalid tag "l"`},
+		{txt: "v=BIMI1; l=https://example.com/a.svg; l=https://example.com/b.svg", err: "bimi: tag l appears more than once"},
+		{txt: "v=BIMI1; a=https://example.com/vmc.pem", err: "bimi: the l tag is missing"},
+		{txt: "v=BIMI1; l=http://example.com/logo.svg", err: "bimi: invalid l=http://example.com/logo.svg, expected an https URL"},
+		{txt: "v=BIMI1; l=example.com/logo.svg", err: "bimi: invalid l=example.com/logo.svg, expected an https URL"},
+		{txt: "v=BIMI1; l=https:///logo.svg", err: "bimi: invalid l=https:///logo.svg, expected an https URL"},
+		{txt: "v=BIMI1; l=https://example.com/logo.svg; a=http://example.com/vmc.pem", err: "bimi: invalid a=http://example.com/vmc.pem, expected an https URL"},
+		{txt: "v=BIMI1; l=https://example.com/%zz.svg", err: `bimi: invalid l=https://example.com/%zz.svg: parse "https://example.com/%zz.svg": invalid URL escape "%zz"`},
+	}
+
+	for _, test := range tests {
+		if record, err := Parse(test.txt); err == nil || err.Error() != test.err {
+			t.Errorf("Parse(%q) = %+v, %v, want the error %q", test.txt, record, err, test.err)
+		}
+	}
+}
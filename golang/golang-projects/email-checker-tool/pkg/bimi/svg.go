@@ -0,0 +1,156 @@
+package bimi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxLogoSize is the largest logo mailbox providers are expected to accept
+const MaxLogoSize = 32 * 1024
+
+// forbiddenElements cannot appear in an SVG Tiny PS document
+var forbiddenElements = map[string]string{
+	"script":           "scripts are not allowed",
+	"foreignObject":    "foreign objects are not allowed",
+	"image":            "embedded or linked raster images are not allowed",
+	"a":                "links are not allowed",
+	"animate":          "animations are not allowed",
+	"animateColor":     "animations are not allowed",
+	"animateMotion":    "animations are not allowed",
+	"animateTransform": "animations are not allowed",
+	"set":              "animations are not allowed",
+	"audio":            "media elements are not allowed",
+	"video":            "media elements are not allowed",
+}
+
+// ValidateSVG checks the logo against the SVG Tiny Portable/Secure profile that BIMI
+// requires, and returns the problems found. No problem means the logo conforms.
+func ValidateSVG(data []byte) []string {
+	var problems []string
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(data) > MaxLogoSize {
+		problemf("the logo is %d bytes, it should not exceed %d bytes", len(data), MaxLogoSize)
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+
+	depth := 0
+	hasRoot, hasTitle := false, false
+	reported := make(map[string]bool)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			problemf("the logo is not well-formed XML: %v", err)
+			return problems
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			depth++
+
+			if depth == 1 {
+				hasRoot = true
+				checkRoot(token, problemf)
+			}
+
+			if depth == 2 && token.Name.Local == "title" {
+				hasTitle = true
+			}
+
+			if reason, ok := forbiddenElements[token.Name.Local]; ok && !reported[token.Name.Local] {
+				reported[token.Name.Local] = true
+				problemf("<%s> found, %s", token.Name.Local, reason)
+			}
+
+			for _, attr := range token.Attr {
+				// Event handlers like onload run scripts
+				if strings.HasPrefix(strings.ToLower(attr.Name.Local), "on") && !reported["on"] {
+					reported["on"] = true
+					problemf("event handler attribute %s found, scripts are not allowed", attr.Name.Local)
+				}
+
+				if attr.Name.Local == "href" && !strings.HasPrefix(attr.Value, "#") && !reported["href"] {
+					reported["href"] = true
+					problemf("external reference %q found, only references inside the document are allowed", attr.Value)
+				}
+			}
+
+		case xml.EndElement:
+			depth--
+
+		case xml.ProcInst:
+			if token.Target == "xml-stylesheet" {
+				problemf("external stylesheets are not allowed")
+			}
+		}
+	}
+
+	if !hasRoot {
+		problemf("the logo has no <svg> element")
+	} else if !hasTitle {
+		problemf("the <svg> element needs a <title> child with the name of the brand")
+	}
+
+	return problems
+}
+
+// checkRoot validates the attributes of the <svg> element
+func checkRoot(root xml.StartElement, problemf func(string, ...interface{})) {
+	if root.Name.Local != "svg" {
+		problemf("the root element is <%s>, expected <svg>", root.Name.Local)
+		return
+	}
+
+	attrs := make(map[string]string)
+	for _, attr := range root.Attr {
+		attrs[attr.Name.Local] = attr.Value
+	}
+
+	if attrs["version"] != "1.2" {
+		problemf("version=%q, SVG Tiny PS requires version=\"1.2\"", attrs["version"])
+	}
+
+	if attrs["baseProfile"] != "tiny-ps" {
+		problemf("baseProfile=%q, SVG Tiny PS requires baseProfile=\"tiny-ps\"", attrs["baseProfile"])
+	}
+
+	for _, name := range []string{"x", "y"} {
+		if _, ok := attrs[name]; ok {
+			problemf("the <svg> element must not have the %s attribute", name)
+		}
+	}
+
+	viewBox, ok := attrs["viewBox"]
+	if !ok {
+		problemf("the <svg> element has no viewBox")
+		return
+	}
+
+	// Mailbox providers display the logo in a square or a circle
+	fields := strings.Fields(strings.ReplaceAll(viewBox, ",", " "))
+	if len(fields) != 4 {
+		problemf("invalid viewBox %q", viewBox)
+		return
+	}
+
+	width, errWidth := strconv.ParseFloat(fields[2], 64)
+	height, errHeight := strconv.ParseFloat(fields[3], 64)
+
+	if errWidth != nil || errHeight != nil || width <= 0 || height <= 0 {
+		problemf("invalid viewBox %q", viewBox)
+	} else if width != height {
+		problemf("the viewBox is %gx%g, the logo should be square", width, height)
+	}
+}
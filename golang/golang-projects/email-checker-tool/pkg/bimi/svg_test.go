@@ -0,0 +1,157 @@
+package bimi
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// validRoot are the attributes of the <svg> element of a conforming logo
+const validRoot = `xmlns="http://www.w3.org/2000/svg" version="1.2" baseProfile="tiny-ps" viewBox="0 0 100 100"`
+
+// logo returns an SVG document with the attributes of the root and its content, after the title
+func logo(root, content string) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><svg %s><title>Example</title>%s</svg>`, root, content))
+}
+
+func TestValidateSVG(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		problems []string
+	}{
+		{
+			name: "conforming",
+			data: logo(validRoot, `<defs><circle id="c" cx="50" cy="50" r="40"/></defs><use xlink:href="#c" xmlns:xlink="http://www.w3.org/1999/xlink"/>`),
+		},
+		{
+			name:     "no title",
+			data:     []byte(`<svg ` + validRoot + `><circle cx="50" cy="50" r="40"/></svg>`),
+			problems: []string{"the <svg> element needs a <title> child with the name of the brand"},
+		},
+		{
+			name:     "nested title",
+			data:     []byte(`<svg ` + validRoot + `><g><title>Example</title></g></svg>`),
+			problems: []string{"the <svg> element needs a <title> child with the name of the brand"},
+		},
+		{
+			name:     "script",
+			data:     logo(validRoot, `<script>alert(1)</script><script>alert(2)</script>`),
+			problems: []string{"<script> found, scripts are not allowed"},
+		},
+		{
+			name:     "event handler",
+			data:     logo(validRoot, `<circle cx="50" cy="50" r="40" onclick="alert(1)" onmouseover="alert(2)"/>`),
+			problems: []string{"event handler attribute onclick found, scripts are not allowed"},
+		},
+		{
+			name: "forbidden elements",
+			data: logo(validRoot, `<foreignObject/><a/><animate/><set/><video/>`),
+			problems: []string{
+				"<foreignObject> found, foreign objects are not allowed",
+				"<a> found, links are not allowed",
+				"<animate> found, animations are not allowed",
+				"<set> found, animations are not allowed",
+				"<video> found, media elements are not allowed",
+			},
+		},
+		{
+			name: "external image",
+			data: logo(validRoot, `<image href="https://cdn.example.net/logo.png"/><use href="logo.svg#c"/>`),
+			problems: []string{
+				"<image> found, embedded or linked raster images are not allowed",
+				`external reference "https://cdn.example.net/logo.png" found, only references inside the document are allowed`,
+			},
+		},
+		{
+			name:     "external reference",
+			data:     logo(validRoot, `<use href="sprites.svg#c"/>`),
+			problems: []string{`external reference "sprites.svg#c" found, only references inside the document are allowed`},
+		},
+		{
+			name:     "stylesheet",
+			data:     []byte(`<?xml-stylesheet href="https://cdn.example.net/logo.css"?>` + string(logo(validRoot, ""))),
+			problems: []string{"external stylesheets are not allowed"},
+		},
+		{
+			name:     "too large",
+			data:     logo(validRoot, "<desc>"+strings.Repeat("x", MaxLogoSize)+"</desc>"),
+			problems: []string{fmt.Sprintf("the logo is %d bytes, it should not exceed %d bytes", len(logo(validRoot, ""))+MaxLogoSize+len("<desc></desc>"), MaxLogoSize)},
+		},
+		{
+			name:     "not well-formed",
+			data:     []byte(`<svg ` + validRoot + `><title>Example</svg>`),
+			problems: []string{"the logo is not well-formed XML: XML syntax error on line 1: element <title> closed by </svg>"},
+		},
+		{
+			name:     "empty",
+			data:     []byte(`<?xml version="1.0"?>`),
+			problems: []string{"the logo has no <svg> element"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if problems := ValidateSVG(test.data); !reflect.DeepEqual(problems, test.problems) {
+				t.Errorf("ValidateSVG() = %q, want %q", problems, test.problems)
+			}
+		})
+	}
+}
+
+func TestCheckRoot(t *testing.T) {
+	tests := []struct {
+		root     string
+		problems []string
+	}{
+		{root: `<svg ` + validRoot + `>`},
+		{root: `<svg version="1.2" baseProfile="tiny-ps" viewBox="0,0,64,64">`},
+		{root: `<html version="1.2" baseProfile="tiny-ps" viewBox="0 0 100 100">`, problems: []string{"the root element is <html>, expected <svg>"}},
+		{
+			root: `<svg viewBox="0 0 100 100">`,
+			problems: []string{
+				`version="", SVG Tiny PS requires version="1.2"`,
+				`baseProfile="", SVG Tiny PS requires baseProfile="tiny-ps"`,
+			},
+		},
+		{
+			root: `<svg version="1.1" baseProfile="tiny" viewBox="0 0 100 100">`,
+			problems: []string{
+				`version="1.1", SVG Tiny PS requires version="1.2"`,
+				`baseProfile="tiny", SVG Tiny PS requires baseProfile="tiny-ps"`,
+			},
+		},
+		{
+			root: `<svg version="1.2" baseProfile="tiny-ps" x="0" y="0" viewBox="0 0 100 100">`,
+			problems: []string{
+				"the <svg> element must not have the x attribute",
+				"the <svg> element must not have the y attribute",
+			},
+		},
+		{root: `<svg version="1.2" baseProfile="tiny-ps" width="100" height="100">`, problems: []string{"the <svg> element has no viewBox"}},
+		{root: `<svg version="1.2" baseProfile="tiny-ps" viewBox="0 0 100">`, problems: []string{`invalid viewBox "0 0 100"`}},
+		{root: `<svg version="1.2" baseProfile="tiny-ps" viewBox="0 0 wide 100">`, problems: []string{`invalid viewBox "0 0 wide 100"`}},
+		{root: `<svg version="1.2" baseProfile="tiny-ps" viewBox="0 0 0 0">`, problems: []string{`invalid viewBox "0 0 0 0"`}},
+		{root: `<svg version="1.2" baseProfile="tiny-ps" viewBox="0 0 200 100">`, problems: []string{"the viewBox is 200x100, the logo should be square"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.root, func(t *testing.T) {
+			token, err := xml.NewDecoder(strings.NewReader(test.root)).Token()
+			if err != nil {
+				t.Fatalf("Token() = %v", err)
+			}
+
+			var problems []string
+			checkRoot(token.(xml.StartElement), func(format string, args ...interface{}) {
+				problems = append(problems, fmt.Sprintf(format, args...))
+			})
+
+			if !reflect.DeepEqual(problems, test.problems) {
+				t.Errorf("checkRoot() = %q, want %q", problems, test.problems)
+			}
+		})
+	}
+}
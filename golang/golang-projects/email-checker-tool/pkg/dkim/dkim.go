@@ -0,0 +1,126 @@
+package dkim
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// DefaultSelectors are the selectors commonly used by mail providers and servers.
+var DefaultSelectors = []string{
+	"default", "dkim", "mail", "selector1", "selector2", "google",
+	"k1", "k2", "s1", "s2", "smtp", "mx", "key1", "key2",
+	"fm1", "fm2", "fm3", "protonmail", "protonmail2", "protonmail3",
+	"mandrill", "everlytickey1", "zoho", "amazonses",
+}
+
+// Resolver looks up the TXT records of the selectors.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Checker discovers the DKIM selectors of a domain.
+type Checker struct {
+	Resolver Resolver
+	// Selectors are probed in order. DefaultSelectors are used when it is empty.
+	Selectors []string
+}
+
+// Selector is a DKIM key found at <selector>._domainkey.<domain>.
+type Selector struct {
+	Selector string   `json:"selector"`
+	Key      *Key     `json:"key,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Report lists the selectors found for a domain.
+type Report struct {
+	Selectors []Selector `json:"selectors,omitempty"`
+	// Errors are the lookups that failed for another reason than the selector not existing.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Check probes every selector of the domain and inspects the keys that are found.
+func (c *Checker) Check(ctx context.Context, domain string) *Report {
+	selectors := c.Selectors
+	if len(selectors) == 0 {
+		selectors = DefaultSelectors
+	}
+
+	report := &Report{}
+	seen := make(map[string]bool)
+
+	for _, selector := range selectors {
+		selector = strings.ToLower(strings.TrimSpace(selector))
+		if selector == "" || seen[selector] {
+			continue
+		}
+		seen[selector] = true
+
+		name := selector + "._domainkey." + strings.TrimSuffix(domain, ".")
+		txtRecords, err := c.Resolver.LookupTXT(ctx, name)
+		if err != nil {
+			if !resolver.IsNotFound(err) {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", name, err))
+			}
+			continue
+		}
+
+		// Selectors with a CNAME to a provider can return unrelated TXT records as well
+		var keys []string
+		for _, txt := range txtRecords {
+			if IsKey(txt) {
+				keys = append(keys, txt)
+			}
+		}
+
+		if len(keys) == 0 {
+			continue
+		}
+
+		report.Selectors = append(report.Selectors, inspect(selector, keys))
+	}
+
+	return report
+}
+
+// inspect parses the key of a selector and reports its problems
+func inspect(selector string, records []string) Selector {
+	result := Selector{Selector: selector}
+
+	if len(records) > 1 {
+		result.Errors = append(result.Errors, fmt.Sprintf("%d key records, verifiers may pick any of them", len(records)))
+	}
+
+	key, err := ParseKey(records[0])
+	result.Key = key
+
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+
+	switch {
+	case key.Revoked():
+		result.Warnings = append(result.Warnings, "the key is revoked (empty p=), signatures with this selector fail")
+	case key.KeyType == KeyTypeRSA && key.Bits < MinRSABits:
+		result.Errors = append(result.Errors, fmt.Sprintf("%d-bit RSA key is weak, verifiers reject keys under %d bits", key.Bits, MinRSABits))
+	case key.KeyType == KeyTypeRSA && key.Bits < RecommendedRSABits:
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d-bit RSA key, %d bits are recommended", key.Bits, RecommendedRSABits))
+	}
+
+	if key.Testing() {
+		result.Warnings = append(result.Warnings, "t=y, the domain is testing DKIM and verifiers may ignore failures")
+	}
+
+	for _, hash := range key.Hashes {
+		if strings.EqualFold(hash, "sha1") {
+			result.Warnings = append(result.Warnings, "h allows sha1, which verifiers no longer accept")
+		}
+	}
+
+	return result
+}
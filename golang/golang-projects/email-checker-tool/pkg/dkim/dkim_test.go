@@ -0,0 +1,117 @@
+package dkim
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeResolver answers the TXT lookups from a map, the missing names do not exist
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if name == "fail._domainkey.example.test" {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+
+	records, ok := r[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return records, nil
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		records  []string
+		errors   []string
+		warnings []string
+	}{
+		{name: "rsa 2048", records: []string{"v=DKIM1; k=rsa; p=" + rsa2048Key}},
+		{name: "ed25519", records: []string{"v=DKIM1; k=ed25519; p=" + ed25519Key}},
+		{name: "rsa 1024", records: []string{"v=DKIM1; k=rsa; p=" + rsa1024Key}, warnings: []string{"1024-bit RSA key, 2048 bits are recommended"}},
+		{name: "rsa 512", records: []string{"v=DKIM1; k=rsa; p=" + rsa512Key}, errors: []string{"512-bit RSA key is weak, verifiers reject keys under 1024 bits"}},
+		{name: "revoked", records: []string{"v=DKIM1; k=rsa; p="}, warnings: []string{"the key is revoked (empty p=), signatures with this selector fail"}},
+		{name: "testing", records: []string{"v=DKIM1; t=y; p=" + rsa2048Key}, warnings: []string{"t=y, the domain is testing DKIM and verifiers may ignore failures"}},
+		{name: "sha1", records: []string{"v=DKIM1; h=sha1:sha256; p=" + rsa2048Key}, warnings: []string{"h allows sha1, which verifiers no longer accept"}},
+		{
+			name:     "weak testing key",
+			records:  []string{"v=DKIM1; k=rsa; t=y; p=" + rsa512Key},
+			errors:   []string{"512-bit RSA key is weak, verifiers reject keys under 1024 bits"},
+			warnings: []string{"t=y, the domain is testing DKIM and verifiers may ignore failures"},
+		},
+		{
+			name:    "several keys",
+			records: []string{"v=DKIM1; p=" + rsa2048Key, "v=DKIM1; k=ed25519; p=" + ed25519Key},
+			errors:  []string{"2 key records, verifiers may pick any of them"},
+		},
+		{name: "invalid key", records: []string{"v=DKIM1; k=dsa; p=" + rsa2048Key}, errors: []string{"dkim: unknown key type k=dsa"}},
+		{name: "unrelated records", records: []string{"v=spf1 -all", "v=DKIM1; p=" + rsa2048Key}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checker := &Checker{
+				Resolver:  fakeResolver{"selector._domainkey.example.test": test.records},
+				Selectors: []string{"selector"},
+			}
+
+			report := checker.Check(context.Background(), "example.test.")
+			if len(report.Selectors) != 1 || len(report.Errors) != 0 {
+				t.Fatalf("Check() = %+v, want the selector", report)
+			}
+
+			selector := report.Selectors[0]
+			if selector.Selector != "selector" || !reflect.DeepEqual(selector.Errors, test.errors) || !reflect.DeepEqual(selector.Warnings, test.warnings) {
+				t.Errorf("Check() = %s, errors %q, warnings %q, want errors %q, warnings %q",
+					selector.Selector, selector.Errors, selector.Warnings, test.errors, test.warnings)
+			}
+		})
+	}
+}
+
+func TestCheckSelectors(t *testing.T) {
+	resolver := fakeResolver{
+		"google._domainkey.example.test":    {"v=DKIM1; p=" + rsa2048Key},
+		"selector1._domainkey.example.test": {"v=DKIM1; p=" + rsa1024Key},
+		"txt._domainkey.example.test":       {"v=spf1 -all"},
+	}
+
+	tests := []struct {
+		name      string
+		selectors []string
+		found     []string
+		errors    int
+	}{
+		{name: "default selectors", found: []string{"selector1", "google"}},
+		{name: "order and case", selectors: []string{" Google", "selector1", "google", ""}, found: []string{"google", "selector1"}},
+		{name: "no key", selectors: []string{"txt", "missing"}},
+		{name: "failed lookup", selectors: []string{"fail", "google"}, found: []string{"google"}, errors: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checker := &Checker{Resolver: resolver, Selectors: test.selectors}
+			report := checker.Check(context.Background(), "example.test")
+
+			var found []string
+			for _, selector := range report.Selectors {
+				found = append(found, selector.Selector)
+			}
+
+			if !reflect.DeepEqual(found, test.found) || len(report.Errors) != test.errors {
+				t.Errorf("Check() = %q, errors %q, want %q and %d errors", found, report.Errors, test.found, test.errors)
+			}
+
+			for _, err := range report.Errors {
+				if !strings.HasPrefix(err, "fail._domainkey.example.test: ") {
+					t.Errorf("Check() error = %q, want the name of the selector", err)
+				}
+			}
+		})
+	}
+}
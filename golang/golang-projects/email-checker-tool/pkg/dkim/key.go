@@ -0,0 +1,191 @@
+package dkim
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Key types of the k tag
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
+// MinRSABits is the smallest RSA key verifiers accept (RFC 8301 section 3.2)
+const MinRSABits = 1024
+
+// RecommendedRSABits is the RSA key length signers should use
+const RecommendedRSABits = 2048
+
+// Key is a parsed DKIM key record (RFC 6376 section 3.6.1).
+type Key struct {
+	Raw      string   `json:"raw"`
+	Version  string   `json:"v,omitempty"`
+	KeyType  string   `json:"k"`
+	Hashes   []string `json:"h,omitempty"`
+	Services []string `json:"s,omitempty"`
+	Flags    []string `json:"t,omitempty"`
+	Notes    string   `json:"n,omitempty"`
+	// PublicKey is the base64 value of the p tag. It is empty when the key is revoked.
+	PublicKey string `json:"-"`
+	// Bits is the length of the public key, 0 when it could not be decoded.
+	Bits int `json:"bits"`
+}
+
+// Revoked tells if the key was revoked with an empty p tag.
+func (k *Key) Revoked() bool {
+	return k.PublicKey == ""
+}
+
+// Testing tells if the domain is testing DKIM with the t=y flag.
+func (k *Key) Testing() bool {
+	for _, flag := range k.Flags {
+		if flag == "y" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsKey tells if the TXT record looks like a DKIM key record.
+func IsKey(txt string) bool {
+	tags, err := splitTags(txt)
+	if err != nil {
+		return false
+	}
+
+	_, hasKey := tags["p"]
+	return tags["v"] == "DKIM1" || hasKey
+}
+
+// ParseKey parses a DKIM key record and decodes its public key.
+func ParseKey(txt string) (*Key, error) {
+	tags, err := splitTags(txt)
+	if err != nil {
+		return nil, err
+	}
+
+	// v is optional, but must be the first tag when it is present
+	if version, ok := tags["v"]; ok {
+		if version != "DKIM1" {
+			return nil, fmt.Errorf("dkim: unsupported version v=%s", version)
+		}
+
+		if !strings.HasPrefix(strings.TrimSpace(txt), "v") {
+			return nil, fmt.Errorf("dkim: v=DKIM1 must be the first tag")
+		}
+	}
+
+	publicKey, ok := tags["p"]
+	if !ok {
+		return nil, fmt.Errorf("dkim: the p tag is missing")
+	}
+
+	key := &Key{
+		Raw:      txt,
+		Version:  tags["v"],
+		KeyType:  KeyTypeRSA,
+		Hashes:   splitList(tags["h"]),
+		Services: splitList(tags["s"]),
+		Flags:    splitList(tags["t"]),
+		Notes:    tags["n"],
+		// Whitespace is allowed anywhere inside the base64 value
+		PublicKey: strings.Join(strings.Fields(publicKey), ""),
+	}
+
+	if keyType, ok := tags["k"]; ok {
+		key.KeyType = strings.ToLower(keyType)
+	}
+
+	if key.Revoked() {
+		return key, nil
+	}
+
+	if key.Bits, err = keyBits(key.KeyType, key.PublicKey); err != nil {
+		return key, err
+	}
+
+	return key, nil
+}
+
+// keyBits decodes the public key and returns its length in bits
+func keyBits(keyType, value string) (int, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return 0, fmt.Errorf("dkim: the public key is not valid base64: %v", err)
+	}
+
+	switch keyType {
+	case KeyTypeRSA:
+		// The key should be a SubjectPublicKeyInfo, but some publish the bare RSAPublicKey
+		if parsed, err := x509.ParsePKIXPublicKey(data); err == nil {
+			rsaKey, ok := parsed.(*rsa.PublicKey)
+			if !ok {
+				return 0, fmt.Errorf("dkim: k=rsa but the public key is a %T", parsed)
+			}
+
+			return rsaKey.N.BitLen(), nil
+		}
+
+		rsaKey, err := x509.ParsePKCS1PublicKey(data)
+		if err != nil {
+			return 0, fmt.Errorf("dkim: invalid RSA public key: %v", err)
+		}
+
+		return rsaKey.N.BitLen(), nil
+
+	case KeyTypeEd25519:
+		// RFC 8463 publishes the raw 32 bytes of the key
+		if len(data) != ed25519.PublicKeySize {
+			return 0, fmt.Errorf("dkim: an Ed25519 public key has %d bytes, got %d", ed25519.PublicKeySize, len(data))
+		}
+
+		return ed25519.PublicKeySize * 8, nil
+
+	default:
+		return 0, fmt.Errorf("dkim: unknown key type k=%s", keyType)
+	}
+}
+
+// splitTags splits the tag=value list of the record
+func splitTags(txt string) (map[string]string, error) {
+	tags := make(map[string]string)
+
+	for _, part := range strings.Split(txt, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		i := strings.IndexByte(part, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("dkim: invalid tag %q", strings.TrimSpace(part))
+		}
+
+		name := strings.TrimSpace(part[:i])
+		if _, ok := tags[name]; ok {
+			return nil, fmt.Errorf("dkim: tag %s appears more than once", name)
+		}
+
+		tags[name] = strings.TrimSpace(part[i+1:])
+	}
+
+	return tags, nil
+}
+
+// splitList splits a colon separated list
+func splitList(value string) []string {
+	var items []string
+
+	for _, item := range strings.Split(value, ":") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
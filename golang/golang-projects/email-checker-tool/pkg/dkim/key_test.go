@@ -0,0 +1,106 @@
+package dkim
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// Public keys of the fixture zone, testdata/example.test.zone
+const (
+	rsa2048Key = "MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAkQpoHMrs0CLlyKdf9mZXKLagu5iauC4xL4yhNUjf6mbsdM5EyLeb8HHaNiKysxDo0P6bs/+gIVMfA48dSVQh5Dltkdw/cs+FFbhAPJoyUETW0uZgkQK2XlrOoRo5JzKYk7CPccgrC7QP3svJaBTUbbmSLazM355O+JMLpZ4fx3Vkj37iX6mzsqjUoI+IQXmKy2Fz752nPsLbEGCBqimEKL5KmkOaDjGhcNi981RlfyXEzEjrNFSuF3fBSGRr+lWmQ4n3C6LK3osCqt8++4XdHCj+HUN9vC6+NmR3hI345TTJPtBXqEnnvc8+U97+2Fxdrika59gGW29uBvrXIauhCwIDAQAB"
+	rsa1024Key = "MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQDh9G//xUn1CFmMPcAfoT0eilGZShlDiMh7WhXnVyj8beuldKGtN56DLbMDmInDYK6rqHraAs92veuqGbEeU9fvOg6Tr1lqKNVrGn+6yav+8vjZd9i/M2629eoms2VOVrHquASTdKZP9HYIb3zlMKqPYQPyiViEsCqjepFj7twm1wIDAQAB"
+	rsa512Key  = "MFwwDQYJKoZIhvcNAQEBBQADSwAwSAJBALT4Zb5Nwc3Ly2Y3GDqyV1o9uWCxChAN3Xnv2u+t8kVUTvgvE4S6JFV1IW1OF+QLE8UPnoefCwaS0swN1dXsZKECAwEAAQ=="
+	ed25519Key = "nLSiFN7BGTR/mB30ezYmvNfN8gGh6HkLWAm0dzgFOdo="
+)
+
+// pkcs1Key returns the bare RSAPublicKey of the 1024-bit key, without the SubjectPublicKeyInfo around it
+func pkcs1Key(t *testing.T) string {
+	t.Helper()
+
+	data, err := base64.StdEncoding.DecodeString(rsa1024Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PublicKey(parsed.(*rsa.PublicKey)))
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		txt     string
+		keyType string
+		bits    int
+		revoked bool
+		testing bool
+		err     string
+	}{
+		{name: "rsa 2048", txt: "v=DKIM1; k=rsa; p=" + rsa2048Key, keyType: KeyTypeRSA, bits: 2048},
+		{name: "rsa 1024", txt: "v=DKIM1; k=rsa; p=" + rsa1024Key, keyType: KeyTypeRSA, bits: 1024},
+		{name: "rsa by default", txt: "p=" + rsa1024Key, keyType: KeyTypeRSA, bits: 1024},
+		{name: "bare rsa key", txt: "v=DKIM1; p=" + pkcs1Key(t), keyType: KeyTypeRSA, bits: 1024},
+		{name: "split key", txt: "v=DKIM1; p=" + rsa1024Key[:60] + " " + rsa1024Key[60:], keyType: KeyTypeRSA, bits: 1024},
+		{name: "ed25519", txt: "v=DKIM1; k=ed25519; p=" + ed25519Key, keyType: KeyTypeEd25519, bits: 256},
+		{name: "key type case", txt: "v=DKIM1; k=Ed25519; p=" + ed25519Key, keyType: KeyTypeEd25519, bits: 256},
+		{name: "testing", txt: "v=DKIM1; t=y:s; p=" + rsa512Key, keyType: KeyTypeRSA, bits: 512, testing: true},
+		{name: "revoked", txt: "v=DKIM1; k=rsa; p=", keyType: KeyTypeRSA, revoked: true},
+		{name: "missing key", txt: "v=DKIM1; k=rsa", err: "dkim: the p tag is missing"},
+		{name: "version", txt: "v=DKIM2; p=" + rsa1024Key, err: "dkim: unsupported version v=DKIM2"},
+		{name: "version not first", txt: "k=rsa; v=DKIM1; p=" + rsa1024Key, err: "dkim: v=DKIM1 must be the first tag"},
+		{name: "duplicate tag", txt: "v=DKIM1; p=; p=" + rsa1024Key, err: "dkim: tag p appears more than once"},
+		{name: "invalid tag", txt: "v=DKIM1; rsa; p=", err: `dkim: invalid tag "rsa"`},
+		{name: "invalid base64", txt: "v=DKIM1; p=not-base64!", err: "dkim: the public key is not valid base64"},
+		{name: "ed25519 length", txt: "v=DKIM1; k=ed25519; p=" + rsa1024Key, err: "dkim: an Ed25519 public key has 32 bytes"},
+		{name: "ed25519 as rsa", txt: "v=DKIM1; k=rsa; p=" + ed25519Key, err: "dkim: invalid RSA public key"},
+		{name: "unknown key type", txt: "v=DKIM1; k=dsa; p=" + rsa1024Key, err: "dkim: unknown key type k=dsa"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := ParseKey(test.txt)
+			if test.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+					t.Fatalf("ParseKey(%q) = %v, want %s", test.txt, err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseKey(%q) = %v", test.txt, err)
+			}
+
+			if key.KeyType != test.keyType || key.Bits != test.bits || key.Revoked() != test.revoked || key.Testing() != test.testing {
+				t.Errorf("ParseKey(%q) = k=%s, %d bits, revoked %t, testing %t, want k=%s, %d bits, revoked %t, testing %t", test.txt,
+					key.KeyType, key.Bits, key.Revoked(), key.Testing(), test.keyType, test.bits, test.revoked, test.testing)
+			}
+		})
+	}
+}
+
+func TestIsKey(t *testing.T) {
+	tests := []struct {
+		txt  string
+		want bool
+	}{
+		{txt: "v=DKIM1; k=rsa; p=" + rsa1024Key, want: true},
+		{txt: "p=", want: true},
+		{txt: "v=DKIM1", want: true},
+		{txt: "v=spf1 -all", want: false},
+		{txt: "google-site-verification=abc", want: false},
+		{txt: "v=DKIM1; broken", want: false},
+	}
+
+	for _, test := range tests {
+		if got := IsKey(test.txt); got != test.want {
+			t.Errorf("IsKey(%q) = %t, want %t", test.txt, got, test.want)
+		}
+	}
+}
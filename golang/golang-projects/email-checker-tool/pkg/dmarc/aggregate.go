@@ -0,0 +1,410 @@
+package dmarc
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxReportSize bounds the size of a decompressed aggregate report, which guards
+// against the archives that expand to much more than they weigh.
+const MaxReportSize = 64 << 20
+
+// Results of the DKIM and SPF evaluations of a policy_evaluated element
+const (
+	ResultPass = "pass"
+	ResultFail = "fail"
+)
+
+// Feedback is an aggregate report (RFC 7489 appendix C).
+type Feedback struct {
+	Metadata ReportMetadata    `xml:"report_metadata" json:"metadata"`
+	Policy   PolicyPublished   `xml:"policy_published" json:"policy"`
+	Records  []AggregateRecord `xml:"record" json:"records"`
+}
+
+// ReportMetadata tells who sent the report and which period it covers.
+type ReportMetadata struct {
+	OrgName   string    `xml:"org_name" json:"orgName"`
+	Email     string    `xml:"email" json:"email"`
+	ReportID  string    `xml:"report_id" json:"reportId"`
+	DateRange DateRange `xml:"date_range" json:"dateRange"`
+	Errors    []string  `xml:"error" json:"errors,omitempty"`
+}
+
+// DateRange is the period of a report, in seconds since the Unix epoch.
+type DateRange struct {
+	Begin int64 `xml:"begin" json:"begin"`
+	End   int64 `xml:"end" json:"end"`
+}
+
+// PolicyPublished is the DMARC record the receiver found when evaluating the messages.
+type PolicyPublished struct {
+	Domain          string `xml:"domain" json:"domain"`
+	DKIMAlignment   string `xml:"adkim" json:"adkim,omitempty"`
+	SPFAlignment    string `xml:"aspf" json:"aspf,omitempty"`
+	Policy          string `xml:"p" json:"p"`
+	SubdomainPolicy string `xml:"sp" json:"sp,omitempty"`
+	Percent         string `xml:"pct" json:"pct,omitempty"`
+}
+
+// AggregateRecord counts the messages that share a source IP and the same evaluation.
+type AggregateRecord struct {
+	SourceIP    string           `xml:"row>source_ip" json:"sourceIp"`
+	Count       int64            `xml:"row>count" json:"count"`
+	Evaluated   PolicyEvaluated  `xml:"row>policy_evaluated" json:"policyEvaluated"`
+	HeaderFrom  string           `xml:"identifiers>header_from" json:"headerFrom"`
+	EnvelopeTo  string           `xml:"identifiers>envelope_to" json:"envelopeTo,omitempty"`
+	DKIMResults []AuthResultDKIM `xml:"auth_results>dkim" json:"dkim,omitempty"`
+	SPFResults  []AuthResultSPF  `xml:"auth_results>spf" json:"spf,omitempty"`
+}
+
+// PolicyEvaluated is the outcome of DMARC for the messages of a record.
+type PolicyEvaluated struct {
+	Disposition string `xml:"disposition" json:"disposition"`
+	// DKIM and SPF are the aligned results, pass or fail.
+	DKIM string `xml:"dkim" json:"dkim"`
+	SPF  string `xml:"spf" json:"spf"`
+}
+
+// AuthResultDKIM is the raw result of a DKIM signature, aligned or not.
+type AuthResultDKIM struct {
+	Domain   string `xml:"domain" json:"domain"`
+	Selector string `xml:"selector" json:"selector,omitempty"`
+	Result   string `xml:"result" json:"result"`
+}
+
+// AuthResultSPF is the raw result of the SPF check, aligned or not.
+type AuthResultSPF struct {
+	Domain string `xml:"domain" json:"domain"`
+	Scope  string `xml:"scope" json:"scope,omitempty"`
+	Result string `xml:"result" json:"result"`
+}
+
+// Passed tells if the messages of the record passed DMARC, which takes an aligned DKIM or SPF pass.
+func (r *AggregateRecord) Passed() bool {
+	return strings.EqualFold(r.Evaluated.DKIM, ResultPass) || strings.EqualFold(r.Evaluated.SPF, ResultPass)
+}
+
+// ParseAggregate parses the XML of an aggregate report.
+func ParseAggregate(r io.Reader) (*Feedback, error) {
+	var feedback Feedback
+
+	decoder := xml.NewDecoder(r)
+	// Some reporters declare another charset than UTF-8, the content is ASCII in practice
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	if err := decoder.Decode(&feedback); err != nil {
+		return nil, fmt.Errorf("dmarc: invalid aggregate report: %v", err)
+	}
+
+	if feedback.Policy.Domain == "" && len(feedback.Records) == 0 {
+		return nil, fmt.Errorf("dmarc: invalid aggregate report: no policy_published nor record")
+	}
+
+	return &feedback, nil
+}
+
+// ReadAggregateFile reads the aggregate reports of a file, either raw XML, gzip or a zip
+// archive of XML files. The format is told by the content rather than the extension.
+func ReadAggregateFile(path string) ([]*Feedback, error) {
+	data, err := readLimited(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		defer reader.Close()
+
+		feedback, err := ParseAggregate(io.LimitReader(reader, MaxReportSize))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+
+		return []*Feedback{feedback}, nil
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return readZip(path, data)
+	default:
+		feedback, err := ParseAggregate(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+
+		return []*Feedback{feedback}, nil
+	}
+}
+
+// readLimited reads the whole file, which must not exceed MaxReportSize
+func readLimited(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxReportSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > MaxReportSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", path, MaxReportSize)
+	}
+
+	return data, nil
+}
+
+// readZip parses every XML file of the zip archive
+func readZip(path string, data []byte) ([]*Feedback, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	var reports []*Feedback
+
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(file.Name), ".xml") {
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", path, file.Name, err)
+		}
+
+		feedback, err := ParseAggregate(io.LimitReader(reader, MaxReportSize))
+		reader.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", path, file.Name, err)
+		}
+
+		reports = append(reports, feedback)
+	}
+
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("%s: the archive has no XML file", path)
+	}
+
+	return reports, nil
+}
+
+// Aggregate adds up the records of many aggregate reports.
+type Aggregate struct {
+	Reports       int       `json:"reports"`
+	Organizations []string  `json:"organizations"`
+	Begin         time.Time `json:"begin"`
+	End           time.Time `json:"end"`
+	Total         Counts    `json:"total"`
+	// BySource and ByDomain are filled by Sort.
+	BySource []SourceCounts `json:"bySource"`
+	ByDomain []DomainCounts `json:"byDomain"`
+
+	sources map[string]*SourceCounts
+	domains map[string]*DomainCounts
+	orgs    map[string]bool
+	// seen are the reports already added, a report sent twice is only counted once
+	seen map[string]bool
+}
+
+// Counts are the messages of a group of records.
+type Counts struct {
+	Messages int64 `json:"messages"`
+	// Passed and Failed are the messages that passed or failed DMARC.
+	Passed int64 `json:"passed"`
+	Failed int64 `json:"failed"`
+	// DKIMPassed and SPFPassed are the messages with an aligned DKIM or SPF pass.
+	DKIMPassed int64 `json:"dkimPassed"`
+	SPFPassed  int64 `json:"spfPassed"`
+	// Dispositions count the messages by what the receiver did with them: none, quarantine or reject.
+	Dispositions map[string]int64 `json:"dispositions"`
+}
+
+// SourceCounts are the messages sent from a single IP address.
+type SourceCounts struct {
+	SourceIP string `json:"sourceIp"`
+	// Domains are the header_from domains the address sent messages for.
+	Domains []string `json:"domains"`
+	Counts
+}
+
+// DomainCounts are the messages sent with a single header_from domain.
+type DomainCounts struct {
+	Domain string `json:"domain"`
+	// Sources is the number of IP addresses that sent messages for the domain.
+	Sources int `json:"sources"`
+	Counts
+
+	ips map[string]bool
+}
+
+// NewAggregate returns an empty aggregate.
+func NewAggregate() *Aggregate {
+	return &Aggregate{
+		sources: make(map[string]*SourceCounts),
+		domains: make(map[string]*DomainCounts),
+		orgs:    make(map[string]bool),
+		seen:    make(map[string]bool),
+	}
+}
+
+// Add counts the records of the report. It returns false when the same report,
+// told by its organization and id, was already added.
+func (a *Aggregate) Add(feedback *Feedback) bool {
+	metadata := feedback.Metadata
+	key := metadata.OrgName + "\x00" + metadata.ReportID
+
+	if metadata.ReportID != "" && a.seen[key] {
+		return false
+	}
+	a.seen[key] = true
+
+	a.Reports++
+
+	if !a.orgs[metadata.OrgName] {
+		a.orgs[metadata.OrgName] = true
+		a.Organizations = append(a.Organizations, metadata.OrgName)
+		sort.Strings(a.Organizations)
+	}
+
+	if metadata.DateRange.Begin > 0 {
+		begin := time.Unix(metadata.DateRange.Begin, 0).UTC()
+		if a.Begin.IsZero() || begin.Before(a.Begin) {
+			a.Begin = begin
+		}
+	}
+
+	if end := time.Unix(metadata.DateRange.End, 0).UTC(); metadata.DateRange.End > 0 && end.After(a.End) {
+		a.End = end
+	}
+
+	for i := range feedback.Records {
+		record := &feedback.Records[i]
+
+		domain := strings.ToLower(strings.TrimSuffix(record.HeaderFrom, "."))
+		if domain == "" {
+			domain = strings.ToLower(feedback.Policy.Domain)
+		}
+
+		source, ok := a.sources[record.SourceIP]
+		if !ok {
+			source = &SourceCounts{SourceIP: record.SourceIP}
+			a.sources[record.SourceIP] = source
+		}
+
+		if !contains(source.Domains, domain) {
+			source.Domains = append(source.Domains, domain)
+			sort.Strings(source.Domains)
+		}
+
+		byDomain, ok := a.domains[domain]
+		if !ok {
+			byDomain = &DomainCounts{Domain: domain, ips: make(map[string]bool)}
+			a.domains[domain] = byDomain
+		}
+
+		byDomain.ips[record.SourceIP] = true
+		byDomain.Sources = len(byDomain.ips)
+
+		a.Total.add(record)
+		source.add(record)
+		byDomain.add(record)
+	}
+
+	return true
+}
+
+// add counts the messages of the record
+func (c *Counts) add(record *AggregateRecord) {
+	c.Messages += record.Count
+
+	if record.Passed() {
+		c.Passed += record.Count
+	} else {
+		c.Failed += record.Count
+	}
+
+	if strings.EqualFold(record.Evaluated.DKIM, ResultPass) {
+		c.DKIMPassed += record.Count
+	}
+
+	if strings.EqualFold(record.Evaluated.SPF, ResultPass) {
+		c.SPFPassed += record.Count
+	}
+
+	if c.Dispositions == nil {
+		c.Dispositions = make(map[string]int64)
+	}
+
+	disposition := strings.ToLower(record.Evaluated.Disposition)
+	if disposition == "" {
+		disposition = PolicyNone
+	}
+
+	c.Dispositions[disposition] += record.Count
+}
+
+// PassRate returns the share of the messages that passed DMARC, from 0 to 1.
+func (c *Counts) PassRate() float64 {
+	if c.Messages == 0 {
+		return 0
+	}
+
+	return float64(c.Passed) / float64(c.Messages)
+}
+
+// Sort fills BySource and ByDomain, the largest senders first. It is called once every report is added.
+func (a *Aggregate) Sort() {
+	a.BySource = a.BySource[:0]
+	for _, source := range a.sources {
+		a.BySource = append(a.BySource, *source)
+	}
+
+	sort.Slice(a.BySource, func(i, j int) bool {
+		if a.BySource[i].Messages != a.BySource[j].Messages {
+			return a.BySource[i].Messages > a.BySource[j].Messages
+		}
+
+		return a.BySource[i].SourceIP < a.BySource[j].SourceIP
+	})
+
+	a.ByDomain = a.ByDomain[:0]
+	for _, domain := range a.domains {
+		a.ByDomain = append(a.ByDomain, *domain)
+	}
+
+	sort.Slice(a.ByDomain, func(i, j int) bool {
+		if a.ByDomain[i].Messages != a.ByDomain[j].Messages {
+			return a.ByDomain[i].Messages > a.ByDomain[j].Messages
+		}
+
+		return a.ByDomain[i].Domain < a.ByDomain[j].Domain
+	})
+}
+
+// contains tells if the list has the value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,116 @@
+package dmarc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// reportsDir holds an aggregate report of every format: raw XML, gzip and zip
+const reportsDir = "../../testdata/dmarc-reports"
+
+func TestReadAggregateFile(t *testing.T) {
+	tests := []struct {
+		file     string
+		org      string
+		domain   string
+		policy   string
+		messages int64
+	}{
+		{file: "google.com!secure.example.test!1704067200!1704153599.xml", org: "google.com", domain: "secure.example.test", policy: PolicyReject, messages: 167},
+		{file: "yahoo.com!secure.example.test!1704153600!1704239999.xml.gz", org: "Yahoo", domain: "secure.example.test", policy: PolicyReject, messages: 43},
+		{file: "enterprise.protection.outlook.com!secure.example.test!1704067200!1704153600.zip", org: "Enterprise Outlook", domain: "secure.example.test", policy: PolicyReject, messages: 20},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			reports, err := ReadAggregateFile(filepath.Join(reportsDir, test.file))
+			if err != nil {
+				t.Fatalf("ReadAggregateFile() = %v", err)
+			}
+
+			if len(reports) != 1 {
+				t.Fatalf("ReadAggregateFile() = %d reports, want 1", len(reports))
+			}
+
+			feedback := reports[0]
+			if feedback.Metadata.OrgName != test.org || feedback.Policy.Domain != test.domain || feedback.Policy.Policy != test.policy {
+				t.Errorf("ReadAggregateFile() = %s, %s, p=%s, want %s, %s, p=%s",
+					feedback.Metadata.OrgName, feedback.Policy.Domain, feedback.Policy.Policy, test.org, test.domain, test.policy)
+			}
+
+			var messages int64
+			for _, record := range feedback.Records {
+				messages += record.Count
+			}
+
+			if messages != test.messages {
+				t.Errorf("ReadAggregateFile() = %d messages, want %d", messages, test.messages)
+			}
+		})
+	}
+}
+
+func TestParseAggregateInvalid(t *testing.T) {
+	for _, body := range []string{"", "not xml", "<feedback></feedback>"} {
+		if _, err := ParseAggregate(strings.NewReader(body)); err == nil {
+			t.Errorf("ParseAggregate(%q) = nil error, want an error", body)
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	files, err := os.ReadDir(reportsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aggregate := NewAggregate()
+
+	for _, file := range files {
+		reports, err := ReadAggregateFile(filepath.Join(reportsDir, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, feedback := range reports {
+			if !aggregate.Add(feedback) {
+				t.Errorf("Add(%s) = false, want the report to be counted", file.Name())
+			}
+
+			// The same report sent twice is only counted once
+			if aggregate.Add(feedback) {
+				t.Errorf("Add(%s) again = true, want false", file.Name())
+			}
+		}
+	}
+
+	aggregate.Sort()
+
+	if aggregate.Reports != 3 || aggregate.Total.Messages != 230 || aggregate.Total.Passed != 218 || aggregate.Total.Failed != 12 {
+		t.Errorf("Aggregate = %d reports, %d messages, %d passed, %d failed, want 3, 230, 218 and 12",
+			aggregate.Reports, aggregate.Total.Messages, aggregate.Total.Passed, aggregate.Total.Failed)
+	}
+
+	var sources []string
+	for _, source := range aggregate.BySource {
+		sources = append(sources, source.SourceIP)
+	}
+
+	if want := []string{"192.0.2.10", "198.51.100.10", "203.0.113.66", "192.0.2.20", "203.0.113.99"}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("BySource = %q, want %q", sources, want)
+	}
+
+	if len(aggregate.ByDomain) != 2 {
+		t.Fatalf("ByDomain = %+v, want 2 domains", aggregate.ByDomain)
+	}
+
+	domain := aggregate.ByDomain[0]
+	dispositions := map[string]int64{PolicyNone: 213, PolicyQuarantine: 2, PolicyReject: 10}
+
+	if domain.Domain != "secure.example.test" || domain.Sources != 4 || domain.Messages != 225 || domain.DKIMPassed != 213 || domain.SPFPassed != 173 || !reflect.DeepEqual(domain.Dispositions, dispositions) {
+		t.Errorf("ByDomain[0] = %+v", domain)
+	}
+}
@@ -0,0 +1,264 @@
+package dmarc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// Resolver looks up the TXT records needed to lint a DMARC record.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Report is the outcome of linting the DMARC record of a domain.
+type Report struct {
+	Record   *Record  `json:"record,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Linter validates DMARC records against RFC 7489.
+type Linter struct {
+	// Resolver is used to look up the authorization records of external report destinations.
+	// A nil Resolver skips that check.
+	Resolver Resolver
+}
+
+// Lint validates the DMARC record found in txtRecords, the TXT records of _dmarc.<domain>.
+// It returns nil if none of them is a DMARC record.
+func (l *Linter) Lint(ctx context.Context, domain string, txtRecords []string) *Report {
+	var dmarcRecords []string
+	for _, txt := range txtRecords {
+		if IsDMARC(txt) {
+			dmarcRecords = append(dmarcRecords, txt)
+		}
+	}
+
+	if len(dmarcRecords) == 0 {
+		return nil
+	}
+
+	report := &Report{}
+
+	// Receivers ignore every record when there is more than one
+	if len(dmarcRecords) > 1 {
+		report.errorf("%d DMARC records found, receivers ignore all of them when there is more than one", len(dmarcRecords))
+	}
+
+	record, err := Parse(dmarcRecords[0])
+	if err != nil {
+		report.errorf("%v", err)
+		return report
+	}
+
+	report.Record = record
+	report.lintTags(record)
+	report.lintPolicy(record)
+
+	if l.Resolver != nil {
+		l.lintDestinations(ctx, report, domain, record)
+	}
+
+	return report
+}
+
+func (r *Report) errorf(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r *Report) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// lintTags checks the syntax of every tag
+func (r *Report) lintTags(record *Record) {
+	seen := make(map[string]bool)
+
+	for _, tag := range record.Tags {
+		if seen[tag.Name] {
+			r.errorf("tag %s appears more than once", tag.Name)
+		}
+		seen[tag.Name] = true
+
+		switch tag.Name {
+		case "v":
+			// IsDMARC already made sure the record starts with v=DMARC1
+
+		case "p", "sp":
+			if !isPolicy(tag.Value) {
+				r.errorf("invalid %s=%s, expected none, quarantine or reject", tag.Name, tag.Value)
+			}
+
+		case "pct":
+			if pct, err := strconv.Atoi(tag.Value); err != nil || pct < 0 || pct > 100 {
+				r.errorf("invalid pct=%s, expected an integer from 0 to 100", tag.Value)
+			}
+
+		case "adkim", "aspf":
+			if value := strings.ToLower(tag.Value); value != AlignmentRelaxed && value != AlignmentStrict {
+				r.errorf("invalid %s=%s, expected r or s", tag.Name, tag.Value)
+			}
+
+		case "fo":
+			for _, option := range splitList(tag.Value, ":") {
+				if option != "0" && option != "1" && option != "d" && option != "s" {
+					r.errorf("invalid fo option %q, expected 0, 1, d or s", option)
+				}
+			}
+
+		case "ri":
+			if ri, err := strconv.Atoi(tag.Value); err != nil || ri < 0 {
+				r.errorf("invalid ri=%s, expected a number of seconds", tag.Value)
+			}
+
+		case "rf":
+			for _, format := range splitList(tag.Value, ":") {
+				if !strings.EqualFold(format, "afrf") {
+					r.errorf("invalid rf=%s, only afrf is defined", tag.Value)
+				}
+			}
+
+		case "rua", "ruf":
+			if len(splitList(tag.Value, ",")) == 0 {
+				r.errorf("%s is empty", tag.Name)
+			}
+
+			for _, uri := range parseURIs(tag.Value) {
+				lintURI(r, tag.Name, uri)
+			}
+
+		default:
+			r.warnf("unknown tag %s is ignored", tag.Name)
+		}
+	}
+}
+
+// lintURI checks a single destination of rua or ruf
+func lintURI(r *Report, tag string, uri ReportURI) {
+	if uri.Address == "" {
+		if !strings.Contains(uri.URI, ":") {
+			r.errorf("invalid %s destination %q, expected a mailto: URI", tag, uri.URI)
+		} else {
+			r.warnf("%s destination %q is not a mailto: URI, most receivers only send reports by email", tag, uri.URI)
+		}
+		return
+	}
+
+	if i := strings.LastIndexByte(uri.Address, '@'); i <= 0 || i == len(uri.Address)-1 {
+		r.errorf("invalid %s address %q", tag, uri.Address)
+	}
+
+	if uri.MaxSize != "" && !isMaxSize(uri.MaxSize) {
+		r.errorf("invalid size limit %q in %s, expected a number with an optional k, m, g or t unit", uri.MaxSize, tag)
+	}
+}
+
+// lintPolicy reports the configurations that are valid but most likely not what the owner wants
+func (r *Report) lintPolicy(record *Record) {
+	if _, ok := record.Tag("p"); !ok {
+		if len(record.AggregateURIs) > 0 {
+			r.errorf("p is missing, receivers treat the record as p=none because it has rua")
+		} else {
+			r.errorf("p is missing, receivers ignore the record")
+		}
+	}
+
+	if record.Policy == PolicyNone && len(record.AggregateURIs) == 0 {
+		r.warnf("p=none without rua only monitors, but no aggregate report will be sent to anyone")
+	}
+
+	if record.Policy == PolicyNone && len(record.AggregateURIs) > 0 {
+		r.warnf("p=none does not protect the domain, move to quarantine or reject once the aggregate reports are clean")
+	}
+
+	if record.Policy != PolicyNone && record.Percent < 100 {
+		r.warnf("pct=%d applies p=%s to only part of the failing messages", record.Percent, record.Policy)
+	}
+
+	if record.SubdomainPolicy == PolicyNone && record.Policy != PolicyNone && isPolicy(record.Policy) {
+		r.warnf("sp=none leaves the subdomains unprotected while p=%s", record.Policy)
+	}
+
+	if len(record.FailureURIs) > 0 {
+		if _, ok := record.Tag("fo"); !ok {
+			r.warnf("ruf without fo only requests failure reports when every mechanism fails")
+		}
+	}
+
+	if _, ok := record.Tag("fo"); ok && len(record.FailureURIs) == 0 {
+		r.warnf("fo has no effect without ruf")
+	}
+}
+
+// lintDestinations checks that the external report destinations accept the reports of the domain (RFC 7489 section 7.1)
+func (l *Linter) lintDestinations(ctx context.Context, r *Report, domain string, record *Record) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	checked := make(map[string]bool)
+
+	uris := append(append([]ReportURI{}, record.AggregateURIs...), record.FailureURIs...)
+	for _, uri := range uris {
+		destination := strings.TrimSuffix(uri.Domain(), ".")
+		if destination == "" || checked[destination] || sameOrganization(domain, destination) {
+			continue
+		}
+		checked[destination] = true
+
+		name := domain + "._report._dmarc." + destination
+		txtRecords, err := l.Resolver.LookupTXT(ctx, name)
+		if err != nil && !resolver.IsNotFound(err) {
+			r.warnf("could not verify that %s accepts reports for %s: %v", destination, domain, err)
+			continue
+		}
+
+		authorized := false
+		for _, txt := range txtRecords {
+			if IsDMARC(txt) {
+				authorized = true
+				break
+			}
+		}
+
+		if !authorized {
+			r.warnf("external destination %s does not authorize reports for %s, %s needs a v=DMARC1 TXT record", destination, domain, name)
+		}
+	}
+}
+
+// sameOrganization tells if both domains have the same organizational domain
+func sameOrganization(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	orgA, errA := publicsuffix.EffectiveTLDPlusOne(a)
+	orgB, errB := publicsuffix.EffectiveTLDPlusOne(b)
+
+	return errA == nil && errB == nil && orgA == orgB
+}
+
+// isPolicy tells if the value is a valid p or sp value
+func isPolicy(value string) bool {
+	switch strings.ToLower(value) {
+	case PolicyNone, PolicyQuarantine, PolicyReject:
+		return true
+	default:
+		return false
+	}
+}
+
+// isMaxSize tells if the value is a size limit like "10m"
+func isMaxSize(value string) bool {
+	number := strings.TrimRight(strings.ToLower(value), "kmgt")
+	if len(value)-len(number) > 1 {
+		return false
+	}
+
+	_, err := strconv.ParseUint(number, 10, 64)
+	return err == nil
+}
@@ -0,0 +1,158 @@
+package dmarc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeResolver answers the TXT lookups from a map, the other names do not exist
+type fakeResolver map[string][]string
+
+func (f fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if records, ok := f[name]; ok {
+		return records, nil
+	}
+
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func TestParse(t *testing.T) {
+	record, err := Parse("v=DMARC1; p=Reject; rua=mailto:a@example.com!10m, mailto:b@example.net; fo=1:d")
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+
+	if record.Policy != PolicyReject || record.SubdomainPolicy != PolicyReject || record.Percent != 100 {
+		t.Errorf("Parse() = p=%s sp=%s pct=%d, want reject, reject and the default 100", record.Policy, record.SubdomainPolicy, record.Percent)
+	}
+
+	if len(record.AggregateURIs) != 2 || record.AggregateURIs[0].Address != "a@example.com" || record.AggregateURIs[0].MaxSize != "10m" || record.AggregateURIs[1].Domain() != "example.net" {
+		t.Errorf("Parse() rua = %+v", record.AggregateURIs)
+	}
+
+	if strings.Join(record.FailureOptions, ":") != "1:d" || record.DKIMAlignment != AlignmentRelaxed || record.ReportInterval != 86400 {
+		t.Errorf("Parse() fo=%q adkim=%s ri=%d", record.FailureOptions, record.DKIMAlignment, record.ReportInterval)
+	}
+
+	for _, txt := range []string{"v=DMARC1; p", ""} {
+		if _, err := Parse(txt); err == nil {
+			t.Errorf("Parse(%q) = nil error, want an error", txt)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	linter := &Linter{Resolver: fakeResolver{
+		"example.com._report._dmarc.authorized.example.net": {"v=DMARC1"},
+	}}
+
+	tests := []struct {
+		name     string
+		records  []string
+		errors   []string
+		warnings []string
+		none     bool
+	}{
+		{
+			name:    "enforced",
+			records: []string{"v=DMARC1; p=reject; rua=mailto:dmarc@example.com"},
+		},
+		{
+			name:    "not dmarc",
+			records: []string{"v=spf1 -all"},
+			none:    true,
+		},
+		{
+			name:     "monitoring without reports",
+			records:  []string{"v=DMARC1; p=none"},
+			warnings: []string{"p=none without rua"},
+		},
+		{
+			name:     "monitoring",
+			records:  []string{"v=DMARC1; p=none; rua=mailto:dmarc@example.com"},
+			warnings: []string{"p=none does not protect the domain"},
+		},
+		{
+			name:     "partial enforcement",
+			records:  []string{"v=DMARC1; p=quarantine; pct=50; sp=none; rua=mailto:dmarc@mail.example.com"},
+			warnings: []string{"pct=50 applies p=quarantine", "sp=none leaves the subdomains unprotected"},
+		},
+		{
+			name:    "invalid values",
+			records: []string{"v=DMARC1; p=block; pct=150; adkim=x; fo=2; rf=iodef; ri=-1; rua=reports@example.com!10q; p=reject"},
+			errors: []string{
+				"invalid p=block",
+				"invalid pct=150",
+				"invalid adkim=x",
+				"invalid fo option \"2\"",
+				"invalid rf=iodef",
+				"invalid ri=-1",
+				"invalid rua destination",
+				"tag p appears more than once",
+			},
+			warnings: []string{"fo has no effect without ruf"},
+		},
+		{
+			name:    "missing policy",
+			records: []string{"v=DMARC1; rua=mailto:dmarc@example.com"},
+			errors:  []string{"p is missing, receivers treat the record as p=none"},
+		},
+		{
+			name:    "multiple records",
+			records: []string{"v=DMARC1; p=reject; rua=mailto:dmarc@example.com", "v=DMARC1; p=none"},
+			errors:  []string{"2 DMARC records found"},
+		},
+		{
+			name:     "failure reports",
+			records:  []string{"v=DMARC1; p=reject; rua=mailto:dmarc@example.com; ruf=mailto:ruf@example.com"},
+			warnings: []string{"ruf without fo"},
+		},
+		{
+			name:     "unknown tag",
+			records:  []string{"v=DMARC1; p=reject; rua=mailto:dmarc@example.com; foo=bar"},
+			warnings: []string{"unknown tag foo"},
+		},
+		{
+			name:     "external destinations",
+			records:  []string{"v=DMARC1; p=reject; rua=mailto:reports@dmarc.example.org,mailto:agg@authorized.example.net!10m"},
+			warnings: []string{"external destination dmarc.example.org does not authorize reports for example.com"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := linter.Lint(context.Background(), "example.com", test.records)
+			if report == nil {
+				if !test.none {
+					t.Fatalf("Lint(%q) = nil, want a report", test.records)
+				}
+				return
+			}
+
+			if test.none {
+				t.Fatalf("Lint(%q) = %+v, want nil", test.records, report)
+			}
+
+			checkMessages(t, "errors", report.Errors, test.errors)
+			checkMessages(t, "warnings", report.Warnings, test.warnings)
+		})
+	}
+}
+
+// checkMessages fails the test unless every message starts with the prefix at the same position
+func checkMessages(t *testing.T, kind string, messages, prefixes []string) {
+	t.Helper()
+
+	if len(messages) != len(prefixes) {
+		t.Errorf("%s = %q, want %d messages starting with %q", kind, messages, len(prefixes), prefixes)
+		return
+	}
+
+	for i := range messages {
+		if !strings.HasPrefix(messages[i], prefixes[i]) {
+			t.Errorf("%s[%d] = %q, want it to start with %q", kind, i, messages[i], prefixes[i])
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package dmarc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Policies a domain owner can request for failing messages
+const (
+	PolicyNone       = "none"
+	PolicyQuarantine = "quarantine"
+	PolicyReject     = "reject"
+)
+
+// Alignment modes of the adkim and aspf tags
+const (
+	AlignmentRelaxed = "r"
+	AlignmentStrict  = "s"
+)
+
+// Tag is a single tag=value pair of a DMARC record, in the order it was written.
+type Tag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ReportURI is a destination of the rua or ruf tags, e.g. "mailto:dmarc@example.com!10m".
+type ReportURI struct {
+	URI string `json:"uri"`
+	// Address is the email address of a mailto URI.
+	Address string `json:"address,omitempty"`
+	// MaxSize is the optional size limit written after '!', e.g. "10m".
+	MaxSize string `json:"maxSize,omitempty"`
+}
+
+// Domain returns the domain of the mailto address, or empty for other URIs.
+func (u ReportURI) Domain() string {
+	i := strings.LastIndexByte(u.Address, '@')
+	if i < 0 {
+		return ""
+	}
+
+	return strings.ToLower(u.Address[i+1:])
+}
+
+// Record is a parsed DMARC record. The fields hold the effective values,
+// with the defaults of RFC 7489 section 6.3 for the tags that were not set.
+type Record struct {
+	Raw  string `json:"raw"`
+	Tags []Tag  `json:"tags"`
+
+	Policy          string      `json:"p"`
+	SubdomainPolicy string      `json:"sp"`
+	Percent         int         `json:"pct"`
+	AggregateURIs   []ReportURI `json:"rua,omitempty"`
+	FailureURIs     []ReportURI `json:"ruf,omitempty"`
+	DKIMAlignment   string      `json:"adkim"`
+	SPFAlignment    string      `json:"aspf"`
+	FailureOptions  []string    `json:"fo"`
+	ReportInterval  int         `json:"ri"`
+	ReportFormat    string      `json:"rf"`
+}
+
+// Tag returns the value of the tag and whether it was set.
+func (r *Record) Tag(name string) (string, bool) {
+	for _, tag := range r.Tags {
+		if tag.Name == name {
+			return tag.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// IsDMARC tells if the TXT record is a DMARC record.
+func IsDMARC(txt string) bool {
+	name, value, ok := splitTag(strings.SplitN(txt, ";", 2)[0])
+	return ok && name == "v" && value == "DMARC1"
+}
+
+// Parse parses a DMARC record. It only fails if the record cannot be split into tags,
+// the values of the tags are checked by Lint.
+func Parse(txt string) (*Record, error) {
+	record := &Record{
+		Raw:            txt,
+		Percent:        100,
+		DKIMAlignment:  AlignmentRelaxed,
+		SPFAlignment:   AlignmentRelaxed,
+		FailureOptions: []string{"0"},
+		ReportInterval: 86400,
+		ReportFormat:   "afrf",
+	}
+
+	for _, part := range strings.Split(txt, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		name, value, ok := splitTag(part)
+		if !ok {
+			return nil, fmt.Errorf("dmarc: invalid tag %q", strings.TrimSpace(part))
+		}
+
+		record.Tags = append(record.Tags, Tag{Name: name, Value: value})
+	}
+
+	if len(record.Tags) == 0 {
+		return nil, fmt.Errorf("dmarc: empty record")
+	}
+
+	// Invalid values are kept out of the effective values, Lint reports them
+	for _, tag := range record.Tags {
+		switch tag.Name {
+		case "p":
+			record.Policy = strings.ToLower(tag.Value)
+		case "sp":
+			record.SubdomainPolicy = strings.ToLower(tag.Value)
+		case "pct":
+			if pct, err := strconv.Atoi(tag.Value); err == nil && pct >= 0 && pct <= 100 {
+				record.Percent = pct
+			}
+		case "rua":
+			record.AggregateURIs = parseURIs(tag.Value)
+		case "ruf":
+			record.FailureURIs = parseURIs(tag.Value)
+		case "adkim":
+			record.DKIMAlignment = strings.ToLower(tag.Value)
+		case "aspf":
+			record.SPFAlignment = strings.ToLower(tag.Value)
+		case "fo":
+			record.FailureOptions = splitList(tag.Value, ":")
+		case "ri":
+			if ri, err := strconv.Atoi(tag.Value); err == nil && ri >= 0 {
+				record.ReportInterval = ri
+			}
+		case "rf":
+			record.ReportFormat = strings.ToLower(tag.Value)
+		}
+	}
+
+	// The subdomains use the policy of the domain unless sp is set
+	if record.SubdomainPolicy == "" {
+		record.SubdomainPolicy = record.Policy
+	}
+
+	return record, nil
+}
+
+// splitTag splits "name=value" and trims the whitespace around both
+func splitTag(part string) (string, string, bool) {
+	i := strings.IndexByte(part, '=')
+	if i < 0 {
+		return "", "", false
+	}
+
+	name := strings.TrimSpace(part[:i])
+	if name == "" {
+		return "", "", false
+	}
+
+	return name, strings.TrimSpace(part[i+1:]), true
+}
+
+// parseURIs parses the comma separated list of a rua or ruf tag
+func parseURIs(value string) []ReportURI {
+	var uris []ReportURI
+
+	for _, item := range splitList(value, ",") {
+		uri := ReportURI{URI: item}
+
+		// The size limit is written after '!', which cannot appear in the URI itself
+		address := item
+		if i := strings.LastIndexByte(item, '!'); i >= 0 {
+			address, uri.MaxSize = item[:i], item[i+1:]
+		}
+
+		if len(address) > len("mailto:") && strings.EqualFold(address[:len("mailto:")], "mailto:") {
+			uri.Address = address[len("mailto:"):]
+		}
+
+		uris = append(uris, uri)
+	}
+
+	return uris
+}
+
+// splitList splits the value on sep and trims every item, dropping the empty ones
+func splitList(value, sep string) []string {
+	var items []string
+
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,250 @@
+package dnscache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Default bounds of the TTLs
+const (
+	DefaultMinTTL = time.Minute
+	DefaultMaxTTL = 24 * time.Hour
+	// DefaultTTL is used when the resolver does not tell the TTL of the answer,
+	// like the system resolver, and for the negative answers.
+	DefaultTTL = 5 * time.Minute
+)
+
+// fileVersion is bumped whenever the format of the cache file changes
+const fileVersion = 1
+
+// Cache keeps the answers of DNS lookups, keyed by query name and type, until their TTL expires.
+// It is safe for concurrent use.
+type Cache struct {
+	// MinTTL and MaxTTL bound the TTL of every answer. Zero means no bound.
+	MinTTL time.Duration
+	MaxTTL time.Duration
+	// DefaultTTL is used when the TTL of an answer is unknown.
+	DefaultTTL time.Duration
+	// Resolver identifies the resolver the answers come from. Load ignores
+	// the files written for another resolver.
+	Resolver string
+
+	mu      sync.Mutex
+	entries map[string]*Entry
+	hits    int64
+	misses  int64
+}
+
+// Entry is a cached answer. Either Records or Error is set.
+type Entry struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Records are the answers in zone file format.
+	Records []string `json:"records,omitempty"`
+	// Error is the message of a negative answer (NXDOMAIN or no records of the type).
+	Error   string    `json:"error,omitempty"`
+	Server  string    `json:"server,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+// file is the content of a persisted cache
+type file struct {
+	Version  int      `json:"version"`
+	Resolver string   `json:"resolver"`
+	Entries  []*Entry `json:"entries"`
+}
+
+// New returns an empty cache with the default TTL bounds.
+func New() *Cache {
+	return &Cache{
+		MinTTL:     DefaultMinTTL,
+		MaxTTL:     DefaultMaxTTL,
+		DefaultTTL: DefaultTTL,
+		entries:    make(map[string]*Entry),
+	}
+}
+
+// Get returns the entry of the query if it has not expired yet.
+func (c *Cache) Get(name string, qtype uint16) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cacheKey(name, qtype)
+
+	entry, ok := c.entries[key]
+	if ok && time.Now().After(entry.Expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+
+	return entry, ok
+}
+
+// Put caches the answer of the query. A zero ttl means that the TTL is unknown.
+func (c *Cache) Put(name string, qtype uint16, records []dns.RR, ttl time.Duration) {
+	entry := &Entry{Name: normalize(name), Type: dns.TypeToString[qtype]}
+
+	for _, record := range records {
+		entry.Records = append(entry.Records, record.String())
+	}
+
+	c.put(entry, ttl)
+}
+
+// PutError caches a negative answer of the query.
+func (c *Cache) PutError(name string, qtype uint16, message, server string) {
+	c.put(&Entry{Name: normalize(name), Type: dns.TypeToString[qtype], Error: message, Server: server}, 0)
+}
+
+// put stores the entry for its TTL, bounded by MinTTL and MaxTTL
+func (c *Cache) put(entry *Entry, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.DefaultTTL
+	}
+
+	if c.MinTTL > 0 && ttl < c.MinTTL {
+		ttl = c.MinTTL
+	}
+
+	if c.MaxTTL > 0 && ttl > c.MaxTTL {
+		ttl = c.MaxTTL
+	}
+
+	entry.Expires = time.Now().Add(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]*Entry)
+	}
+
+	c.entries[entry.Name+"/"+entry.Type] = entry
+}
+
+// Stats returns the number of lookups answered from the cache and the number that were not.
+func (c *Cache) Stats() (hits, misses int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses
+}
+
+// Load adds the entries of a file written by Save. A missing file is not an error.
+func (c *Cache) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var content file
+	if err := json.Unmarshal(data, &content); err != nil {
+		return fmt.Errorf("dnscache: invalid cache file %s: %v", path, err)
+	}
+
+	// An older format, or the answers of another resolver, are simply dropped and looked up again
+	if content.Version != fileVersion || content.Resolver != c.Resolver {
+		return nil
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]*Entry)
+	}
+
+	for _, entry := range content.Entries {
+		if entry.Expires.After(now) {
+			c.entries[entry.Name+"/"+entry.Type] = entry
+		}
+	}
+
+	return nil
+}
+
+// Save writes the entries that have not expired yet to the file, creating its directory if needed.
+func (c *Cache) Save(path string) error {
+	now := time.Now()
+	content := file{Version: fileVersion, Resolver: c.Resolver}
+
+	c.mu.Lock()
+	for _, entry := range c.entries {
+		if entry.Expires.After(now) {
+			content.Entries = append(content.Entries, entry)
+		}
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Writes to a temporary file first so that an interrupted run does not leave a truncated cache
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// RRs parses the records of the entry.
+func (e *Entry) RRs() ([]dns.RR, error) {
+	records := make([]dns.RR, 0, len(e.Records))
+
+	for _, text := range e.Records {
+		record, err := dns.NewRR(text)
+		if err != nil {
+			return nil, fmt.Errorf("dnscache: invalid cached record %q: %v", text, err)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// cacheKey returns the key of the query
+func cacheKey(name string, qtype uint16) string {
+	return normalize(name) + "/" + dns.TypeToString[qtype]
+}
+
+// normalize makes the names that only differ by case or by the trailing dot equal
+func normalize(name string) string {
+	return strings.ToLower(dns.Fqdn(name))
+}
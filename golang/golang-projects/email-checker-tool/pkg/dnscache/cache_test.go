@@ -0,0 +1,219 @@
+package dnscache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// mustRR parses a record in zone file format
+func mustRR(t *testing.T, text string) dns.RR {
+	t.Helper()
+
+	record, err := dns.NewRR(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return record
+}
+
+func TestPutTTL(t *testing.T) {
+	tests := []struct {
+		name   string
+		minTTL time.Duration
+		maxTTL time.Duration
+		ttl    time.Duration
+		want   time.Duration
+	}{
+		{name: "within the bounds", minTTL: DefaultMinTTL, maxTTL: DefaultMaxTTL, ttl: time.Hour, want: time.Hour},
+		{name: "unknown", minTTL: DefaultMinTTL, maxTTL: DefaultMaxTTL, ttl: 0, want: DefaultTTL},
+		{name: "below MinTTL", minTTL: DefaultMinTTL, maxTTL: DefaultMaxTTL, ttl: 10 * time.Second, want: DefaultMinTTL},
+		{name: "above MaxTTL", minTTL: DefaultMinTTL, maxTTL: DefaultMaxTTL, ttl: 48 * time.Hour, want: DefaultMaxTTL},
+		{name: "unknown below MinTTL", minTTL: 10 * time.Minute, maxTTL: DefaultMaxTTL, ttl: 0, want: 10 * time.Minute},
+		{name: "unknown above MaxTTL", minTTL: 0, maxTTL: time.Minute, ttl: 0, want: time.Minute},
+		{name: "no bounds", minTTL: 0, maxTTL: 0, ttl: 5 * time.Second, want: 5 * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := New()
+			cache.MinTTL, cache.MaxTTL = test.minTTL, test.maxTTL
+
+			before := time.Now()
+			cache.Put("example.test", dns.TypeMX, []dns.RR{mustRR(t, "example.test. 300 IN MX 10 mx.example.test.")}, test.ttl)
+			after := time.Now()
+
+			entry, ok := cache.Get("example.test", dns.TypeMX)
+			if !ok {
+				t.Fatal("Get() after Put() = false")
+			}
+
+			if entry.Expires.Before(before.Add(test.want)) || entry.Expires.After(after.Add(test.want)) {
+				t.Errorf("Put(ttl %s) expires in %s, want %s", test.ttl, entry.Expires.Sub(before).Round(time.Second), test.want)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	cache := New()
+	cache.MinTTL = 0
+
+	cache.Put("Example.TEST.", dns.TypeTXT, []dns.RR{mustRR(t, `example.test. 300 IN TXT "v=spf1 -all"`)}, time.Hour)
+	cache.Put("expired.example.test", dns.TypeTXT, nil, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	tests := []struct {
+		name  string
+		qtype uint16
+		ok    bool
+	}{
+		// The names only differing by case or by the trailing dot are the same
+		{name: "example.test", qtype: dns.TypeTXT, ok: true},
+		{name: "EXAMPLE.test.", qtype: dns.TypeTXT, ok: true},
+		{name: "example.test", qtype: dns.TypeMX, ok: false},
+		{name: "expired.example.test", qtype: dns.TypeTXT, ok: false},
+		{name: "other.example.test", qtype: dns.TypeTXT, ok: false},
+	}
+
+	for _, test := range tests {
+		if _, ok := cache.Get(test.name, test.qtype); ok != test.ok {
+			t.Errorf("Get(%s, %s) = %v, want %v", test.name, dns.TypeToString[test.qtype], ok, test.ok)
+		}
+	}
+
+	if hits, misses := cache.Stats(); hits != 2 || misses != 3 {
+		t.Errorf("Stats() = %d hits, %d misses, want 2 and 3", hits, misses)
+	}
+
+	// The expired entry is dropped, so it is not saved either
+	if _, ok := cache.entries["expired.example.test./TXT"]; ok {
+		t.Error("Get() kept the expired entry")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "dns.json")
+
+	cache := New()
+	cache.Resolver = "udp://192.0.2.53:53"
+	cache.MinTTL = 0
+	cache.Put("example.test", dns.TypeMX, []dns.RR{mustRR(t, "example.test. 300 IN MX 10 mx.example.test.")}, time.Hour)
+	cache.PutError("missing.example.test", dns.TypeTXT, "no such host", "192.0.2.53:53")
+	cache.Put("expired.example.test", dns.TypeMX, nil, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	// The directory is created, and only the file is left once the temporary one is renamed
+	if err := cache.Save(path); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+
+	files, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].Name() != "dns.json" {
+		t.Errorf("Save() left %v, want dns.json only", files)
+	}
+
+	loaded := New()
+	loaded.Resolver = cache.Resolver
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+
+	if len(loaded.entries) != 2 {
+		t.Errorf("Load() = %d entries, want 2 without the expired one", len(loaded.entries))
+	}
+
+	entry, ok := loaded.Get("example.test", dns.TypeMX)
+	if !ok {
+		t.Fatal("Load() lost the MX entry")
+	}
+
+	records, err := entry.RRs()
+	if err != nil || len(records) != 1 || records[0].(*dns.MX).Mx != "mx.example.test." {
+		t.Errorf("RRs() = %v, %v, want the MX record", records, err)
+	}
+
+	entry, ok = loaded.Get("missing.example.test", dns.TypeTXT)
+	if !ok || entry.Error != "no such host" || entry.Server != "192.0.2.53:53" {
+		t.Errorf("Load() = %+v, want the negative answer", entry)
+	}
+}
+
+func TestLoadIgnored(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	entries := []*Entry{{Name: "example.test.", Type: "TXT", Records: []string{`example.test. 300 IN TXT "v=spf1 -all"`}, Expires: expires}}
+
+	tests := []struct {
+		name    string
+		content interface{}
+		entries int
+		err     bool
+	}{
+		{name: "same resolver", content: file{Version: fileVersion, Resolver: "system", Entries: entries}, entries: 1},
+		{name: "other resolver", content: file{Version: fileVersion, Resolver: "udp://192.0.2.53:53", Entries: entries}},
+		{name: "other version", content: file{Version: fileVersion + 1, Resolver: "system", Entries: entries}},
+		{name: "invalid", content: "not a cache", err: true},
+		{name: "missing"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dns.json")
+
+			if test.content != nil {
+				data, err := json.Marshal(test.content)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := os.WriteFile(path, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cache := New()
+			cache.Resolver = "system"
+
+			err := cache.Load(path)
+			if (err != nil) != test.err {
+				t.Fatalf("Load() = %v, want an error %v", err, test.err)
+			}
+
+			if len(cache.entries) != test.entries {
+				t.Errorf("Load() = %d entries, want %d", len(cache.entries), test.entries)
+			}
+		})
+	}
+}
+
+func TestEntryRRs(t *testing.T) {
+	entry := &Entry{Records: []string{"example.test. 300 IN MX 10 mx.example.test.", "example.test. 300 IN MX 20 mx2.example.test."}}
+
+	records, err := entry.RRs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hosts []string
+	for _, record := range records {
+		hosts = append(hosts, record.(*dns.MX).Mx)
+	}
+
+	if want := []string{"mx.example.test.", "mx2.example.test."}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("RRs() = %v, want %v", hosts, want)
+	}
+
+	if _, err := (&Entry{Records: []string{"not a record"}}).RRs(); err == nil {
+		t.Error("RRs() of an invalid record = nil, want an error")
+	}
+}
@@ -0,0 +1,248 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// Querier is implemented by the resolvers that return the records themselves, with
+// their TTL, like *resolver.Client.
+type Querier interface {
+	Lookup(ctx context.Context, name string, qtype uint16) ([]dns.RR, error)
+}
+
+// Wrap returns a resolver that answers from the cache and only asks next on a miss.
+// The TTLs of the records are honored when next is a Querier, the other resolvers
+// do not tell them and DefaultTTL is used instead.
+func (c *Cache) Wrap(next resolver.Resolver) resolver.Resolver {
+	return &cachingResolver{cache: c, next: next}
+}
+
+// cachingResolver is a resolver.Resolver backed by a Cache
+type cachingResolver struct {
+	cache *Cache
+	next  resolver.Resolver
+}
+
+// LookupMX returns the MX records of the name sorted by preference.
+func (r *cachingResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	answers, err := r.lookup(ctx, name, dns.TypeMX, func() ([]dns.RR, error) {
+		records, err := r.next.LookupMX(ctx, name)
+
+		answers := make([]dns.RR, 0, len(records))
+		for _, mx := range records {
+			answers = append(answers, &dns.MX{Hdr: header(name, dns.TypeMX), Preference: mx.Pref, Mx: dns.Fqdn(mx.Host)})
+		}
+
+		return answers, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*net.MX, 0, len(answers))
+	for _, answer := range answers {
+		if mx, ok := answer.(*dns.MX); ok {
+			records = append(records, &net.MX{Host: mx.Mx, Pref: mx.Preference})
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Pref < records[j].Pref
+	})
+
+	return records, nil
+}
+
+// LookupTXT returns the TXT records of the name.
+func (r *cachingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	answers, err := r.lookup(ctx, name, dns.TypeTXT, func() ([]dns.RR, error) {
+		records, err := r.next.LookupTXT(ctx, name)
+
+		answers := make([]dns.RR, 0, len(records))
+		for _, txt := range records {
+			answers = append(answers, &dns.TXT{Hdr: header(name, dns.TypeTXT), Txt: splitTXT(txt)})
+		}
+
+		return answers, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]string, 0, len(answers))
+	for _, answer := range answers {
+		if txt, ok := answer.(*dns.TXT); ok {
+			records = append(records, strings.Join(txt.Txt, ""))
+		}
+	}
+
+	return records, nil
+}
+
+// LookupIP returns the A records, the AAAA records or both depending on network.
+func (r *cachingResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	var qtypes []uint16
+
+	switch network {
+	case "ip4":
+		qtypes = []uint16{dns.TypeA}
+	case "ip6":
+		qtypes = []uint16{dns.TypeAAAA}
+	default:
+		// Anything else is left for the next resolver to reject
+		if network != "ip" {
+			return r.next.LookupIP(ctx, network, host)
+		}
+		qtypes = []uint16{dns.TypeA, dns.TypeAAAA}
+	}
+
+	var ips []net.IP
+	var lastErr error
+
+	for _, qtype := range qtypes {
+		qtype := qtype
+
+		answers, err := r.lookup(ctx, host, qtype, func() ([]dns.RR, error) {
+			network := "ip4"
+			if qtype == dns.TypeAAAA {
+				network = "ip6"
+			}
+
+			addresses, err := r.next.LookupIP(ctx, network, host)
+
+			answers := make([]dns.RR, 0, len(addresses))
+			for _, ip := range addresses {
+				if qtype == dns.TypeA {
+					answers = append(answers, &dns.A{Hdr: header(host, qtype), A: ip})
+				} else {
+					answers = append(answers, &dns.AAAA{Hdr: header(host, qtype), AAAA: ip})
+				}
+			}
+
+			return answers, err
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		for _, answer := range answers {
+			switch rr := answer.(type) {
+			case *dns.A:
+				ips = append(ips, rr.A)
+			case *dns.AAAA:
+				ips = append(ips, rr.AAAA)
+			}
+		}
+	}
+
+	// Only fails when none of the queries returned an address
+	if len(ips) == 0 {
+		return nil, lastErr
+	}
+
+	return ips, nil
+}
+
+// LookupAddr returns the names the address points to.
+func (r *cachingResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	name, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return r.next.LookupAddr(ctx, addr)
+	}
+
+	answers, err := r.lookup(ctx, name, dns.TypePTR, func() ([]dns.RR, error) {
+		names, err := r.next.LookupAddr(ctx, addr)
+
+		answers := make([]dns.RR, 0, len(names))
+		for _, ptr := range names {
+			answers = append(answers, &dns.PTR{Hdr: header(name, dns.TypePTR), Ptr: dns.Fqdn(ptr)})
+		}
+
+		return answers, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(answers))
+	for _, answer := range answers {
+		if ptr, ok := answer.(*dns.PTR); ok {
+			names = append(names, ptr.Ptr)
+		}
+	}
+
+	return names, nil
+}
+
+// lookup answers from the cache, or with the Querier when next is one and with
+// fetch otherwise. Answers and negative answers are cached, failures are not.
+func (r *cachingResolver) lookup(ctx context.Context, name string, qtype uint16, fetch func() ([]dns.RR, error)) ([]dns.RR, error) {
+	if entry, ok := r.cache.Get(name, qtype); ok {
+		if entry.Error != "" {
+			return nil, &net.DNSError{Err: entry.Error, Name: name, Server: entry.Server, IsNotFound: true}
+		}
+
+		if answers, err := entry.RRs(); err == nil {
+			return answers, nil
+		}
+	}
+
+	var answers []dns.RR
+	var err error
+
+	if querier, ok := r.next.(Querier); ok {
+		answers, err = querier.Lookup(ctx, name, qtype)
+	} else {
+		answers, err = fetch()
+	}
+
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		r.cache.Put(name, qtype, answers, minTTL(answers))
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		r.cache.PutError(name, qtype, dnsErr.Err, dnsErr.Server)
+	}
+
+	return answers, err
+}
+
+// header returns the header of a record whose TTL is unknown
+func header(name string, qtype uint16) dns.RR_Header {
+	return dns.RR_Header{Name: dns.Fqdn(name), Rrtype: qtype, Class: dns.ClassINET}
+}
+
+// minTTL returns the smallest TTL of the records, 0 when it is unknown
+func minTTL(records []dns.RR) time.Duration {
+	var ttl uint32
+
+	for i, record := range records {
+		if i == 0 || record.Header().Ttl < ttl {
+			ttl = record.Header().Ttl
+		}
+	}
+
+	return time.Duration(ttl) * time.Second
+}
+
+// splitTXT splits a TXT value into the strings of at most 255 bytes a record can hold
+func splitTXT(value string) []string {
+	var parts []string
+
+	for len(value) > 255 {
+		parts = append(parts, value[:255])
+		value = value[255:]
+	}
+
+	return append(parts, value)
+}
@@ -0,0 +1,202 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dnsstub"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// fakeResolver answers the TXT lookups from its records, or fails them with the error of the
+// name, and counts the lookups that reach it
+type fakeResolver struct {
+	txt    map[string][]string
+	errors map[string]error
+	calls  map[string]int
+}
+
+func (r *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	r.calls[name]++
+
+	if err, ok := r.errors[name]; ok {
+		return nil, err
+	}
+
+	return r.txt[name], nil
+}
+
+func (r *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.calls[name]++
+	return nil, &net.DNSError{Err: resolver.ErrNoRecords, Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	r.calls[host]++
+	return []net.IP{net.ParseIP("192.0.2.1")}, nil
+}
+
+func (r *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	r.calls[addr]++
+	return []string{"mail.example.test."}, nil
+}
+
+func TestCachingResolver(t *testing.T) {
+	next := &fakeResolver{
+		txt: map[string][]string{"example.test": {"v=spf1 -all"}},
+		errors: map[string]error{
+			"missing.example.test":  &net.DNSError{Err: resolver.ErrNoSuchHost, Name: "missing.example.test", IsNotFound: true},
+			"nodata.example.test":   &net.DNSError{Err: resolver.ErrNoRecords, Name: "nodata.example.test", IsNotFound: true},
+			"servfail.example.test": &net.DNSError{Err: resolver.ErrServerFailed, Name: "servfail.example.test", IsTemporary: true},
+			"timeout.example.test":  &net.DNSError{Err: "i/o timeout", Name: "timeout.example.test", IsTimeout: true, IsTemporary: true},
+		},
+		calls: make(map[string]int),
+	}
+
+	tests := []struct {
+		name  string
+		class string
+		// calls is the number of lookups that reach the next resolver after asking twice
+		calls int
+	}{
+		{name: "example.test", class: resolver.ClassOK, calls: 1},
+		// The negative answers are cached, and keep telling NXDOMAIN from no records
+		{name: "missing.example.test", class: resolver.ClassNXDomain, calls: 1},
+		{name: "nodata.example.test", class: resolver.ClassNotFound, calls: 1},
+		// The failures are not, the next run may get an answer
+		{name: "servfail.example.test", class: resolver.ClassTemporary, calls: 2},
+		{name: "timeout.example.test", class: resolver.ClassTimeout, calls: 2},
+	}
+
+	cached := New().Wrap(next)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				records, err := cached.LookupTXT(context.Background(), test.name)
+
+				if class := resolver.Classify(err); class != test.class {
+					t.Errorf("LookupTXT(%s) #%d = %v (%s), want %s", test.name, i+1, err, class, test.class)
+				}
+
+				if test.class == resolver.ClassNXDomain || test.class == resolver.ClassNotFound {
+					if !resolver.IsNotFound(err) {
+						t.Errorf("IsNotFound(%v) #%d = false, want true", err, i+1)
+					}
+				}
+
+				if want := next.txt[test.name]; err == nil && !reflect.DeepEqual(records, want) {
+					t.Errorf("LookupTXT(%s) #%d = %q, want %q", test.name, i+1, records, want)
+				}
+			}
+
+			if next.calls[test.name] != test.calls {
+				t.Errorf("LookupTXT(%s) twice reached the resolver %d times, want %d", test.name, next.calls[test.name], test.calls)
+			}
+		})
+	}
+}
+
+func TestCachingResolverOtherTypes(t *testing.T) {
+	next := &fakeResolver{calls: make(map[string]int)}
+	cached := New().Wrap(next)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := cached.LookupMX(ctx, "nodata.example.test"); resolver.Classify(err) != resolver.ClassNotFound {
+			t.Errorf("LookupMX() = %v, want no records", err)
+		}
+
+		if ips, err := cached.LookupIP(ctx, "ip4", "mail.example.test"); err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+			t.Errorf("LookupIP() = %v, %v, want 192.0.2.1", ips, err)
+		}
+
+		if names, err := cached.LookupAddr(ctx, "192.0.2.1"); err != nil || !reflect.DeepEqual(names, []string{"mail.example.test."}) {
+			t.Errorf("LookupAddr() = %v, %v, want mail.example.test.", names, err)
+		}
+	}
+
+	for name, calls := range next.calls {
+		if calls != 1 {
+			t.Errorf("%s reached the resolver %d times, want 1", name, calls)
+		}
+	}
+}
+
+func TestCachingResolverTTL(t *testing.T) {
+	zone := dnsstub.NewZone()
+	if err := zone.Add(
+		"example.test. 120 IN MX 20 mx2.example.test.",
+		"example.test. 600 IN MX 10 mx1.example.test.",
+		"short.example.test. 5 IN TXT \"v=spf1 -all\"",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := dnsstub.Start("127.0.0.1:0", zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	cache := New()
+	cached := cache.Wrap(resolver.NewNameserver(server.Addr(), "udp", 2*time.Second))
+	ctx := context.Background()
+
+	records, err := cached.LookupMX(ctx, "example.test")
+	if err != nil || len(records) != 2 || records[0].Host != "mx1.example.test." {
+		t.Fatalf("LookupMX() = %v, %v, want mx1 then mx2", records, err)
+	}
+
+	_, err = cached.LookupTXT(ctx, "missing.example.test")
+	if resolver.Classify(err) != resolver.ClassNXDomain {
+		t.Fatalf("LookupTXT() = %v, want NXDOMAIN", err)
+	}
+
+	_, err = cached.LookupTXT(ctx, "example.test")
+	if resolver.Classify(err) != resolver.ClassNotFound {
+		t.Fatalf("LookupTXT() = %v, want no records", err)
+	}
+
+	cached.LookupTXT(ctx, "short.example.test")
+
+	tests := []struct {
+		name  string
+		qtype uint16
+		ttl   time.Duration
+	}{
+		// The smallest TTL of the records wins
+		{name: "example.test", qtype: dns.TypeMX, ttl: 120 * time.Second},
+		{name: "short.example.test", qtype: dns.TypeTXT, ttl: DefaultMinTTL},
+		{name: "missing.example.test", qtype: dns.TypeTXT, ttl: DefaultTTL},
+		{name: "example.test", qtype: dns.TypeTXT, ttl: DefaultTTL},
+	}
+
+	for _, test := range tests {
+		entry, ok := cache.Get(test.name, test.qtype)
+		if !ok {
+			t.Errorf("Get(%s, %s) = false, want the answer", test.name, dns.TypeToString[test.qtype])
+			continue
+		}
+
+		if ttl := time.Until(entry.Expires); ttl > test.ttl || ttl < test.ttl-5*time.Second {
+			t.Errorf("%s %s is cached for %s, want %s", test.name, dns.TypeToString[test.qtype], ttl.Round(time.Second), test.ttl)
+		}
+	}
+
+	// Once cached, the answers no longer need the server
+	server.Close()
+
+	if records, err := cached.LookupMX(ctx, "example.test"); err != nil || len(records) != 2 {
+		t.Errorf("LookupMX() from the cache = %v, %v, want 2 records", records, err)
+	}
+
+	if _, err := cached.LookupTXT(ctx, "missing.example.test"); resolver.Classify(err) != resolver.ClassNXDomain {
+		t.Errorf("LookupTXT() from the cache = %v, want NXDOMAIN", err)
+	}
+}
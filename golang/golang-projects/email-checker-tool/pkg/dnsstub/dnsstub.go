@@ -0,0 +1,258 @@
+package dnsstub
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// maxCNAMEChain is the number of CNAME records followed while answering a query
+const maxCNAMEChain = 8
+
+// Zone is the set of records served by the stub server, indexed by owner name.
+// It is usually loaded from fixture zone files so that the checks can be run
+// offline against known records.
+type Zone struct {
+	mu      sync.RWMutex
+	records map[string][]dns.RR
+	// authenticated makes the answers look validated by a DNSSEC-aware resolver
+	authenticated bool
+	// bogus are the names whose answers fail the DNSSEC validation
+	bogus map[string]bool
+}
+
+// NewZone returns an empty Zone.
+func NewZone() *Zone {
+	return &Zone{records: make(map[string][]dns.RR), bogus: make(map[string]bool)}
+}
+
+// SetAuthenticated makes the zone answer like a validating resolver for a signed zone:
+// the AD bit is set on the answers to the queries with the DO or AD bit.
+func (z *Zone) SetAuthenticated(authenticated bool) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	z.authenticated = authenticated
+}
+
+// AddBogus makes the zone answer SERVFAIL for the names, like a validating resolver
+// whose validation failed, unless the query has the CD bit to disable the validation.
+func (z *Zone) AddBogus(names ...string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	for _, name := range names {
+		z.bogus[strings.ToLower(dns.Fqdn(name))] = true
+	}
+}
+
+// Add adds records written in the zone file format, for example
+// "example.test. 300 IN MX 10 mail.example.test.".
+func (z *Zone) Add(records ...string) error {
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			return err
+		}
+
+		if rr != nil {
+			z.AddRR(rr)
+		}
+	}
+
+	return nil
+}
+
+// AddRR adds parsed records to the zone.
+func (z *Zone) AddRR(records ...dns.RR) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	for _, rr := range records {
+		name := strings.ToLower(rr.Header().Name)
+		z.records[name] = append(z.records[name], rr)
+	}
+}
+
+// Load reads the records of a zone file. origin is used for the relative names
+// of a file without an $ORIGIN directive.
+func (z *Zone) Load(r io.Reader, origin, filename string) error {
+	parser := dns.NewZoneParser(r, dns.Fqdn(origin), filename)
+
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		z.AddRR(rr)
+	}
+
+	return parser.Err()
+}
+
+// LoadFile reads the records of the zone file at path.
+func (z *Zone) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return z.Load(file, ".", path)
+}
+
+// ServeDNS answers the query from the records of the zone.
+func (z *Zone) ServeDNS(w dns.ResponseWriter, query *dns.Msg) {
+	response := new(dns.Msg)
+	response.SetReply(query)
+	response.Authoritative = true
+
+	if len(query.Question) == 1 {
+		z.answer(response, query.Question[0])
+		z.validate(response, query)
+	} else {
+		response.Rcode = dns.RcodeFormatError
+	}
+
+	// Keeps the UDP responses within the size the client can receive
+	size := dns.MinMsgSize
+	if opt := query.IsEdns0(); opt != nil {
+		size = int(opt.UDPSize())
+		response.SetEdns0(opt.UDPSize(), false)
+	}
+
+	if w.LocalAddr().Network() == "udp" {
+		response.Truncate(size)
+	}
+
+	w.WriteMsg(response)
+}
+
+// validate sets the DNSSEC status of the response the way a validating resolver would
+func (z *Zone) validate(response *dns.Msg, query *dns.Msg) {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+
+	if z.bogus[strings.ToLower(query.Question[0].Name)] && !query.CheckingDisabled {
+		response.Rcode = dns.RcodeServerFailure
+		response.Answer = nil
+		return
+	}
+
+	wantsDNSSEC := query.AuthenticatedData
+	if opt := query.IsEdns0(); opt != nil && opt.Do() {
+		wantsDNSSEC = true
+	}
+
+	response.AuthenticatedData = z.authenticated && wantsDNSSEC && !query.CheckingDisabled
+}
+
+// answer fills the response with the records matching the question
+func (z *Zone) answer(response *dns.Msg, question dns.Question) {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+
+	name := strings.ToLower(question.Name)
+
+	for i := 0; i < maxCNAMEChain; i++ {
+		records, ok := z.records[name]
+		if !ok {
+			// Only the first name of a CNAME chain decides if it is NXDOMAIN
+			if i == 0 {
+				response.Rcode = dns.RcodeNameError
+			}
+			return
+		}
+
+		var cname *dns.CNAME
+		for _, rr := range records {
+			switch {
+			case rr.Header().Rrtype == question.Qtype || question.Qtype == dns.TypeANY:
+				response.Answer = append(response.Answer, dns.Copy(rr))
+			case rr.Header().Rrtype == dns.TypeCNAME:
+				cname = rr.(*dns.CNAME)
+			}
+		}
+
+		if len(response.Answer) > 0 || cname == nil {
+			return
+		}
+
+		response.Answer = append(response.Answer, dns.Copy(cname))
+		name = strings.ToLower(cname.Target)
+	}
+}
+
+// Server serves a Zone over UDP and TCP on the same port.
+type Server struct {
+	Zone *Zone
+	addr string
+	udp  *dns.Server
+	tcp  *dns.Server
+}
+
+// Start listens on addr and serves the zone in the background. The port of
+// addr can be 0, Addr returns the port that was picked.
+func Start(addr string, zone *Zone) (*Server, error) {
+	packetConn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Uses the same port for TCP, which matters when the port was picked by the system
+	listener, err := net.Listen("tcp", packetConn.LocalAddr().String())
+	if err != nil {
+		packetConn.Close()
+		return nil, err
+	}
+
+	server := &Server{
+		Zone: zone,
+		addr: packetConn.LocalAddr().String(),
+		udp:  &dns.Server{PacketConn: packetConn, Handler: zone},
+		tcp:  &dns.Server{Listener: listener, Handler: zone},
+	}
+
+	started := make(chan error, 2)
+	server.udp.NotifyStartedFunc = func() { started <- nil }
+	server.tcp.NotifyStartedFunc = func() { started <- nil }
+
+	go func() {
+		if err := server.udp.ActivateAndServe(); err != nil {
+			started <- err
+		}
+	}()
+
+	go func() {
+		if err := server.tcp.ActivateAndServe(); err != nil {
+			started <- err
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		if err := <-started; err != nil {
+			server.Close()
+			return nil, fmt.Errorf("could not start stub DNS server: %v", err)
+		}
+	}
+
+	return server, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	udpErr := s.udp.Shutdown()
+	tcpErr := s.tcp.Shutdown()
+
+	if udpErr != nil {
+		return udpErr
+	}
+
+	return tcpErr
+}
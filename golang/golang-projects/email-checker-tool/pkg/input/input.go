@@ -0,0 +1,255 @@
+package input
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Entry is a normalized domain, or an email address when Local is set.
+type Entry struct {
+	Local  string
+	Domain string
+}
+
+// String returns the address of the entry, or its domain.
+func (e Entry) String() string {
+	if e.Local == "" {
+		return e.Domain
+	}
+
+	return e.Local + "@" + e.Domain
+}
+
+// Normalize cleans up a domain or an email address the way people write them:
+// surrounding spaces, a mailto: prefix, a "Name <address>" form, uppercase letters,
+// the trailing dot and Unicode domains, which are converted to punycode.
+// The local part of an address is case sensitive and kept as is.
+func Normalize(raw string) (Entry, error) {
+	// A byte order mark is left by some editors at the start of the files
+	entry := strings.TrimSpace(strings.TrimPrefix(raw, "\ufeff"))
+
+	if len(entry) >= 7 && strings.EqualFold(entry[:7], "mailto:") {
+		entry = entry[7:]
+
+		// Drops the headers of the URI, e.g. ?subject=...
+		if i := strings.IndexByte(entry, '?'); i >= 0 {
+			entry = entry[:i]
+		}
+
+		if unescaped, err := url.PathUnescape(entry); err == nil {
+			entry = unescaped
+		}
+	}
+
+	if strings.ContainsAny(entry, "<>") {
+		address, err := mail.ParseAddress(entry)
+
+		// The parser rejects what the rest of Normalize fixes, e.g. the trailing dot of the domain
+		start, end := strings.LastIndexByte(entry, '<'), strings.LastIndexByte(entry, '>')
+
+		switch {
+		case err == nil:
+			entry = address.Address
+		case start >= 0 && end > start:
+			entry = strings.TrimSpace(entry[start+1 : end])
+		default:
+			return Entry{}, fmt.Errorf("invalid address %q: %v", raw, err)
+		}
+	}
+
+	var normalized Entry
+	domain := entry
+
+	if i := strings.LastIndexByte(entry, '@'); i >= 0 {
+		normalized.Local, domain = entry[:i], entry[i+1:]
+
+		if normalized.Local == "" {
+			return Entry{}, fmt.Errorf("invalid address %q: the local part is empty", raw)
+		}
+	}
+
+	domain = strings.TrimSuffix(domain, ".")
+	if domain == "" {
+		return Entry{}, fmt.Errorf("invalid domain %q: it is empty", raw)
+	}
+
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid domain %q: %v", domain, err)
+	}
+
+	for _, label := range strings.Split(ascii, ".") {
+		if label == "" {
+			return Entry{}, fmt.Errorf("invalid domain %q: empty label", domain)
+		}
+	}
+
+	normalized.Domain = ascii
+	return normalized, nil
+}
+
+// Reader reads the domains and addresses to check, one per line or from a CSV column.
+// Blank lines and comments starting with '#' are skipped, and an entry is only
+// returned the first time it is seen, across every input read by the same Reader.
+type Reader struct {
+	// KeepAddresses keeps the email addresses, they are reduced to their domain otherwise.
+	KeepAddresses bool
+	// Column reads the input as CSV and takes the entries from this column, either
+	// the name of a header or a position starting at 1. Empty reads one entry per line.
+	Column string
+	// Header skips the first row of the CSV input. It is implied when Column is a name.
+	Header bool
+	// OnInvalid is called with the entries that cannot be normalized, which are skipped.
+	OnInvalid func(source string, line int, entry string, err error)
+
+	seen map[string]bool
+}
+
+// Read passes every new entry of src to fn, and stops at the first error fn returns.
+// source names the input in the errors, e.g. the path of the file.
+func (r *Reader) Read(src io.Reader, source string, fn func(entry string) error) error {
+	if r.seen == nil {
+		r.seen = make(map[string]bool)
+	}
+
+	if r.Column != "" {
+		return r.readCSV(src, source, fn)
+	}
+
+	scanner := bufio.NewScanner(src)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		if err := r.add(source, line, stripComment(scanner.Text()), fn); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %v", source, err)
+	}
+
+	return nil
+}
+
+// readCSV passes the entries of the column of every row to fn
+func (r *Reader) readCSV(src io.Reader, source string, fn func(entry string) error) error {
+	reader := csv.NewReader(src)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	column, err := strconv.Atoi(r.Column)
+	byName := err != nil
+
+	if !byName && column < 1 {
+		return fmt.Errorf("invalid CSV column %s, the first column is 1", r.Column)
+	}
+
+	// Positions start at 1 for the people, at 0 for the code
+	column--
+
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", source, err)
+		}
+
+		if row == 0 && byName {
+			if column = headerIndex(record, r.Column); column < 0 {
+				return fmt.Errorf("%s: no column named %q in the header", source, r.Column)
+			}
+
+			continue
+		}
+
+		if row == 0 && r.Header {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		if column >= len(record) {
+			r.invalid(source, line, strings.Join(record, ","), fmt.Errorf("the row has no column %d", column+1))
+			continue
+		}
+
+		if err := r.add(source, line, record[column], fn); err != nil {
+			return err
+		}
+	}
+}
+
+// add normalizes the entry and passes it to fn unless it is blank, invalid or already seen
+func (r *Reader) add(source string, line int, raw string, fn func(entry string) error) error {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+
+	entry, err := Normalize(raw)
+	if err != nil {
+		r.invalid(source, line, raw, err)
+		return nil
+	}
+
+	if !r.KeepAddresses {
+		entry.Local = ""
+	}
+
+	key := entry.String()
+	if r.seen[key] {
+		return nil
+	}
+	r.seen[key] = true
+
+	return fn(key)
+}
+
+// invalid reports an entry that was skipped
+func (r *Reader) invalid(source string, line int, entry string, err error) {
+	if r.OnInvalid != nil {
+		r.OnInvalid(source, line, entry, err)
+	}
+}
+
+// stripComment removes a comment that starts the line or follows a space
+func stripComment(line string) string {
+	if strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(line, "\ufeff")), "#") {
+		return ""
+	}
+
+	if i := strings.Index(line, " #"); i >= 0 {
+		line = line[:i]
+	}
+
+	if i := strings.Index(line, "\t#"); i >= 0 {
+		line = line[:i]
+	}
+
+	return line
+}
+
+// headerIndex returns the position of the column named name, or -1
+func headerIndex(header []string, name string) int {
+	for i, column := range header {
+		if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")), name) {
+			return i
+		}
+	}
+
+	return -1
+}
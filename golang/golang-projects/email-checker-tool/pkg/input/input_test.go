@@ -0,0 +1,235 @@
+package input
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw   string
+		entry Entry
+		err   bool
+	}{
+		{raw: "example.test", entry: Entry{Domain: "example.test"}},
+		{raw: "  Example.TEST.  ", entry: Entry{Domain: "example.test"}},
+		{raw: "\ufeffexample.test", entry: Entry{Domain: "example.test"}},
+		// The local part is case sensitive, the domain is not
+		{raw: "Alice@Example.Test", entry: Entry{Local: "Alice", Domain: "example.test"}},
+		{raw: "mailto:alice@example.test", entry: Entry{Local: "alice", Domain: "example.test"}},
+		{raw: "MAILTO:alice@example.test?subject=Hello%20there&cc=bob@example.test", entry: Entry{Local: "alice", Domain: "example.test"}},
+		{raw: "mailto:alice%2Bnews@example.test", entry: Entry{Local: "alice+news", Domain: "example.test"}},
+		{raw: "Alice Liddell <alice@example.test>", entry: Entry{Local: "alice", Domain: "example.test"}},
+		{raw: `"Liddell, Alice" <alice@example.test.>`, entry: Entry{Local: "alice", Domain: "example.test"}},
+		{raw: "<alice@example.test>", entry: Entry{Local: "alice", Domain: "example.test"}},
+		{raw: "bücher.example.test", entry: Entry{Domain: "xn--bcher-kva.example.test"}},
+		{raw: "Info@BÜCHER.example.test", entry: Entry{Local: "Info", Domain: "xn--bcher-kva.example.test"}},
+		{raw: "", err: true},
+		{raw: ".", err: true},
+		{raw: "@example.test", err: true},
+		{raw: "alice@", err: true},
+		{raw: "bad..test", err: true},
+		{raw: ".example.test", err: true},
+		{raw: "Alice <alice@example.test", err: true},
+		{raw: "exa mple.test", err: true},
+	}
+
+	for _, test := range tests {
+		entry, err := Normalize(test.raw)
+
+		if test.err {
+			if err == nil {
+				t.Errorf("Normalize(%q) = %+v, want an error", test.raw, entry)
+			}
+			continue
+		}
+
+		if err != nil || entry != test.entry {
+			t.Errorf("Normalize(%q) = %+v, %v, want %+v", test.raw, entry, err, test.entry)
+		}
+	}
+}
+
+func TestEntryString(t *testing.T) {
+	if s := (Entry{Domain: "example.test"}).String(); s != "example.test" {
+		t.Errorf("String() = %q, want example.test", s)
+	}
+
+	if s := (Entry{Local: "alice", Domain: "example.test"}).String(); s != "alice@example.test" {
+		t.Errorf("String() = %q, want alice@example.test", s)
+	}
+}
+
+// invalidEntry is an entry the Reader skipped
+type invalidEntry struct {
+	source string
+	line   int
+	entry  string
+}
+
+// readAll reads every input in turn with the reader and returns the entries and the skipped ones
+func readAll(t *testing.T, reader *Reader, inputs ...string) ([]string, []invalidEntry) {
+	t.Helper()
+
+	var entries []string
+	var invalid []invalidEntry
+
+	reader.OnInvalid = func(source string, line int, entry string, err error) {
+		invalid = append(invalid, invalidEntry{source: source, line: line, entry: entry})
+	}
+
+	for i, input := range inputs {
+		err := reader.Read(strings.NewReader(input), fmt.Sprintf("input%d", i+1), func(entry string) error {
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Read(input%d) = %v", i+1, err)
+		}
+	}
+
+	return entries, invalid
+}
+
+func TestReaderLines(t *testing.T) {
+	tests := []struct {
+		name          string
+		keepAddresses bool
+		inputs        []string
+		entries       []string
+		invalid       []invalidEntry
+	}{
+		{
+			name:    "comments and blank lines",
+			inputs:  []string{"# customers\n\n  SECURE.example.test.  # main\n\t\nnospf.example.test\t# second\n  # indented comment\n"},
+			entries: []string{"secure.example.test", "nospf.example.test"},
+		},
+		{
+			name:    "a # inside an entry",
+			inputs:  []string{"example.test#anchor\n"},
+			invalid: []invalidEntry{{source: "input1", line: 1, entry: "example.test#anchor"}},
+		},
+		{
+			name:    "dedupe across the inputs",
+			inputs:  []string{"secure.example.test\nSecure.Example.Test.\n", "nospf.example.test\nsecure.example.test\n"},
+			entries: []string{"secure.example.test", "nospf.example.test"},
+		},
+		{
+			name:    "addresses reduced to their domain",
+			inputs:  []string{"alice@secure.example.test\nbob@secure.example.test\nsecure.example.test\n"},
+			entries: []string{"secure.example.test"},
+		},
+		{
+			name:          "addresses kept",
+			keepAddresses: true,
+			inputs:        []string{"alice@secure.example.test\nmailto:alice@SECURE.example.test\nAlice@secure.example.test\nsecure.example.test\n"},
+			entries:       []string{"alice@secure.example.test", "Alice@secure.example.test", "secure.example.test"},
+		},
+		{
+			name:    "invalid entries are skipped",
+			inputs:  []string{"secure.example.test\nbad..test\n@example.test\nnospf.example.test\n"},
+			entries: []string{"secure.example.test", "nospf.example.test"},
+			invalid: []invalidEntry{{source: "input1", line: 2, entry: "bad..test"}, {source: "input1", line: 3, entry: "@example.test"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, invalid := readAll(t, &Reader{KeepAddresses: test.keepAddresses}, test.inputs...)
+
+			if !reflect.DeepEqual(entries, test.entries) {
+				t.Errorf("Read() = %q, want %q", entries, test.entries)
+			}
+
+			if !reflect.DeepEqual(invalid, test.invalid) {
+				t.Errorf("OnInvalid() got %+v, want %+v", invalid, test.invalid)
+			}
+		})
+	}
+}
+
+func TestReaderCSV(t *testing.T) {
+	const contacts = "name,Email,company\n" +
+		"Alice,alice@secure.example.test,Secure\n" +
+		"# a comment\n" +
+		"Bob,\"Bob <bob@nospf.example.test>\",NoSPF\n" +
+		"Carol,carol@bad..test,Bad\n" +
+		"Dave\n" +
+		"Eve,eve@secure.example.test,Secure\n"
+
+	tests := []struct {
+		name    string
+		column  string
+		header  bool
+		entries []string
+		invalid []invalidEntry
+		err     bool
+	}{
+		{
+			name:    "by name",
+			column:  "email",
+			entries: []string{"secure.example.test", "nospf.example.test"},
+			invalid: []invalidEntry{{source: "input1", line: 5, entry: "carol@bad..test"}, {source: "input1", line: 6, entry: "Dave"}},
+		},
+		{
+			name:    "by position with the header",
+			column:  "2",
+			header:  true,
+			entries: []string{"secure.example.test", "nospf.example.test"},
+			invalid: []invalidEntry{{source: "input1", line: 5, entry: "carol@bad..test"}, {source: "input1", line: 6, entry: "Dave"}},
+		},
+		{
+			// The header is then read as an entry, and a single label passes for a domain
+			name:    "by position without the header",
+			column:  "2",
+			entries: []string{"email", "secure.example.test", "nospf.example.test"},
+			invalid: []invalidEntry{{source: "input1", line: 5, entry: "carol@bad..test"}, {source: "input1", line: 6, entry: "Dave"}},
+		},
+		{name: "unknown name", column: "phone", err: true},
+		{name: "position 0", column: "0", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := &Reader{Column: test.column, Header: test.header}
+
+			if test.err {
+				err := reader.Read(strings.NewReader(contacts), "input1", func(string) error { return nil })
+				if err == nil {
+					t.Errorf("Read(column %s) = nil, want an error", test.column)
+				}
+				return
+			}
+
+			entries, invalid := readAll(t, reader, contacts)
+
+			if !reflect.DeepEqual(entries, test.entries) {
+				t.Errorf("Read(column %s) = %q, want %q", test.column, entries, test.entries)
+			}
+
+			if !reflect.DeepEqual(invalid, test.invalid) {
+				t.Errorf("OnInvalid() got %+v, want %+v", invalid, test.invalid)
+			}
+		})
+	}
+}
+
+func TestReaderStops(t *testing.T) {
+	stop := errors.New("stop")
+	var entries []string
+
+	err := (&Reader{}).Read(strings.NewReader("a.test\nb.test\nc.test\n"), "input", func(entry string) error {
+		entries = append(entries, entry)
+		if len(entries) == 2 {
+			return stop
+		}
+		return nil
+	})
+
+	if !errors.Is(err, stop) || len(entries) != 2 {
+		t.Errorf("Read() = %v after %q, want the error of fn after 2 entries", err, entries)
+	}
+}
@@ -0,0 +1,151 @@
+package mailbox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Limits of RFC 5321 section 4.5.3.1
+const (
+	maxLocalLength   = 64
+	maxDomainLength  = 253
+	maxAddressLength = 254
+	maxLabelLength   = 63
+)
+
+// Address is a syntactically valid email address.
+type Address struct {
+	Local  string
+	Domain string
+}
+
+// String returns the address as local@domain.
+func (a *Address) String() string {
+	return a.Local + "@" + a.Domain
+}
+
+// ParseAddress validates the syntax of a bare address like "user@example.com".
+// Display names, comments and address literals are not accepted.
+func ParseAddress(address string) (*Address, error) {
+	if len(address) > maxAddressLength {
+		return nil, fmt.Errorf("the address is longer than %d characters", maxAddressLength)
+	}
+
+	// The local part can be quoted and contain an @, the domain cannot
+	i := strings.LastIndexByte(address, '@')
+	if i < 0 {
+		return nil, fmt.Errorf("the address has no @")
+	}
+
+	local, domain := address[:i], strings.TrimSuffix(address[i+1:], ".")
+
+	if err := checkLocal(local); err != nil {
+		return nil, err
+	}
+
+	if err := checkDomain(domain); err != nil {
+		return nil, err
+	}
+
+	// The domain is case insensitive, the local part is left as is
+	return &Address{Local: local, Domain: strings.ToLower(domain)}, nil
+}
+
+// checkLocal validates a dot-atom or a quoted string
+func checkLocal(local string) error {
+	if local == "" {
+		return fmt.Errorf("the local part is empty")
+	}
+
+	if len(local) > maxLocalLength {
+		return fmt.Errorf("the local part is longer than %d characters", maxLocalLength)
+	}
+
+	if strings.HasPrefix(local, `"`) {
+		return checkQuoted(local)
+	}
+
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return fmt.Errorf("the local part %q has an empty dot-separated part", local)
+		}
+
+		for _, r := range atom {
+			if !isAtext(r) {
+				return fmt.Errorf("the local part %q contains %q, which must be quoted", local, r)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkQuoted validates a quoted local part like "john doe"
+func checkQuoted(local string) error {
+	if len(local) < 2 || !strings.HasSuffix(local, `"`) {
+		return fmt.Errorf("the quoted local part %s is not closed", local)
+	}
+
+	escaped := false
+	for _, r := range local[1 : len(local)-1] {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return fmt.Errorf("the quoted local part %s contains an unescaped quote", local)
+		case r < 32 || r == 127:
+			return fmt.Errorf("the quoted local part %s contains a control character", local)
+		}
+	}
+
+	if escaped {
+		return fmt.Errorf("the quoted local part %s ends with a backslash", local)
+	}
+
+	return nil
+}
+
+// checkDomain validates the domain as a list of letter-digit-hyphen labels
+func checkDomain(domain string) error {
+	if domain == "" {
+		return fmt.Errorf("the domain is empty")
+	}
+
+	if len(domain) > maxDomainLength {
+		return fmt.Errorf("the domain is longer than %d characters", maxDomainLength)
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return fmt.Errorf("the domain %q is not fully qualified", domain)
+	}
+
+	for _, label := range labels {
+		if label == "" || len(label) > maxLabelLength {
+			return fmt.Errorf("the domain %q has a label of invalid length", domain)
+		}
+
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("the domain %q has a label starting or ending with a hyphen", domain)
+		}
+
+		for _, r := range label {
+			if !isLetterDigit(r) && r != '-' {
+				return fmt.Errorf("the domain %q contains %q", domain, r)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isAtext tells if the character can appear unquoted in the local part (RFC 5322 section 3.2.3)
+func isAtext(r rune) bool {
+	return isLetterDigit(r) || strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r)
+}
+
+func isLetterDigit(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
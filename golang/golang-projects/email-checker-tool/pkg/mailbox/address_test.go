@@ -0,0 +1,54 @@
+package mailbox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+		err     string
+	}{
+		{address: "alice@example.test", want: "alice@example.test"},
+		{address: "Alice.Smith@Example.TEST.", want: "Alice.Smith@example.test"},
+		{address: "alice+tag@mail.example.test", want: "alice+tag@mail.example.test"},
+		{address: `"john doe"@example.test`, want: `"john doe"@example.test`},
+		{address: `"a@b"@example.test`, want: `"a@b"@example.test`},
+		{address: `"quoted \" quote"@example.test`, want: `"quoted \" quote"@example.test`},
+		{address: "example.test", err: "the address has no @"},
+		{address: "@example.test", err: "the local part is empty"},
+		{address: "alice@", err: "the domain is empty"},
+		{address: "alice..smith@example.test", err: `the local part "alice..smith" has an empty dot-separated part`},
+		{address: "john doe@example.test", err: `the local part "john doe" contains ' ', which must be quoted`},
+		{address: `"john@example.test`, err: `the quoted local part "john is not closed`},
+		{address: `"a"b"@example.test`, err: `the quoted local part "a"b" contains an unescaped quote`},
+		{address: strings.Repeat("a", 65) + "@example.test", err: "the local part is longer than 64 characters"},
+		{address: "alice@localhost", err: `the domain "localhost" is not fully qualified`},
+		{address: "alice@-example.test", err: `the domain "-example.test" has a label starting or ending with a hyphen`},
+		{address: "alice@example..test", err: `the domain "example..test" has a label of invalid length`},
+		{address: "alice@exa_mple.test", err: `the domain "exa_mple.test" contains '_'`},
+		{address: "alice@" + strings.Repeat("a", 250) + ".test", err: "the address is longer than 254 characters"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			address, err := ParseAddress(test.address)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("ParseAddress(%q) = %v, want %s", test.address, err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseAddress(%q) = %v", test.address, err)
+			}
+
+			if address.String() != test.want {
+				t.Errorf("ParseAddress(%q) = %s, want %s", test.address, address, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package mailbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/smtpprobe"
+)
+
+// Status of a verified mailbox
+const (
+	// StatusDeliverable means that the MX host accepted the recipient.
+	StatusDeliverable = "deliverable"
+	// StatusUndeliverable means that the address is invalid, the domain does not
+	// accept mail or the MX host rejected the recipient for good.
+	StatusUndeliverable = "undeliverable"
+	// StatusUnknown means that no MX host gave a definitive answer, e.g. because of
+	// greylisting, a temporary failure or a catch-all domain.
+	StatusUnknown = "unknown"
+)
+
+// Result is the outcome of verifying a single address.
+type Result struct {
+	Address string `json:"address"`
+	Status  string `json:"status"`
+	// Reason explains the status.
+	Reason string `json:"reason,omitempty"`
+	// MX is the host that answered, Reply its answer to RCPT TO.
+	MX    string           `json:"mx,omitempty"`
+	Reply *smtpprobe.Reply `json:"reply,omitempty"`
+	// CatchAll tells that the MX host also accepted a random local part.
+	CatchAll bool `json:"catchAll"`
+}
+
+// Verifier checks whether mailboxes exist with a RCPT TO dialogue.
+type Verifier struct {
+	Prober *smtpprobe.Prober
+	// From is the MAIL FROM address, the null reverse-path when empty.
+	From string
+}
+
+// Invalid returns the result of an address whose syntax is invalid.
+func Invalid(address string, err error) *Result {
+	return &Result{
+		Address: address,
+		Status:  StatusUndeliverable,
+		Reason:  "invalid syntax: " + err.Error(),
+	}
+}
+
+// Verify asks the MX hosts, in preference order, whether they accept the address.
+// When the domain has no MX record, the domain itself is used as the implicit MX
+// (RFC 5321 section 5.1). The next host is only tried when a host cannot be reached.
+func (v *Verifier) Verify(ctx context.Context, address *Address, mxRecords []*net.MX) *Result {
+	result := &Result{Address: address.String(), Status: StatusUnknown}
+
+	hosts := make([]string, 0, len(mxRecords))
+	for _, mx := range mxRecords {
+		host := strings.TrimSuffix(mx.Host, ".")
+
+		// A null MX (RFC 7505) tells that the domain does not accept mail
+		if host == "" {
+			result.Status = StatusUndeliverable
+			result.Reason = "the domain publishes a null MX and does not accept mail"
+			return result
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	if len(hosts) == 0 {
+		hosts = append(hosts, address.Domain)
+	}
+
+	// A random local part tells if the host accepts every recipient
+	probe := randomLocal() + "@" + address.Domain
+
+	var failures []string
+	for _, host := range hosts {
+		replies, err := v.Prober.Recipients(ctx, host, v.From, []string{result.Address, probe})
+		if len(replies) == 0 {
+			failures = append(failures, fmt.Sprintf("%s: %v", host, err))
+			continue
+		}
+
+		result.MX = host
+		result.Reply = &replies[0]
+		classify(result, replies)
+		return result
+	}
+
+	result.Reason = "no MX host answered: " + strings.Join(failures, "; ")
+	return result
+}
+
+// classify sets the status from the replies to the address and to the random probe
+func classify(result *Result, replies []smtpprobe.Reply) {
+	reply := replies[0]
+
+	switch {
+	case reply.Accepted():
+		result.Status = StatusDeliverable
+
+		if len(replies) > 1 && replies[1].Accepted() {
+			result.CatchAll = true
+			result.Status = StatusUnknown
+			result.Reason = "the domain is catch-all, it accepts any recipient"
+		}
+
+	case reply.Permanent():
+		result.Status = StatusUndeliverable
+		result.Reason = fmt.Sprintf("the MX host rejected the recipient: %d %s", reply.Code, reply.Message)
+
+	default:
+		// 4xx replies are usually greylisting or rate limiting
+		result.Status = StatusUnknown
+		result.Reason = fmt.Sprintf("the MX host deferred the recipient: %d %s", reply.Code, reply.Message)
+	}
+}
+
+// randomLocal returns a local part that is very unlikely to exist
+func randomLocal() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "no-such-user-7f3a91c2"
+	}
+
+	return "no-such-user-" + hex.EncodeToString(buf)
+}
@@ -0,0 +1,118 @@
+package mailbox
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/smtpprobe"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/smtptest"
+)
+
+// loopbackResolver sends every host but "down.example.test" to the loopback address
+type loopbackResolver struct{}
+
+func (loopbackResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	if host == "down.example.test" {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return []net.IP{net.IPv4(127, 0, 0, 1)}, nil
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    smtptest.Config
+		mxRecords []*net.MX
+		status    string
+		catchAll  bool
+		mx        string
+		reason    string
+	}{
+		{
+			name:      "deliverable",
+			config:    smtptest.Config{Mailboxes: []string{"alice@example.test"}},
+			mxRecords: []*net.MX{{Host: "mx.example.test.", Pref: 10}},
+			status:    StatusDeliverable,
+			mx:        "mx.example.test",
+		},
+		{
+			name:      "undeliverable",
+			config:    smtptest.Config{Mailboxes: []string{"bob@example.test"}},
+			mxRecords: []*net.MX{{Host: "mx.example.test.", Pref: 10}},
+			status:    StatusUndeliverable,
+			mx:        "mx.example.test",
+			reason:    "the MX host rejected the recipient: 550 5.1.1 No such user here",
+		},
+		{
+			name:      "catch-all",
+			config:    smtptest.Config{CatchAll: true},
+			mxRecords: []*net.MX{{Host: "mx.example.test.", Pref: 10}},
+			status:    StatusUnknown,
+			catchAll:  true,
+			mx:        "mx.example.test",
+			reason:    "the domain is catch-all, it accepts any recipient",
+		},
+		{
+			name:   "implicit MX",
+			config: smtptest.Config{Mailboxes: []string{"alice@example.test"}},
+			status: StatusDeliverable,
+			mx:     "example.test",
+		},
+		{
+			name:      "next MX host",
+			config:    smtptest.Config{Mailboxes: []string{"alice@example.test"}},
+			mxRecords: []*net.MX{{Host: "down.example.test.", Pref: 10}, {Host: "mx.example.test.", Pref: 20}},
+			status:    StatusDeliverable,
+			mx:        "mx.example.test",
+		},
+		{
+			name:      "null MX",
+			mxRecords: []*net.MX{{Host: ".", Pref: 0}},
+			status:    StatusUndeliverable,
+			reason:    "the domain publishes a null MX and does not accept mail",
+		},
+		{
+			name:      "unreachable",
+			mxRecords: []*net.MX{{Host: "down.example.test.", Pref: 10}},
+			status:    StatusUnknown,
+			reason:    "no MX host answered: down.example.test: connect: lookup down.example.test: no such host",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, err := smtptest.Start("127.0.0.1:0", test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			_, port, _ := net.SplitHostPort(server.Addr())
+			verifier := &Verifier{Prober: &smtpprobe.Prober{Port: port, Timeout: 2 * time.Second, Resolver: loopbackResolver{}}}
+
+			address := &Address{Local: "alice", Domain: "example.test"}
+			result := verifier.Verify(context.Background(), address, test.mxRecords)
+
+			if result.Address != "alice@example.test" || result.Status != test.status || result.CatchAll != test.catchAll || result.MX != test.mx || result.Reason != test.reason {
+				t.Errorf("Verify() = %+v, want %s, catch-all %t, MX %q, reason %q", result, test.status, test.catchAll, test.mx, test.reason)
+			}
+
+			if test.mx != "" && (result.Reply == nil || !strings.HasPrefix(result.Reply.Message, "2.1.5") && !strings.HasPrefix(result.Reply.Message, "5.1.1")) {
+				t.Errorf("Verify() reply = %+v, want the reply to the address", result.Reply)
+			}
+		})
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	_, err := ParseAddress("alice")
+	result := Invalid("alice", err)
+
+	if result.Status != StatusUndeliverable || result.Reason != "invalid syntax: the address has no @" {
+		t.Errorf("Invalid() = %+v, want an undeliverable address", result)
+	}
+}
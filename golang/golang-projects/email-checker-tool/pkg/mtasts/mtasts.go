@@ -0,0 +1,192 @@
+package mtasts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// maxPolicySize bounds the size of a policy file, which only has a few lines
+const maxPolicySize = 64 * 1024
+
+// Resolver looks up the _mta-sts TXT record.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Checker looks up and validates the MTA-STS policy of a domain.
+type Checker struct {
+	Resolver Resolver
+	// Client fetches the policy files, http.DefaultClient when nil. It can be
+	// replaced to fetch them from a local server. Redirects are never followed.
+	Client *http.Client
+}
+
+// Report is the outcome of checking the MTA-STS policy of a domain.
+type Report struct {
+	Record    *Record `json:"record,omitempty"`
+	PolicyURL string  `json:"policyUrl"`
+	Policy    *Policy `json:"policy,omitempty"`
+	// UnmatchedMX are the MX hosts that no mx pattern of the policy matches.
+	UnmatchedMX []string `json:"unmatchedMx,omitempty"`
+	Errors      []string `json:"errors,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// PolicyURL returns the URL the policy of the domain is published at.
+func PolicyURL(domain string) string {
+	return "https://mta-sts." + strings.TrimSuffix(domain, ".") + "/.well-known/mta-sts.txt"
+}
+
+// Check looks up the _mta-sts record of the domain, fetches its policy and verifies
+// that it covers every host of mxHosts. It returns nil when the domain has no record.
+func (c *Checker) Check(ctx context.Context, domain string, mxHosts []string) *Report {
+	domain = strings.TrimSuffix(domain, ".")
+	name := "_mta-sts." + domain
+
+	txtRecords, err := c.Resolver.LookupTXT(ctx, name)
+	if err != nil {
+		if resolver.IsNotFound(err) {
+			return nil
+		}
+
+		return &Report{PolicyURL: PolicyURL(domain), Errors: []string{fmt.Sprintf("%s: %v", name, err)}}
+	}
+
+	var stsRecords []string
+	for _, txt := range txtRecords {
+		if IsRecord(txt) {
+			stsRecords = append(stsRecords, txt)
+		}
+	}
+
+	if len(stsRecords) == 0 {
+		return nil
+	}
+
+	report := &Report{PolicyURL: PolicyURL(domain)}
+
+	// Senders ignore the policy when there is more than one record
+	if len(stsRecords) > 1 {
+		report.errorf("%d MTA-STS records found, senders ignore the policy when there is more than one", len(stsRecords))
+	}
+
+	if report.Record, err = ParseRecord(stsRecords[0]); err != nil {
+		report.errorf("%v", err)
+	}
+
+	body, err := c.fetch(ctx, report)
+	if err != nil {
+		report.errorf("could not fetch the policy: %v", err)
+		return report
+	}
+
+	if report.Policy, err = ParsePolicy(body); err != nil {
+		report.errorf("%v", err)
+		return report
+	}
+
+	report.lintPolicy(mxHosts)
+	return report
+}
+
+func (r *Report) errorf(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r *Report) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// fetch downloads the policy file, following the rules of RFC 8461 section 3.3
+func (c *Checker) fetch(ctx context.Context, report *Report) (string, error) {
+	client := http.DefaultClient
+	if c.Client != nil {
+		client = c.Client
+	}
+
+	// A redirect must not be followed, the policy is only trusted from its own host
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, report.PolicyURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := noRedirect.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s answered %s, expected 200 OK", report.PolicyURL, response.Status)
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(response.Header.Get("Content-Type")); err != nil || mediaType != "text/plain" {
+		report.warnf("the policy is served as %q, senders expect text/plain", response.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxPolicySize+1))
+	if err != nil {
+		return "", err
+	}
+
+	if len(body) > maxPolicySize {
+		return "", fmt.Errorf("the policy is larger than %d bytes", maxPolicySize)
+	}
+
+	return string(body), nil
+}
+
+// lintPolicy compares the policy with the MX hosts and reports what senders will not like
+func (r *Report) lintPolicy(mxHosts []string) {
+	policy := r.Policy
+
+	for _, host := range mxHosts {
+		if !policy.Matches(host) {
+			r.UnmatchedMX = append(r.UnmatchedMX, strings.TrimSuffix(host, "."))
+		}
+	}
+
+	if len(r.UnmatchedMX) > 0 && policy.Mode != ModeNone {
+		message := "no mx pattern of the policy matches " + strings.Join(r.UnmatchedMX, ", ")
+
+		if policy.Mode == ModeEnforce {
+			r.errorf("%s, senders refuse to deliver to them", message)
+		} else {
+			r.warnf("%s, senders report the failures but still deliver", message)
+		}
+	}
+
+	for _, pattern := range policy.MX {
+		used := false
+		for _, host := range mxHosts {
+			used = used || MatchMX(pattern, host)
+		}
+
+		if !used && len(mxHosts) > 0 {
+			r.warnf("mx pattern %s matches none of the MX hosts", pattern)
+		}
+	}
+
+	switch policy.Mode {
+	case ModeTesting:
+		r.warnf("mode testing only reports the failures, use enforce once the TLS reports are clean")
+	case ModeNone:
+		r.warnf("mode none disables the policy")
+	}
+
+	// A short max_age lets an attacker strip the policy from senders that have not seen it for a while
+	if policy.Mode == ModeEnforce && policy.MaxAge < 86400 {
+		r.warnf("max_age=%d is shorter than a day, a week or more is recommended", policy.MaxAge)
+	}
+}
@@ -0,0 +1,159 @@
+package mtasts
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Modes of an MTA-STS policy
+const (
+	ModeEnforce = "enforce"
+	ModeTesting = "testing"
+	ModeNone    = "none"
+)
+
+// MaxMaxAge is the largest max_age a policy can have, about one year (RFC 8461 section 3.2)
+const MaxMaxAge = 31557600
+
+// idPattern is the syntax of the id of the TXT record, 1 to 32 letters and digits
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9]{1,32}$`)
+
+// Record is a parsed _mta-sts TXT record (RFC 8461 section 3.1).
+type Record struct {
+	Raw string `json:"raw"`
+	ID  string `json:"id"`
+}
+
+// Policy is a parsed MTA-STS policy file (RFC 8461 section 3.2).
+type Policy struct {
+	Version string   `json:"version"`
+	Mode    string   `json:"mode"`
+	MX      []string `json:"mx,omitempty"`
+	MaxAge  int      `json:"maxAge"`
+}
+
+// IsRecord tells if the TXT record is an MTA-STS record.
+func IsRecord(txt string) bool {
+	first := strings.TrimSpace(strings.SplitN(txt, ";", 2)[0])
+	return strings.ReplaceAll(first, " ", "") == "v=STSv1"
+}
+
+// ParseRecord parses an MTA-STS TXT record.
+func ParseRecord(txt string) (*Record, error) {
+	record := &Record{Raw: txt}
+	seen := make(map[string]bool)
+
+	for i, field := range strings.Split(txt, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		j := strings.IndexByte(field, '=')
+		if j < 0 {
+			return nil, fmt.Errorf("mta-sts: invalid field %q", field)
+		}
+
+		name, value := strings.TrimSpace(field[:j]), strings.TrimSpace(field[j+1:])
+		if seen[name] {
+			return nil, fmt.Errorf("mta-sts: field %s appears more than once", name)
+		}
+		seen[name] = true
+
+		switch {
+		case i == 0 && (name != "v" || value != "STSv1"):
+			return nil, fmt.Errorf("mta-sts: the record must start with v=STSv1")
+		case name == "id":
+			if !idPattern.MatchString(value) {
+				return nil, fmt.Errorf("mta-sts: invalid id=%s, expected 1 to 32 letters and digits", value)
+			}
+			record.ID = value
+		}
+	}
+
+	if record.ID == "" {
+		return nil, fmt.Errorf("mta-sts: the id field is missing")
+	}
+
+	return record, nil
+}
+
+// ParsePolicy parses the body of a policy file. Unknown keys are ignored, as the RFC requires.
+func ParsePolicy(body string) (*Policy, error) {
+	policy := &Policy{MaxAge: -1}
+	scanner := bufio.NewScanner(strings.NewReader(body))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("mta-sts: invalid policy line %q", line)
+		}
+
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+
+		switch key {
+		case "version":
+			policy.Version = value
+		case "mode":
+			policy.Mode = value
+		case "mx":
+			policy.MX = append(policy.MX, strings.ToLower(value))
+		case "max_age":
+			maxAge, err := strconv.Atoi(value)
+			if err != nil || maxAge < 0 || maxAge > MaxMaxAge {
+				return nil, fmt.Errorf("mta-sts: invalid max_age %q, expected 0 to %d seconds", value, MaxMaxAge)
+			}
+			policy.MaxAge = maxAge
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case policy.Version != "STSv1":
+		return nil, fmt.Errorf("mta-sts: invalid policy version %q, expected STSv1", policy.Version)
+	case policy.Mode != ModeEnforce && policy.Mode != ModeTesting && policy.Mode != ModeNone:
+		return nil, fmt.Errorf("mta-sts: invalid mode %q, expected enforce, testing or none", policy.Mode)
+	case policy.MaxAge < 0:
+		return nil, fmt.Errorf("mta-sts: max_age is missing")
+	case policy.Mode != ModeNone && len(policy.MX) == 0:
+		return nil, fmt.Errorf("mta-sts: mode %s needs at least one mx", policy.Mode)
+	}
+
+	return policy, nil
+}
+
+// Matches tells if the MX host matches one of the mx patterns of the policy.
+func (p *Policy) Matches(host string) bool {
+	for _, pattern := range p.MX {
+		if MatchMX(pattern, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchMX tells if the host matches the pattern, where a leading "*." matches
+// exactly one label (RFC 8461 section 4.1).
+func MatchMX(pattern, host string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if !strings.HasPrefix(pattern, "*.") {
+		return pattern == host
+	}
+
+	i := strings.IndexByte(host, '.')
+	return i > 0 && host[i+1:] == pattern[2:]
+}
@@ -0,0 +1,104 @@
+package mtasts
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRecord(t *testing.T) {
+	tests := []struct {
+		txt string
+		id  string
+		err string
+	}{
+		{txt: "v=STSv1; id=20240101T000000", id: "20240101T000000"},
+		{txt: "v=STSv1;id=1;", id: "1"},
+		{txt: "v=STSv1; id=abc; ext=value", id: "abc"},
+		{txt: "id=1; v=STSv1", err: "mta-sts: the record must start with v=STSv1"},
+		{txt: "v=STSv1", err: "mta-sts: the id field is missing"},
+		{txt: "v=STSv1; id=missing-policy", err: "mta-sts: invalid id=missing-policy, expected 1 to 32 letters and digits"},
+		{txt: "v=STSv1; id=123456789012345678901234567890123", err: "mta-sts: invalid id=123456789012345678901234567890123, expected 1 to 32 letters and digits"},
+		{txt: "v=STSv1; id=1; id=2", err: "mta-sts: field id appears more than once"},
+		{txt: "v=STSv1; id", err: `mta-sts: invalid field "id"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.txt, func(t *testing.T) {
+			record, err := ParseRecord(test.txt)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("ParseRecord(%q) = %v, want %s", test.txt, err, test.err)
+				}
+				return
+			}
+
+			if err != nil || record.ID != test.id {
+				t.Errorf("ParseRecord(%q) = %+v, %v, want id=%s", test.txt, record, err, test.id)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		policy *Policy
+		err    string
+	}{
+		{
+			name:   "enforce",
+			body:   "version: STSv1\nmode: enforce\nmx: mx1.example.test\nmx: *.Example.test\nmax_age: 604800\n",
+			policy: &Policy{Version: "STSv1", Mode: ModeEnforce, MX: []string{"mx1.example.test", "*.example.test"}, MaxAge: 604800},
+		},
+		{
+			name:   "crlf and unknown keys",
+			body:   "version: STSv1\r\nmode: testing\r\nmx: mx.example.test\r\nmax_age: 86400\r\nextension: value\r\n",
+			policy: &Policy{Version: "STSv1", Mode: ModeTesting, MX: []string{"mx.example.test"}, MaxAge: 86400},
+		},
+		{name: "none without mx", body: "version: STSv1\nmode: none\nmax_age: 0", policy: &Policy{Version: "STSv1", Mode: ModeNone, MaxAge: 0}},
+		{name: "version", body: "version: STSv2\nmode: enforce\nmx: mx.example.test\nmax_age: 86400", err: `mta-sts: invalid policy version "STSv2", expected STSv1`},
+		{name: "mode", body: "version: STSv1\nmode: strict\nmx: mx.example.test\nmax_age: 86400", err: `mta-sts: invalid mode "strict", expected enforce, testing or none`},
+		{name: "missing max_age", body: "version: STSv1\nmode: enforce\nmx: mx.example.test", err: "mta-sts: max_age is missing"},
+		{name: "max_age too large", body: "version: STSv1\nmode: enforce\nmx: mx.example.test\nmax_age: 31557601", err: `mta-sts: invalid max_age "31557601", expected 0 to 31557600 seconds`},
+		{name: "enforce without mx", body: "version: STSv1\nmode: enforce\nmax_age: 86400", err: "mta-sts: mode enforce needs at least one mx"},
+		{name: "invalid line", body: "version STSv1", err: `mta-sts: invalid policy line "version STSv1"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := ParsePolicy(test.body)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("ParsePolicy() = %v, want %s", err, test.err)
+				}
+				return
+			}
+
+			if err != nil || !reflect.DeepEqual(policy, test.policy) {
+				t.Errorf("ParsePolicy() = %+v, %v, want %+v", policy, err, test.policy)
+			}
+		})
+	}
+}
+
+func TestMatchMX(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{pattern: "mx.example.test", host: "mx.example.test.", want: true},
+		{pattern: "MX.example.test", host: "mx.EXAMPLE.test", want: true},
+		{pattern: "*.example.test", host: "mx1.example.test", want: true},
+		{pattern: "*.example.test", host: "example.test", want: false},
+		{pattern: "*.example.test", host: "a.mx.example.test", want: false},
+		{pattern: "mx.example.test", host: "mx2.example.test", want: false},
+	}
+
+	for _, test := range tests {
+		if got := MatchMX(test.pattern, test.host); got != test.want {
+			t.Errorf("MatchMX(%q, %q) = %t, want %t", test.pattern, test.host, got, test.want)
+		}
+	}
+}
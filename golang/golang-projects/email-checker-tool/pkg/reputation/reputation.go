@@ -0,0 +1,222 @@
+package reputation
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// DefaultBlocklists are the DNS blocklists queried when none are configured.
+var DefaultBlocklists = []string{"zen.spamhaus.org", "bl.spamcop.net", "b.barracudacentral.org"}
+
+// Resolver looks up the addresses and names of the MX hosts, and their blocklist entries.
+type Resolver interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Checker resolves the MX hosts of a domain, checks the reverse DNS of their
+// addresses and queries the addresses against DNS blocklists.
+type Checker struct {
+	Resolver Resolver
+	// Blocklists are the zones of the DNS blocklists (RFC 5782), e.g. zen.spamhaus.org.
+	// Nil only checks the addresses and their reverse DNS.
+	Blocklists []string
+}
+
+// Host is the outcome of checking a single MX host.
+type Host struct {
+	Host      string    `json:"host"`
+	Addresses []Address `json:"addresses,omitempty"`
+	Errors    []string  `json:"errors,omitempty"`
+}
+
+// Address is the outcome of checking a single address of an MX host.
+type Address struct {
+	IP string `json:"ip"`
+	// PTR are the names the address points back to.
+	PTR []string `json:"ptr,omitempty"`
+	// FCrDNS tells whether one of the PTR names resolves back to the address,
+	// which many receivers expect from a mail server.
+	FCrDNS bool `json:"fcrdns"`
+	// PTRError tells that the PTR lookup failed, so FCrDNS is unknown rather than false.
+	PTRError string    `json:"ptrError,omitempty"`
+	Listings []Listing `json:"listings,omitempty"`
+	// Errors are the blocklists that could not be queried.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Listing is an entry of the address in a DNS blocklist.
+type Listing struct {
+	Blocklist string `json:"blocklist"`
+	// Codes are the 127.0.0.x return codes, whose meaning depends on the blocklist.
+	Codes  []string `json:"codes"`
+	Reason string   `json:"reason,omitempty"`
+}
+
+// Report is the outcome of checking every MX host of a domain.
+type Report struct {
+	Hosts []Host `json:"hosts"`
+}
+
+// Listed returns the addresses that are listed in at least one blocklist.
+func (r *Report) Listed() []*Address {
+	var listed []*Address
+
+	for i := range r.Hosts {
+		for j := range r.Hosts[i].Addresses {
+			if address := &r.Hosts[i].Addresses[j]; len(address.Listings) > 0 {
+				listed = append(listed, address)
+			}
+		}
+	}
+
+	return listed
+}
+
+// Check checks every MX host in preference order. It returns nil when the domain has no MX host.
+func (c *Checker) Check(ctx context.Context, mxHosts []string) *Report {
+	report := &Report{}
+
+	for _, host := range mxHosts {
+		// A null MX (RFC 7505) tells that the domain does not accept mail
+		host = strings.TrimSuffix(host, ".")
+		if host == "" {
+			continue
+		}
+
+		report.Hosts = append(report.Hosts, c.CheckHost(ctx, host))
+	}
+
+	if len(report.Hosts) == 0 {
+		return nil
+	}
+
+	return report
+}
+
+// CheckHost resolves the A and AAAA records of the host and checks every address.
+func (c *Checker) CheckHost(ctx context.Context, host string) Host {
+	result := Host{Host: host}
+
+	ips, err := c.Resolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("could not resolve %s: %v", host, err))
+		return result
+	}
+
+	for _, ip := range ips {
+		result.Addresses = append(result.Addresses, c.CheckAddress(ctx, ip))
+	}
+
+	return result
+}
+
+// CheckAddress checks the reverse DNS of the address and queries it against every blocklist.
+func (c *Checker) CheckAddress(ctx context.Context, ip net.IP) Address {
+	address := Address{IP: ip.String()}
+
+	names, err := c.Resolver.LookupAddr(ctx, ip.String())
+	if err != nil && !resolver.IsNotFound(err) {
+		address.PTRError = err.Error()
+	}
+
+	for _, name := range names {
+		name = strings.TrimSuffix(name, ".")
+		address.PTR = append(address.PTR, name)
+
+		if !address.FCrDNS && c.resolvesTo(ctx, name, ip) {
+			address.FCrDNS = true
+		}
+	}
+
+	for _, blocklist := range c.Blocklists {
+		blocklist = strings.TrimSuffix(blocklist, ".")
+
+		listing, err := c.query(ctx, blocklist, ip)
+		if err != nil {
+			address.Errors = append(address.Errors, fmt.Sprintf("%s: %v", blocklist, err))
+			continue
+		}
+
+		if listing != nil {
+			address.Listings = append(address.Listings, *listing)
+		}
+	}
+
+	return address
+}
+
+// resolvesTo tells whether one of the addresses of the name is ip
+func (c *Checker) resolvesTo(ctx context.Context, name string, ip net.IP) bool {
+	ips, err := c.Resolver.LookupIP(ctx, "ip", name)
+	if err != nil {
+		return false
+	}
+
+	for _, resolved := range ips {
+		if resolved.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// query looks up the address in the blocklist. It returns nil when the address is not listed.
+func (c *Checker) query(ctx context.Context, blocklist string, ip net.IP) (*Listing, error) {
+	name := reverseName(ip) + "." + blocklist
+
+	answers, err := c.Resolver.LookupIP(ctx, "ip4", name)
+	if resolver.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	listing := &Listing{Blocklist: blocklist}
+
+	for _, answer := range answers {
+		answer = answer.To4()
+
+		// The lists answer 127.0.0.0/8 (RFC 5782), anything else comes from a resolver
+		// that rewrites NXDOMAIN. Spamhaus answers 127.255.255.0/24 to refuse the query.
+		switch {
+		case answer == nil || answer[0] != 127:
+			return nil, fmt.Errorf("unexpected answer %s, the resolver may rewrite NXDOMAIN", answer)
+		case answer[1] == 255 && answer[2] == 255:
+			return nil, fmt.Errorf("the blocklist refused the query (%s), e.g. because it came from a public resolver", answer)
+		}
+
+		listing.Codes = append(listing.Codes, answer.String())
+	}
+
+	// The reason is optional, a listing without one is still a listing
+	if reasons, err := c.Resolver.LookupTXT(ctx, name); err == nil {
+		listing.Reason = strings.Join(reasons, " ")
+	}
+
+	return listing, nil
+}
+
+// reverseName returns the labels of the address in reverse order, the way blocklists
+// are queried: 4.3.2.1 for 1.2.3.4 and every nibble of an IPv6 address
+func reverseName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+
+	ip16 := ip.To16()
+	nibbles := make([]string, 0, 32)
+
+	for i := len(ip16) - 1; i >= 0; i-- {
+		nibbles = append(nibbles, fmt.Sprintf("%x", ip16[i]&0x0f), fmt.Sprintf("%x", ip16[i]>>4))
+	}
+
+	return strings.Join(nibbles, ".")
+}
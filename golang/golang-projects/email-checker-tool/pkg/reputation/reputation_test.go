@@ -0,0 +1,176 @@
+package reputation
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dnsstub"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// ip6Reversed are the nibbles of 2001:db8::25 in reverse order
+const ip6Reversed = "5.2.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2"
+
+// testZone has MX hosts with and without a matching reverse DNS, and two blocklists:
+// bl.test answers like a real one, rewrite.test like a resolver that rewrites NXDOMAIN
+var testZone = []string{
+	"mx.good.test. 300 IN A 192.0.2.10",
+	"10.2.0.192.in-addr.arpa. 300 IN PTR mx.good.test.",
+	"mx6.good.test. 300 IN AAAA 2001:db8::25",
+	ip6Reversed + ".ip6.arpa. 300 IN PTR mx6.good.test.",
+
+	"mx.mismatch.test. 300 IN A 192.0.2.20",
+	"20.2.0.192.in-addr.arpa. 300 IN PTR mail.elsewhere.test.",
+	"mail.elsewhere.test. 300 IN A 192.0.2.99",
+
+	"mx.noptr.test. 300 IN A 192.0.2.30",
+
+	"20.2.0.192.bl.test. 300 IN A 127.0.0.2",
+	"20.2.0.192.bl.test. 300 IN A 127.0.0.4",
+	`20.2.0.192.bl.test. 300 IN TXT "Listed for spam, see https://bl.test/192.0.2.20"`,
+	"30.2.0.192.bl.test. 300 IN A 127.255.255.254",
+	ip6Reversed + ".bl.test. 300 IN A 127.0.0.3",
+
+	"10.2.0.192.rewrite.test. 300 IN A 192.0.2.200",
+}
+
+// newTestChecker returns a Checker querying the test zone with the blocklists
+func newTestChecker(t *testing.T, blocklists ...string) *Checker {
+	t.Helper()
+
+	zone := dnsstub.NewZone()
+	if err := zone.Add(testZone...); err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := dnsstub.Start("127.0.0.1:0", zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	return &Checker{
+		Resolver:   resolver.NewNameserver(server.Addr(), "udp", 2*time.Second),
+		Blocklists: blocklists,
+	}
+}
+
+func TestCheckAddress(t *testing.T) {
+	checker := newTestChecker(t, "bl.test.")
+
+	tests := []struct {
+		ip       string
+		ptr      []string
+		fcrdns   bool
+		listings []Listing
+		errors   []string
+	}{
+		{ip: "192.0.2.10", ptr: []string{"mx.good.test"}, fcrdns: true},
+		{ip: "2001:db8::25", ptr: []string{"mx6.good.test"}, fcrdns: true, listings: []Listing{{Blocklist: "bl.test", Codes: []string{"127.0.0.3"}}}},
+		{
+			// The PTR name exists but resolves to another address
+			ip:       "192.0.2.20",
+			ptr:      []string{"mail.elsewhere.test"},
+			listings: []Listing{{Blocklist: "bl.test", Codes: []string{"127.0.0.2", "127.0.0.4"}, Reason: "Listed for spam, see https://bl.test/192.0.2.20"}},
+		},
+		{
+			// A missing PTR record is no error, and 127.255.255.x is a refused query, not a listing
+			ip:     "192.0.2.30",
+			errors: []string{"bl.test: the blocklist refused the query (127.255.255.254)"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			address := checker.CheckAddress(context.Background(), net.ParseIP(test.ip))
+
+			if !reflect.DeepEqual(address.PTR, test.ptr) || address.FCrDNS != test.fcrdns || address.PTRError != "" {
+				t.Errorf("CheckAddress(%s) = PTR %q, FCrDNS %v (%s), want %q, %v", test.ip, address.PTR, address.FCrDNS, address.PTRError, test.ptr, test.fcrdns)
+			}
+
+			if !reflect.DeepEqual(address.Listings, test.listings) {
+				t.Errorf("CheckAddress(%s) = listings %+v, want %+v", test.ip, address.Listings, test.listings)
+			}
+
+			if len(address.Errors) != len(test.errors) {
+				t.Fatalf("CheckAddress(%s) = errors %q, want %q", test.ip, address.Errors, test.errors)
+			}
+
+			for i, err := range address.Errors {
+				if !strings.HasPrefix(err, test.errors[i]) {
+					t.Errorf("CheckAddress(%s) error %q, want %q", test.ip, err, test.errors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckRewrittenNXDOMAIN(t *testing.T) {
+	checker := newTestChecker(t, "bl.test", "rewrite.test")
+
+	// An address out of 127.0.0.0/8 is not a listing, the resolver made it up
+	address := checker.CheckAddress(context.Background(), net.ParseIP("192.0.2.10"))
+
+	if len(address.Listings) != 0 {
+		t.Errorf("CheckAddress() = listings %+v, want none", address.Listings)
+	}
+
+	if len(address.Errors) != 1 || !strings.HasPrefix(address.Errors[0], "rewrite.test: unexpected answer 192.0.2.200, the resolver may rewrite NXDOMAIN") {
+		t.Errorf("CheckAddress() = errors %q, want the rewritten answer of rewrite.test", address.Errors)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	checker := newTestChecker(t, "bl.test")
+
+	// The null MX is skipped, and a host that does not resolve gets an error
+	report := checker.Check(context.Background(), []string{"mx.good.test.", ".", "mx.mismatch.test.", "missing.good.test."})
+	if report == nil {
+		t.Fatal("Check() = nil, want a report")
+	}
+
+	var hosts []string
+	for _, host := range report.Hosts {
+		hosts = append(hosts, host.Host)
+	}
+
+	if want := []string{"mx.good.test", "mx.mismatch.test", "missing.good.test"}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("Check() = hosts %q, want %q", hosts, want)
+	}
+
+	if missing := report.Hosts[2]; len(missing.Addresses) != 0 || len(missing.Errors) != 1 || !strings.HasPrefix(missing.Errors[0], "could not resolve missing.good.test") {
+		t.Errorf("Check() = %+v for missing.good.test, want an error", missing)
+	}
+
+	listed := report.Listed()
+	if len(listed) != 1 || listed[0].IP != "192.0.2.20" {
+		t.Errorf("Listed() = %+v, want 192.0.2.20", listed)
+	}
+
+	if report := checker.Check(context.Background(), []string{"."}); report != nil {
+		t.Errorf("Check() of a null MX = %+v, want nil", report)
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	tests := []struct {
+		ip   string
+		name string
+	}{
+		{ip: "192.0.2.10", name: "10.2.0.192"},
+		{ip: "127.0.0.2", name: "2.0.0.127"},
+		{ip: "::ffff:192.0.2.10", name: "10.2.0.192"},
+		{ip: "2001:db8::25", name: ip6Reversed},
+		{ip: "2001:db8:abcd:12::f", name: "f.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.2.1.0.0.d.c.b.a.8.b.d.0.1.0.0.2"},
+	}
+
+	for _, test := range tests {
+		if name := reverseName(net.ParseIP(test.ip)); name != test.name {
+			t.Errorf("reverseName(%s) = %s, want %s", test.ip, name, test.name)
+		}
+	}
+}
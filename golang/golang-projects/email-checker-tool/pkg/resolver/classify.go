@@ -0,0 +1,73 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// Classes of the outcome of a lookup
+const (
+	// ClassOK is a lookup that returned records.
+	ClassOK = "ok"
+	// ClassNotFound is a name that exists without records of the requested type (NODATA).
+	ClassNotFound = "not-found"
+	// ClassNXDomain is a name that does not exist.
+	ClassNXDomain = "nxdomain"
+	// ClassTemporary is a failure that may not happen again, e.g. SERVFAIL.
+	ClassTemporary = "temporary"
+	// ClassTimeout is a lookup that got no answer in time.
+	ClassTimeout = "timeout"
+	// ClassError is any other failure, e.g. a REFUSED answer or an invalid name.
+	ClassError = "error"
+)
+
+// Classify tells the class of the outcome of a lookup from its error. The system
+// resolver does not always tell NXDOMAIN from NODATA, its not found answers are
+// then classified as nxdomain.
+func Classify(err error) string {
+	if err == nil {
+		return ClassOK
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ClassTimeout
+	}
+
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		return ClassError
+	}
+
+	switch {
+	case dnsErr.IsNotFound && dnsErr.Err == ErrNoRecords:
+		return ClassNotFound
+	case dnsErr.IsNotFound:
+		return ClassNXDomain
+	case dnsErr.IsTimeout:
+		return ClassTimeout
+	case dnsErr.IsTemporary:
+		return ClassTemporary
+	default:
+		return ClassError
+	}
+}
+
+// Failed tells if the class is a failure rather than an answer, the records may
+// then exist or not.
+func Failed(class string) bool {
+	return class == ClassTemporary || class == ClassTimeout || class == ClassError
+}
+
+// Retryable tells if the lookup is worth sending again.
+func Retryable(err error) bool {
+	class := Classify(err)
+	return class == ClassTemporary || class == ClassTimeout
+}
+
+// IsNotFound tells if the lookup failed because the name or the record does not exist,
+// i.e. its class is ClassNotFound or ClassNXDomain.
+func IsNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
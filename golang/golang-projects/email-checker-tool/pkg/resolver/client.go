@@ -0,0 +1,198 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Error messages of the *net.DNSError returned by Client, worded like the ones of the net package
+const (
+	ErrNoSuchHost   = "no such host"
+	ErrNoRecords    = "no records of the requested type"
+	ErrServerFailed = "server misbehaving"
+)
+
+// Exchanger sends a DNS query to a nameserver and returns its response.
+type Exchanger interface {
+	Exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error)
+	// Server describes the nameserver, it is used in the error messages.
+	Server() string
+}
+
+// Client is a Resolver that builds the DNS queries itself and sends them with an Exchanger.
+type Client struct {
+	Exchanger Exchanger
+}
+
+// LookupMX returns the MX records of the name sorted by preference.
+func (c *Client) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	answers, err := c.Lookup(ctx, name, dns.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*net.MX, 0, len(answers))
+	for _, answer := range answers {
+		mx := answer.(*dns.MX)
+		records = append(records, &net.MX{Host: mx.Mx, Pref: mx.Preference})
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Pref < records[j].Pref
+	})
+
+	return records, nil
+}
+
+// LookupTXT returns the TXT records of the name.
+func (c *Client) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	answers, err := c.Lookup(ctx, name, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]string, 0, len(answers))
+	for _, answer := range answers {
+		// A single TXT record can be split into several strings of 255 bytes
+		records = append(records, strings.Join(answer.(*dns.TXT).Txt, ""))
+	}
+
+	return records, nil
+}
+
+// LookupIP returns the A records, the AAAA records or both depending on network.
+func (c *Client) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	var qtypes []uint16
+
+	switch network {
+	case "ip4":
+		qtypes = []uint16{dns.TypeA}
+	case "ip6":
+		qtypes = []uint16{dns.TypeAAAA}
+	case "ip":
+		qtypes = []uint16{dns.TypeA, dns.TypeAAAA}
+	default:
+		return nil, &net.DNSError{Err: "unsupported network " + network, Name: host}
+	}
+
+	var ips []net.IP
+	var lastErr error
+
+	for _, qtype := range qtypes {
+		answers, err := c.Lookup(ctx, host, qtype)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		for _, answer := range answers {
+			switch rr := answer.(type) {
+			case *dns.A:
+				ips = append(ips, rr.A)
+			case *dns.AAAA:
+				ips = append(ips, rr.AAAA)
+			}
+		}
+	}
+
+	// Only fails when none of the queries returned an address
+	if len(ips) == 0 {
+		return nil, lastErr
+	}
+
+	return ips, nil
+}
+
+// LookupAddr returns the names the address points to.
+func (c *Client) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	name, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return nil, &net.DNSError{Err: "unrecognized address", Name: addr}
+	}
+
+	answers, err := c.Lookup(ctx, name, dns.TypePTR)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(answers))
+	for _, answer := range answers {
+		names = append(names, answer.(*dns.PTR).Ptr)
+	}
+
+	return names, nil
+}
+
+// Lookup sends a query of the given type and returns the answers of that type.
+// Failures are returned as a *net.DNSError.
+func (c *Client) Lookup(ctx context.Context, name string, qtype uint16) ([]dns.RR, error) {
+	query := new(dns.Msg)
+	query.SetQuestion(dns.Fqdn(name), qtype)
+	query.SetEdns0(4096, false)
+
+	response, err := c.Exchanger.Exchange(ctx, query)
+	if err != nil {
+		return nil, c.exchangeError(name, err)
+	}
+
+	switch response.Rcode {
+	case dns.RcodeSuccess:
+	case dns.RcodeNameError:
+		return nil, c.dnsError(name, ErrNoSuchHost, true, false)
+	case dns.RcodeServerFailure:
+		return nil, c.dnsError(name, ErrServerFailed, false, true)
+	default:
+		return nil, c.dnsError(name, "server returned "+dns.RcodeToString[response.Rcode], false, false)
+	}
+
+	// The answer section can also contain the CNAME records that lead to the name
+	var answers []dns.RR
+	for _, answer := range response.Answer {
+		if answer.Header().Rrtype == qtype {
+			answers = append(answers, answer)
+		}
+	}
+
+	if len(answers) == 0 {
+		return nil, c.dnsError(name, ErrNoRecords, true, false)
+	}
+
+	return answers, nil
+}
+
+// dnsError returns a *net.DNSError for the name
+func (c *Client) dnsError(name, message string, notFound, temporary bool) error {
+	return &net.DNSError{
+		Err:         message,
+		Name:        name,
+		Server:      c.Exchanger.Server(),
+		IsNotFound:  notFound,
+		IsTemporary: temporary,
+	}
+}
+
+// exchangeError converts a failed exchange to a *net.DNSError
+func (c *Client) exchangeError(name string, err error) error {
+	dnsErr := &net.DNSError{
+		Err:    err.Error(),
+		Name:   name,
+		Server: c.Exchanger.Server(),
+	}
+
+	// The nameserver could not be reached or did not answer, the next attempt may succeed
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		dnsErr.IsTimeout = true
+		dnsErr.IsTemporary = true
+	case !errors.Is(err, context.Canceled):
+		dnsErr.IsTemporary = true
+	}
+
+	return dnsErr
+}
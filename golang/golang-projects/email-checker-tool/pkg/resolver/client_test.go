@@ -0,0 +1,98 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dnsstub"
+)
+
+// startStub serves the records on a free port until the test ends, and returns a Client querying them
+func startStub(t *testing.T, network string, records ...string) *Client {
+	t.Helper()
+
+	zone := dnsstub.NewZone()
+	if err := zone.Add(records...); err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := dnsstub.Start("127.0.0.1:0", zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	return NewNameserver(server.Addr(), network, 2*time.Second)
+}
+
+func TestClientLookups(t *testing.T) {
+	records := []string{
+		"example.test. 300 IN MX 20 mx2.example.test.",
+		"example.test. 300 IN MX 10 mx1.example.test.",
+		`example.test. 300 IN TXT "v=spf1 " "-all"`,
+		"mx1.example.test. 300 IN A 192.0.2.1",
+		"mx1.example.test. 300 IN AAAA 2001:db8::1",
+		"alias.example.test. 300 IN CNAME mx1.example.test.",
+		"1.2.0.192.in-addr.arpa. 300 IN PTR mx1.example.test.",
+	}
+
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network, func(t *testing.T) {
+			client := startStub(t, network, records...)
+			ctx := context.Background()
+
+			mx, err := client.LookupMX(ctx, "example.test")
+			if err != nil || len(mx) != 2 || mx[0].Host != "mx1.example.test." || mx[0].Pref != 10 {
+				t.Errorf("LookupMX() = %v, %v, want mx1 first", mx, err)
+			}
+
+			txt, err := client.LookupTXT(ctx, "example.test")
+			if want := []string{"v=spf1 -all"}; err != nil || !reflect.DeepEqual(txt, want) {
+				t.Errorf("LookupTXT() = %q, %v, want %q", txt, err, want)
+			}
+
+			ips, err := client.LookupIP(ctx, "ip", "alias.example.test")
+			if err != nil || len(ips) != 2 || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+				t.Errorf("LookupIP() through a CNAME = %v, %v, want both addresses", ips, err)
+			}
+
+			names, err := client.LookupAddr(ctx, "192.0.2.1")
+			if want := []string{"mx1.example.test."}; err != nil || !reflect.DeepEqual(names, want) {
+				t.Errorf("LookupAddr() = %q, %v, want %q", names, err, want)
+			}
+
+			_, err = client.LookupTXT(ctx, "mx1.example.test")
+			if class := Classify(err); class != ClassNotFound {
+				t.Errorf("LookupTXT() of a name without TXT = %v (%s), want %s", err, class, ClassNotFound)
+			}
+
+			_, err = client.LookupMX(ctx, "missing.example.test")
+			if class := Classify(err); class != ClassNXDomain {
+				t.Errorf("LookupMX() of a missing name = %v (%s), want %s", err, class, ClassNXDomain)
+			}
+		})
+	}
+}
+
+func TestClientUnreachable(t *testing.T) {
+	// Nothing answers on the port of a listener that was closed
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.LocalAddr().String()
+	listener.Close()
+
+	client := NewNameserver(addr, "udp", 200*time.Millisecond)
+
+	_, err = client.LookupMX(context.Background(), "example.test")
+	if class := Classify(err); !Failed(class) || !Retryable(err) {
+		t.Errorf("LookupMX() of an unreachable nameserver = %v (%s), want a retryable failure", err, class)
+	}
+}
@@ -0,0 +1,61 @@
+package resolver
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+)
+
+// DNSSEC status of an answer
+const (
+	// SecuritySecure means that the resolver validated the answer, or the proof that it does not exist.
+	SecuritySecure = "secure"
+	// SecurityInsecure means that the zone is not signed, the answer cannot be authenticated.
+	SecurityInsecure = "insecure"
+	// SecurityBogus means that the zone is signed but the validation failed.
+	SecurityBogus = "bogus"
+	// SecurityIndeterminate means that the status could not be determined, e.g. after a timeout.
+	SecurityIndeterminate = "indeterminate"
+)
+
+// Security queries the name with the DO bit and tells whether the resolver authenticated
+// the answer. It relies on the resolver validating the answers: the AD bit of its
+// response means secure, and a SERVFAIL that goes away when the validation is
+// disabled with the CD bit means bogus.
+func (c *Client) Security(ctx context.Context, name string, qtype uint16) (string, error) {
+	response, err := c.exchangeDNSSEC(ctx, name, qtype, false)
+	if err != nil {
+		return SecurityIndeterminate, c.exchangeError(name, err)
+	}
+
+	switch {
+	case response.Rcode == dns.RcodeServerFailure:
+	case response.AuthenticatedData:
+		return SecuritySecure, nil
+	default:
+		return SecurityInsecure, nil
+	}
+
+	// Tells a failed validation apart from a server that is simply failing
+	response, err = c.exchangeDNSSEC(ctx, name, qtype, true)
+	if err != nil {
+		return SecurityIndeterminate, c.exchangeError(name, err)
+	}
+
+	if response.Rcode == dns.RcodeServerFailure {
+		return SecurityIndeterminate, c.dnsError(name, ErrServerFailed, false, true)
+	}
+
+	return SecurityBogus, nil
+}
+
+// exchangeDNSSEC sends a query with the DO and AD bits, and the CD bit when checkingDisabled
+func (c *Client) exchangeDNSSEC(ctx context.Context, name string, qtype uint16, checkingDisabled bool) (*dns.Msg, error) {
+	query := new(dns.Msg)
+	query.SetQuestion(dns.Fqdn(name), qtype)
+	query.SetEdns0(4096, true)
+	query.AuthenticatedData = true
+	query.CheckingDisabled = checkingDisabled
+
+	return c.Exchanger.Exchange(ctx, query)
+}
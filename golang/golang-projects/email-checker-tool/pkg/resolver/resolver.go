@@ -0,0 +1,98 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Resolver looks up the DNS records used by the email checks.
+type Resolver interface {
+	// LookupMX returns the MX records of the name sorted by preference.
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	// LookupTXT returns the TXT records of the name. The strings of a
+	// single record are joined together.
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	// LookupIP returns the addresses of the host. network is "ip4" for the
+	// A records, "ip6" for the AAAA records or "ip" for both.
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+	// LookupAddr returns the names the address points to using the PTR records.
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// System uses the resolver of the operating system.
+type System struct{}
+
+// LookupMX returns the MX records of the name using net.DefaultResolver.
+func (System) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return net.DefaultResolver.LookupMX(ctx, name)
+}
+
+// LookupTXT returns the TXT records of the name using net.DefaultResolver.
+func (System) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return net.DefaultResolver.LookupTXT(ctx, name)
+}
+
+// LookupIP returns the addresses of the host using net.DefaultResolver.
+func (System) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, network, host)
+}
+
+// LookupAddr returns the names of the address using net.DefaultResolver.
+func (System) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	return net.DefaultResolver.LookupAddr(ctx, addr)
+}
+
+// New returns the Resolver described by spec:
+//
+//	system                            the resolver of the operating system
+//	host[:port] or udp://host[:port]  a nameserver queried over UDP, falling back to TCP
+//	tcp://host[:port]                 a nameserver queried over TCP
+//	tls://host[:port]                 DNS-over-TLS (RFC 7858)
+//	https://host/path                 DNS-over-HTTPS (RFC 8484)
+//
+// timeout is used by the nameserver, DoT and DoH resolvers as the timeout of a single exchange.
+func New(spec string, timeout time.Duration) (Resolver, error) {
+	if spec == "" || spec == "system" {
+		return System{}, nil
+	}
+
+	// A plain address is a nameserver queried over UDP
+	if !strings.Contains(spec, "://") {
+		spec = "udp://" + spec
+	}
+
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resolver %q: %v", spec, err)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid resolver %q: missing host", spec)
+	}
+
+	switch u.Scheme {
+	case "udp":
+		return NewNameserver(withPort(u.Host, "53"), "udp", timeout), nil
+	case "tcp":
+		return NewNameserver(withPort(u.Host, "53"), "tcp", timeout), nil
+	case "tls":
+		return NewDoT(withPort(u.Host, "853"), timeout), nil
+	case "https":
+		return NewDoH(u.String(), timeout), nil
+	default:
+		return nil, fmt.Errorf("invalid resolver %q: unsupported scheme %q", spec, u.Scheme)
+	}
+}
+
+// withPort adds the default port to the host if it does not have one
+func withPort(host, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
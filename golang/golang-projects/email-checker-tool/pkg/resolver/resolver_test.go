@@ -0,0 +1,89 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		spec   string
+		server string
+		err    bool
+	}{
+		{spec: "", server: "system"},
+		{spec: "system", server: "system"},
+		{spec: "1.1.1.1", server: "1.1.1.1:53"},
+		{spec: "udp://1.1.1.1:5353", server: "1.1.1.1:5353"},
+		{spec: "tcp://[2606:4700::1111]", server: "[2606:4700::1111]:53"},
+		{spec: "tls://dns.google", server: "tls://dns.google:853"},
+		{spec: "https://dns.google/dns-query", server: "https://dns.google/dns-query"},
+		{spec: "ftp://dns.google", err: true},
+		{spec: "udp://", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			resolver, err := New(test.spec, time.Second)
+			if test.err {
+				if err == nil {
+					t.Fatalf("New(%q) = %T, want an error", test.spec, resolver)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("New(%q) = %v", test.spec, err)
+			}
+
+			server := "system"
+			if client, ok := resolver.(*Client); ok {
+				server = client.Exchanger.Server()
+			}
+
+			if server != test.server {
+				t.Errorf("New(%q) queries %s, want %s", test.spec, server, test.server)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		class string
+	}{
+		{name: "answer", err: nil, class: ClassOK},
+		{name: "nodata", err: &net.DNSError{Err: ErrNoRecords, IsNotFound: true}, class: ClassNotFound},
+		{name: "nxdomain", err: &net.DNSError{Err: ErrNoSuchHost, IsNotFound: true}, class: ClassNXDomain},
+		{name: "servfail", err: &net.DNSError{Err: ErrServerFailed, IsTemporary: true}, class: ClassTemporary},
+		{name: "timeout", err: &net.DNSError{Err: "i/o timeout", IsTimeout: true, IsTemporary: true}, class: ClassTimeout},
+		{name: "deadline", err: fmt.Errorf("lookup: %w", context.DeadlineExceeded), class: ClassTimeout},
+		{name: "refused", err: &net.DNSError{Err: "server returned REFUSED"}, class: ClassError},
+		{name: "other", err: errors.New("boom"), class: ClassError},
+		{name: "wrapped", err: fmt.Errorf("_dmarc.example.test: %w", &net.DNSError{Err: ErrNoSuchHost, IsNotFound: true}), class: ClassNXDomain},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if class := Classify(test.err); class != test.class {
+				t.Errorf("Classify(%v) = %s, want %s", test.err, class, test.class)
+			}
+
+			want := test.class == ClassTemporary || test.class == ClassTimeout
+			if retryable := Retryable(test.err); retryable != want {
+				t.Errorf("Retryable(%v) = %v, want %v", test.err, retryable, want)
+			}
+
+			want = test.class == ClassNotFound || test.class == ClassNXDomain
+			if notFound := IsNotFound(test.err); notFound != want {
+				t.Errorf("IsNotFound(%v) = %v, want %v", test.err, notFound, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// dohMediaType is the media type of a DNS message sent over HTTPS
+const dohMediaType = "application/dns-message"
+
+// maxDoHResponse is the largest DNS message accepted from a DoH server
+const maxDoHResponse = 65535
+
+// Nameserver sends the queries to a specific nameserver over UDP or TCP.
+type Nameserver struct {
+	addr string
+	udp  *dns.Client
+	tcp  *dns.Client
+	net  string
+}
+
+// NewNameserver returns a Resolver that queries the nameserver at addr. network is
+// either "udp", which retries over TCP when the response is truncated, or "tcp".
+func NewNameserver(addr, network string, timeout time.Duration) *Client {
+	return &Client{Exchanger: &Nameserver{
+		addr: addr,
+		udp:  &dns.Client{Net: "udp", Timeout: timeout},
+		tcp:  &dns.Client{Net: "tcp", Timeout: timeout},
+		net:  network,
+	}}
+}
+
+// Exchange sends the query to the nameserver.
+func (n *Nameserver) Exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error) {
+	if n.net == "tcp" {
+		response, _, err := n.tcp.ExchangeContext(ctx, query, n.addr)
+		return response, err
+	}
+
+	response, _, err := n.udp.ExchangeContext(ctx, query, n.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The whole response does not fit in a UDP packet
+	if response.Truncated {
+		response, _, err = n.tcp.ExchangeContext(ctx, query, n.addr)
+	}
+
+	return response, err
+}
+
+// Server returns the address of the nameserver.
+func (n *Nameserver) Server() string {
+	return n.addr
+}
+
+// DoT sends the queries to a DNS-over-TLS server.
+type DoT struct {
+	addr   string
+	client *dns.Client
+}
+
+// NewDoT returns a Resolver that queries the DNS-over-TLS server at addr.
+// The certificate of the server is verified against the host of addr.
+func NewDoT(addr string, timeout time.Duration) *Client {
+	host, _, _ := net.SplitHostPort(addr)
+
+	return &Client{Exchanger: &DoT{
+		addr: addr,
+		client: &dns.Client{
+			Net:       "tcp-tls",
+			Timeout:   timeout,
+			TLSConfig: &tls.Config{ServerName: host},
+		},
+	}}
+}
+
+// Exchange sends the query to the DNS-over-TLS server.
+func (d *DoT) Exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error) {
+	response, _, err := d.client.ExchangeContext(ctx, query, d.addr)
+	return response, err
+}
+
+// Server returns the address of the DNS-over-TLS server.
+func (d *DoT) Server() string {
+	return "tls://" + d.addr
+}
+
+// DoH sends the queries to a DNS-over-HTTPS server.
+type DoH struct {
+	url    string
+	client *http.Client
+}
+
+// NewDoH returns a Resolver that POSTs the queries to the DNS-over-HTTPS server at url.
+func NewDoH(url string, timeout time.Duration) *Client {
+	return &Client{Exchanger: &DoH{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}}
+}
+
+// Exchange sends the query to the DNS-over-HTTPS server.
+func (d *DoH) Exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error) {
+	// RFC 8484 recommends an ID of 0 so that the responses can be cached by HTTP caches
+	query = query.Copy()
+	query.Id = 0
+
+	body, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", dohMediaType)
+	request.Header.Set("Accept", dohMediaType)
+
+	resp, err := d.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDoHResponse))
+	if err != nil {
+		return nil, err
+	}
+
+	response := new(dns.Msg)
+	if err := response.Unpack(data); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Server returns the URL of the DNS-over-HTTPS server.
+func (d *DoH) Server() string {
+	return d.url
+}
@@ -0,0 +1,205 @@
+package score
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// Categories of the checks that are scored
+const (
+	CategoryMX     = "mx"
+	CategorySMTP   = "smtp"
+	CategorySPF    = "spf"
+	CategoryDMARC  = "dmarc"
+	CategoryDKIM   = "dkim"
+	CategoryMTASTS = "mta-sts"
+	CategoryTLSRPT = "tls-rpt"
+	CategoryBIMI   = "bimi"
+	CategoryDNSSEC = "dnssec"
+)
+
+// Grades from the best to the worst
+const (
+	GradeA = "A"
+	GradeB = "B"
+	GradeC = "C"
+	GradeD = "D"
+	GradeF = "F"
+)
+
+// Grades lists every grade from the best to the worst.
+var Grades = []string{GradeA, GradeB, GradeC, GradeD, GradeF}
+
+// Policy holds the weight of every category and the lowest score of every grade.
+type Policy struct {
+	// Weights are relative, a category with a zero weight is not scored.
+	Weights map[string]float64 `json:"weights"`
+	// Thresholds are the lowest score, out of 100, of the grades A to D. Lower scores get an F.
+	Thresholds map[string]float64 `json:"thresholds"`
+}
+
+// Check is the outcome of a single category for a domain.
+type Check struct {
+	Category string
+	// Points go from 0, the mechanism is missing or broken, to 1, there is nothing to fix.
+	Points float64
+	// Remediation tells what to change to get the missing points.
+	Remediation []string
+}
+
+// Category is a scored check.
+type Category struct {
+	Name        string   `json:"name"`
+	Weight      float64  `json:"weight"`
+	Points      float64  `json:"points"`
+	Remediation []string `json:"remediation,omitempty"`
+}
+
+// Result is the score of a domain.
+type Result struct {
+	// Score goes from 0 to 100.
+	Score      float64    `json:"score"`
+	Grade      string     `json:"grade"`
+	Categories []Category `json:"categories"`
+	// Remediation lists the fixes of every category, those worth the most points first.
+	Remediation []string `json:"remediation,omitempty"`
+}
+
+// DefaultPolicy returns the weights and thresholds used when no policy file is given.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Weights: map[string]float64{
+			CategoryMX:     15,
+			CategorySMTP:   10,
+			CategorySPF:    20,
+			CategoryDMARC:  25,
+			CategoryDKIM:   15,
+			CategoryMTASTS: 5,
+			CategoryTLSRPT: 3,
+			CategoryBIMI:   2,
+			CategoryDNSSEC: 5,
+		},
+		Thresholds: map[string]float64{
+			GradeA: 90,
+			GradeB: 80,
+			GradeC: 70,
+			GradeD: 60,
+		},
+	}
+}
+
+// LoadPolicy reads a JSON policy file. The weights and thresholds it does not
+// set keep their default value.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file Policy
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("score: invalid policy file %s: %v", path, err)
+	}
+
+	policy := DefaultPolicy()
+
+	for category, weight := range file.Weights {
+		if _, ok := policy.Weights[category]; !ok {
+			return nil, fmt.Errorf("score: unknown category %q in %s", category, path)
+		}
+
+		if weight < 0 {
+			return nil, fmt.Errorf("score: the weight of %s cannot be negative", category)
+		}
+
+		policy.Weights[category] = weight
+	}
+
+	for grade, threshold := range file.Thresholds {
+		if _, ok := policy.Thresholds[grade]; !ok {
+			return nil, fmt.Errorf("score: unknown grade %q in %s, expected A, B, C or D", grade, path)
+		}
+
+		policy.Thresholds[grade] = threshold
+	}
+
+	// A better grade must need a higher score
+	for i := 1; i < len(Grades)-1; i++ {
+		if policy.Thresholds[Grades[i]] > policy.Thresholds[Grades[i-1]] {
+			return nil, fmt.Errorf("score: the threshold of %s is higher than the one of %s", Grades[i], Grades[i-1])
+		}
+	}
+
+	return policy, nil
+}
+
+// Score combines the checks into a weighted score and its grade. The checks of
+// the categories without a weight are left out. It returns nil when no check is left.
+func (p *Policy) Score(checks []Check) *Result {
+	result := &Result{}
+
+	var total, earned float64
+	var lost []float64
+
+	for _, check := range checks {
+		weight := p.Weights[check.Category]
+		if weight <= 0 {
+			continue
+		}
+
+		points := math.Max(0, math.Min(1, check.Points))
+
+		result.Categories = append(result.Categories, Category{
+			Name:        check.Category,
+			Weight:      weight,
+			Points:      round(points),
+			Remediation: check.Remediation,
+		})
+
+		total += weight
+		earned += weight * points
+		lost = append(lost, weight*(1-points))
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	result.Score = round(100 * earned / total)
+	result.Grade = p.Grade(result.Score)
+
+	// The categories that lose the most points are the first to fix
+	order := make([]int, len(result.Categories))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return lost[order[i]] > lost[order[j]]
+	})
+
+	for _, i := range order {
+		result.Remediation = append(result.Remediation, result.Categories[i].Remediation...)
+	}
+
+	return result
+}
+
+// Grade returns the grade of a score out of 100.
+func (p *Policy) Grade(score float64) string {
+	for _, grade := range Grades[:len(Grades)-1] {
+		if score >= p.Thresholds[grade] {
+			return grade
+		}
+	}
+
+	return GradeF
+}
+
+// round keeps two decimals so that the scores read well in the reports
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
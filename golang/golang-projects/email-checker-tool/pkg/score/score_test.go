@@ -0,0 +1,269 @@
+package score
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// policyFile is the policy of the README, which favors DMARC and does not score BIMI
+const policyFile = "../../testdata/score-policy.json"
+
+// writePolicy writes the content to a policy file of its own and returns its path
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		weights    map[string]float64
+		thresholds map[string]float64
+		err        string
+	}{
+		{
+			// The missing fields keep their default value
+			name:       "partial",
+			content:    `{"weights": {"bimi": 0, "dmarc": 40}, "thresholds": {"A": 95}}`,
+			weights:    map[string]float64{CategoryBIMI: 0, CategoryDMARC: 40, CategorySPF: 20, CategoryMX: 15},
+			thresholds: map[string]float64{GradeA: 95, GradeB: 80, GradeC: 70, GradeD: 60},
+		},
+		{
+			name:       "empty",
+			content:    `{}`,
+			weights:    DefaultPolicy().Weights,
+			thresholds: DefaultPolicy().Thresholds,
+		},
+		{name: "unknown category", content: `{"weights": {"spam": 10}}`, err: `unknown category "spam"`},
+		{name: "negative weight", content: `{"weights": {"spf": -5}}`, err: "the weight of spf cannot be negative"},
+		{name: "unknown grade", content: `{"thresholds": {"E": 10}}`, err: `unknown grade "E"`},
+		{name: "grade F", content: `{"thresholds": {"F": 0}}`, err: `unknown grade "F"`},
+		{name: "thresholds out of order", content: `{"thresholds": {"B": 95}}`, err: "the threshold of B is higher than the one of A"},
+		{name: "default out of order", content: `{"thresholds": {"D": 75}}`, err: "the threshold of D is higher than the one of C"},
+		{name: "invalid json", content: `{"weights": [}`, err: "invalid policy file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := LoadPolicy(writePolicy(t, test.content))
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("LoadPolicy(%s) = %v, want an error with %q", test.content, err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("LoadPolicy(%s) = %v", test.content, err)
+			}
+
+			for category, weight := range test.weights {
+				if policy.Weights[category] != weight {
+					t.Errorf("LoadPolicy(%s) weight of %s = %v, want %v", test.content, category, policy.Weights[category], weight)
+				}
+			}
+
+			if !reflect.DeepEqual(policy.Thresholds, test.thresholds) {
+				t.Errorf("LoadPolicy(%s) thresholds = %v, want %v", test.content, policy.Thresholds, test.thresholds)
+			}
+		})
+	}
+
+	if _, err := LoadPolicy(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadPolicy() of a missing file = nil, want an error")
+	}
+}
+
+func TestLoadPolicyTestdata(t *testing.T) {
+	policy, err := LoadPolicy(policyFile)
+	if err != nil {
+		t.Fatalf("LoadPolicy(%s) = %v", policyFile, err)
+	}
+
+	if policy.Weights[CategoryDMARC] != 30 || policy.Weights[CategoryBIMI] != 0 || policy.Thresholds[GradeA] != 95 || policy.Thresholds[GradeD] != 50 {
+		t.Errorf("LoadPolicy(%s) = %+v", policyFile, policy)
+	}
+
+	// BIMI is not scored: the missing logo neither lowers the score nor asks for a fix
+	checks := []Check{
+		{Category: CategoryMX, Points: 1},
+		{Category: CategorySMTP, Points: 1},
+		{Category: CategorySPF, Points: 1},
+		{Category: CategoryDMARC, Points: 1},
+		{Category: CategoryDKIM, Points: 1},
+		{Category: CategoryMTASTS, Points: 1},
+		{Category: CategoryTLSRPT, Points: 1},
+		{Category: CategoryBIMI, Points: 0, Remediation: []string{"publish a BIMI record"}},
+		{Category: CategoryDNSSEC, Points: 0, Remediation: []string{"sign the zone with DNSSEC"}},
+	}
+
+	// 98 of 100 points, an A even with the higher threshold of this policy
+	result := policy.Score(checks)
+	if result.Score != 98 || result.Grade != GradeA || len(result.Categories) != 8 {
+		t.Errorf("Score() = %v %s with %d categories, want 98 A with 8", result.Score, result.Grade, len(result.Categories))
+	}
+
+	if want := []string{"sign the zone with DNSSEC"}; !reflect.DeepEqual(result.Remediation, want) {
+		t.Errorf("Score() remediation = %q, want %q", result.Remediation, want)
+	}
+
+	// Without DMARC the score drops to 68, a D with the lower threshold of this policy
+	checks[3] = Check{Category: CategoryDMARC, Points: 0, Remediation: []string{"publish a DMARC record"}}
+	if result := policy.Score(checks); result.Score != 68 || result.Grade != GradeD {
+		t.Errorf("Score() without DMARC = %v %s, want 68 D", result.Score, result.Grade)
+	}
+}
+
+func TestScore(t *testing.T) {
+	policy := &Policy{
+		Weights:    map[string]float64{CategorySPF: 20, CategoryDMARC: 30, CategoryDKIM: 10, CategoryBIMI: 0},
+		Thresholds: DefaultPolicy().Thresholds,
+	}
+
+	tests := []struct {
+		name        string
+		checks      []Check
+		score       float64
+		grade       string
+		remediation []string
+	}{
+		{
+			name:   "perfect",
+			checks: []Check{{Category: CategorySPF, Points: 1}, {Category: CategoryDMARC, Points: 1}, {Category: CategoryDKIM, Points: 1}},
+			score:  100,
+			grade:  GradeA,
+		},
+		{
+			// The fixes worth the most points come first: DMARC loses 15, DKIM 10 and SPF 5
+			name: "remediation order",
+			checks: []Check{
+				{Category: CategorySPF, Points: 0.75, Remediation: []string{"use -all"}},
+				{Category: CategoryDKIM, Points: 0, Remediation: []string{"publish a DKIM key"}},
+				{Category: CategoryDMARC, Points: 0.5, Remediation: []string{"use p=reject", "add rua="}},
+			},
+			score:       50,
+			grade:       GradeF,
+			remediation: []string{"use p=reject", "add rua=", "publish a DKIM key", "use -all"},
+		},
+		{
+			// The points are clamped to 0..1, and a category without weight is left out
+			name: "clamped",
+			checks: []Check{
+				{Category: CategorySPF, Points: 1.5},
+				{Category: CategoryDMARC, Points: -1, Remediation: []string{"publish a DMARC record"}},
+				{Category: CategoryBIMI, Points: 0, Remediation: []string{"publish a BIMI record"}},
+			},
+			score:       40,
+			grade:       GradeF,
+			remediation: []string{"publish a DMARC record"},
+		},
+		{
+			// Only the categories that were checked count, a failed lookup is not a zero
+			name:   "partial",
+			checks: []Check{{Category: CategorySPF, Points: 1}, {Category: CategoryDKIM, Points: 0.4}},
+			score:  80,
+			grade:  GradeB,
+		},
+		{
+			name:   "rounded",
+			checks: []Check{{Category: CategorySPF, Points: 1}, {Category: CategoryDMARC, Points: 1}, {Category: CategoryDKIM, Points: 1.0 / 3}},
+			score:  88.89,
+			grade:  GradeB,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := policy.Score(test.checks)
+
+			if result.Score != test.score || result.Grade != test.grade {
+				t.Errorf("Score() = %v %s, want %v %s", result.Score, result.Grade, test.score, test.grade)
+			}
+
+			if !reflect.DeepEqual(result.Remediation, test.remediation) {
+				t.Errorf("Score() remediation = %q, want %q", result.Remediation, test.remediation)
+			}
+		})
+	}
+
+	if result := policy.Score([]Check{{Category: CategoryBIMI, Points: 1}}); result != nil {
+		t.Errorf("Score() without a weighted category = %+v, want nil", result)
+	}
+}
+
+func TestGrade(t *testing.T) {
+	policy := DefaultPolicy()
+
+	tests := []struct {
+		score float64
+		grade string
+	}{
+		{score: 100, grade: GradeA},
+		{score: 90, grade: GradeA},
+		{score: 89.99, grade: GradeB},
+		{score: 80, grade: GradeB},
+		{score: 79.99, grade: GradeC},
+		{score: 70, grade: GradeC},
+		{score: 60, grade: GradeD},
+		{score: 59.99, grade: GradeF},
+		{score: 0, grade: GradeF},
+	}
+
+	for _, test := range tests {
+		if grade := policy.Grade(test.score); grade != test.grade {
+			t.Errorf("Grade(%v) = %s, want %s", test.score, grade, test.grade)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	policy := &Policy{Weights: map[string]float64{CategorySPF: 1, CategoryDMARC: 1}, Thresholds: DefaultPolicy().Thresholds}
+
+	var summary Summary
+	summary.Add("a.test", policy.Score([]Check{{Category: CategorySPF, Points: 1}, {Category: CategoryDMARC, Points: 1}}))
+	summary.Add("b.test", policy.Score([]Check{{Category: CategorySPF, Points: 1}, {Category: CategoryDMARC, Points: 0, Remediation: []string{"publish a DMARC record"}}}))
+	summary.Add("c.test", nil)
+	summary.Add("d.test", policy.Score([]Check{{Category: CategoryDMARC, Points: 0.5, Remediation: []string{"use p=reject"}}}))
+
+	if summary.Domains != 3 || summary.Average != 66.67 || !reflect.DeepEqual(summary.Grades, map[string]int{GradeA: 1, GradeF: 2}) {
+		t.Errorf("Summary = %d domains, average %v, grades %v, want 3, 66.67, A 1 F 2", summary.Domains, summary.Average, summary.Grades)
+	}
+
+	want := []CategorySummary{{Name: CategorySPF, Average: 1}, {Name: CategoryDMARC, Average: 0.5, ToFix: 2}}
+	for i := range summary.Categories {
+		summary.Categories[i].domains, summary.Categories[i].sum = 0, 0
+	}
+
+	if !reflect.DeepEqual(summary.Categories, want) {
+		t.Errorf("Categories = %+v, want %+v", summary.Categories, want)
+	}
+
+	var lowest []string
+	for _, domain := range summary.Lowest {
+		lowest = append(lowest, domain.Domain)
+	}
+
+	if want := []string{"b.test", "d.test", "a.test"}; !reflect.DeepEqual(lowest, want) {
+		t.Errorf("Lowest = %q, want %q", lowest, want)
+	}
+
+	var text strings.Builder
+	if err := summary.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(text.String(), "summary of 3 domains: average score 66.7\ngrades: A 1, B 0, C 0, D 0, F 2\n") {
+		t.Errorf("WriteText() =\n%s", text.String())
+	}
+}
@@ -0,0 +1,116 @@
+package score
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Summary aggregates the scores of many domains. The zero value is ready to use.
+type Summary struct {
+	Domains int            `json:"domains"`
+	Average float64        `json:"average"`
+	Grades  map[string]int `json:"grades"`
+	// Categories tell how every category did across the domains, in the order they were first seen.
+	Categories []CategorySummary `json:"categories"`
+	// Lowest are the domains with the lowest scores, the worst first.
+	Lowest []DomainScore `json:"lowest,omitempty"`
+
+	sum   float64
+	index map[string]int
+}
+
+// CategorySummary is how a category did across the domains.
+type CategorySummary struct {
+	Name string `json:"name"`
+	// Average are the average points of the category, from 0 to 1.
+	Average float64 `json:"average"`
+	// ToFix is the number of domains with a remediation for the category.
+	ToFix int `json:"toFix"`
+
+	domains int
+	sum     float64
+}
+
+// DomainScore is the score of a single domain.
+type DomainScore struct {
+	Domain string  `json:"domain"`
+	Score  float64 `json:"score"`
+	Grade  string  `json:"grade"`
+}
+
+// maxLowest is the number of domains kept in Summary.Lowest
+const maxLowest = 10
+
+// Add counts the score of the domain. A nil result is ignored.
+func (s *Summary) Add(domain string, result *Result) {
+	if result == nil {
+		return
+	}
+
+	if s.Grades == nil {
+		s.Grades = make(map[string]int)
+		s.index = make(map[string]int)
+	}
+
+	s.Domains++
+	s.sum += result.Score
+	s.Average = round(s.sum / float64(s.Domains))
+	s.Grades[result.Grade]++
+
+	for _, category := range result.Categories {
+		i, ok := s.index[category.Name]
+		if !ok {
+			i = len(s.Categories)
+			s.index[category.Name] = i
+			s.Categories = append(s.Categories, CategorySummary{Name: category.Name})
+		}
+
+		summary := &s.Categories[i]
+		summary.domains++
+		summary.sum += category.Points
+		summary.Average = round(summary.sum / float64(summary.domains))
+
+		if len(category.Remediation) > 0 {
+			summary.ToFix++
+		}
+	}
+
+	s.Lowest = append(s.Lowest, DomainScore{Domain: domain, Score: result.Score, Grade: result.Grade})
+	sort.SliceStable(s.Lowest, func(i, j int) bool {
+		return s.Lowest[i].Score < s.Lowest[j].Score
+	})
+
+	if len(s.Lowest) > maxLowest {
+		s.Lowest = s.Lowest[:maxLowest]
+	}
+}
+
+// WriteText writes the summary as aligned text tables.
+func (s *Summary) WriteText(w io.Writer) error {
+	grades := make([]string, 0, len(Grades))
+	for _, grade := range Grades {
+		grades = append(grades, fmt.Sprintf("%s %d", grade, s.Grades[grade]))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "summary of %d domains: average score %.1f\n", s.Domains, s.Average)
+	fmt.Fprintf(tw, "grades: %s\n\n", strings.Join(grades, ", "))
+
+	fmt.Fprintln(tw, "category\taverage\tto fix")
+	for _, category := range s.Categories {
+		fmt.Fprintf(tw, "%s\t%.0f%%\t%d\n", category.Name, 100*category.Average, category.ToFix)
+	}
+
+	if len(s.Lowest) > 0 {
+		fmt.Fprintln(tw, "\nlowest scores")
+		for _, domain := range s.Lowest {
+			fmt.Fprintf(tw, "%s\t%.1f\t%s\n", domain.Domain, domain.Score, domain.Grade)
+		}
+	}
+
+	return tw.Flush()
+}
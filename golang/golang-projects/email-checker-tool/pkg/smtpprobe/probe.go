@@ -0,0 +1,313 @@
+package smtpprobe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// DefaultPort is the port MX hosts accept mail on
+const DefaultPort = "25"
+
+// Resolver looks up the addresses of the MX hosts.
+type Resolver interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+// Prober connects to MX hosts and records what they support. It never sends mail.
+type Prober struct {
+	// HELOName is the name sent with EHLO, "localhost" when empty.
+	HELOName string
+	// Port is the port the MX hosts are probed on, DefaultPort when empty.
+	Port string
+	// Timeout bounds the whole dialogue with a single host. Zero means no timeout.
+	Timeout time.Duration
+	// RootCAs are used to verify the certificates, the system pool when nil.
+	RootCAs *x509.CertPool
+	// Dialer opens the connections, a zero net.Dialer when nil.
+	Dialer *net.Dialer
+	// Resolver looks up the addresses of the hosts. The dialer resolves them when it is nil.
+	Resolver Resolver
+}
+
+// Certificate describes the certificate an MX host presented after STARTTLS.
+type Certificate struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	DNSNames  []string  `json:"dnsNames,omitempty"`
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+	// DaysLeft is the number of days until the certificate expires, negative once expired.
+	DaysLeft int  `json:"daysLeft"`
+	Valid    bool `json:"valid"`
+	// VerifyError tells why the certificate is not valid for the host.
+	VerifyError string `json:"verifyError,omitempty"`
+}
+
+// TLSInfo describes the TLS session negotiated with STARTTLS.
+type TLSInfo struct {
+	Version     string       `json:"version"`
+	CipherSuite string       `json:"cipherSuite"`
+	Certificate *Certificate `json:"certificate,omitempty"`
+}
+
+// Result is the outcome of probing a single MX host.
+type Result struct {
+	Host       string   `json:"host"`
+	Preference uint16   `json:"preference"`
+	Addr       string   `json:"addr,omitempty"`
+	Reachable  bool     `json:"reachable"`
+	Banner     string   `json:"banner,omitempty"`
+	Extensions []string `json:"extensions,omitempty"`
+	STARTTLS   bool     `json:"starttls"`
+	TLS        *TLSInfo `json:"tls,omitempty"`
+	// Error tells at which step the dialogue failed.
+	Error string `json:"error,omitempty"`
+	// LatencyMS is how long the whole dialogue took, in milliseconds.
+	LatencyMS int64 `json:"latencyMs"`
+}
+
+// ProbeAll probes every MX host in preference order.
+func (p *Prober) ProbeAll(ctx context.Context, mxRecords []*net.MX) []Result {
+	results := make([]Result, 0, len(mxRecords))
+
+	for _, mx := range mxRecords {
+		// A null MX (RFC 7505) tells that the domain does not accept mail
+		if mx.Host == "." || mx.Host == "" {
+			continue
+		}
+
+		result := p.Probe(ctx, strings.TrimSuffix(mx.Host, "."))
+		result.Preference = mx.Pref
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// Probe connects to the host, sends EHLO, records the extensions and tests STARTTLS.
+func (p *Prober) Probe(ctx context.Context, host string) Result {
+	result := Result{Host: host}
+	start := time.Now()
+
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	err := p.probe(ctx, host, &result)
+	result.LatencyMS = time.Since(start).Milliseconds()
+
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// probe runs the SMTP dialogue and fills the result as it goes
+func (p *Prober) probe(ctx context.Context, host string, result *Result) error {
+	conn, err := p.dial(ctx, host)
+	if err != nil {
+		return fmt.Errorf("connect: %v", err)
+	}
+	defer conn.Close()
+
+	// The context deadline also bounds every read and write of the dialogue
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	result.Addr = conn.RemoteAddr().String()
+	text := textproto.NewConn(conn)
+
+	_, banner, err := text.ReadResponse(220)
+	if err != nil {
+		return fmt.Errorf("banner: %v", err)
+	}
+
+	result.Reachable = true
+	result.Banner = banner
+
+	extensions, err := p.ehlo(text)
+	if err != nil {
+		return err
+	}
+
+	result.Extensions = extensions
+	result.STARTTLS = hasExtension(extensions, "STARTTLS")
+
+	if !result.STARTTLS {
+		quit(text)
+		return nil
+	}
+
+	tlsConn, err := startTLS(ctx, text, conn, host)
+	if err != nil {
+		return err
+	}
+
+	result.TLS = p.inspect(host, tlsConn.ConnectionState())
+
+	// The extensions must be asked again once the session is encrypted
+	text = textproto.NewConn(tlsConn)
+	if _, err := p.ehlo(text); err != nil {
+		return fmt.Errorf("after starttls: %v", err)
+	}
+
+	quit(text)
+	return nil
+}
+
+// dial connects to the first address of the host that answers
+func (p *Prober) dial(ctx context.Context, host string) (net.Conn, error) {
+	dialer := p.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+
+	port := p.Port
+	if port == "" {
+		port = DefaultPort
+	}
+
+	if p.Resolver == nil {
+		return dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	}
+
+	addresses, err := p.Resolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("%s has no address", host)
+	}
+
+	for _, address := range addresses {
+		var conn net.Conn
+		if conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(address.String(), port)); err == nil {
+			return conn, nil
+		}
+	}
+
+	return nil, err
+}
+
+// startTLS upgrades the connection. The certificate is not verified here so that
+// an invalid one is reported rather than aborting the dialogue.
+func startTLS(ctx context.Context, text *textproto.Conn, conn net.Conn, host string) (*tls.Conn, error) {
+	id, err := text.Cmd("STARTTLS")
+	if err != nil {
+		return nil, fmt.Errorf("starttls: %v", err)
+	}
+
+	text.StartResponse(id)
+	_, _, err = text.ReadResponse(220)
+	text.EndResponse(id)
+
+	if err != nil {
+		return nil, fmt.Errorf("starttls: %v", err)
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("tls handshake: %v", err)
+	}
+
+	return tlsConn, nil
+}
+
+// ehlo sends EHLO and returns the extensions advertised by the host
+func (p *Prober) ehlo(text *textproto.Conn) ([]string, error) {
+	name := p.HELOName
+	if name == "" {
+		name = "localhost"
+	}
+
+	id, err := text.Cmd("EHLO %s", name)
+	if err != nil {
+		return nil, fmt.Errorf("ehlo: %v", err)
+	}
+
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+
+	_, message, err := text.ReadResponse(250)
+	if err != nil {
+		return nil, fmt.Errorf("ehlo: %v", err)
+	}
+
+	// The first line is the greeting, the others are the extensions
+	lines := strings.Split(message, "\n")
+	return lines[1:], nil
+}
+
+// inspect describes the TLS session and verifies the certificate against the host
+func (p *Prober) inspect(host string, state tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		return info
+	}
+
+	leaf := state.PeerCertificates[0]
+	certificate := &Certificate{
+		Subject:   leaf.Subject.String(),
+		Issuer:    leaf.Issuer.String(),
+		DNSNames:  leaf.DNSNames,
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+		DaysLeft:  int(time.Until(leaf.NotAfter).Hours() / 24),
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         p.RootCAs,
+		Intermediates: intermediates,
+	})
+
+	if err != nil {
+		certificate.VerifyError = err.Error()
+	} else {
+		certificate.Valid = true
+	}
+
+	info.Certificate = certificate
+	return info
+}
+
+// quit ends the dialogue politely, its errors do not matter anymore
+func quit(text *textproto.Conn) {
+	if id, err := text.Cmd("QUIT"); err == nil {
+		text.StartResponse(id)
+		text.ReadResponse(221)
+		text.EndResponse(id)
+	}
+}
+
+// hasExtension tells if the extension is in the EHLO response
+func hasExtension(extensions []string, name string) bool {
+	for _, extension := range extensions {
+		fields := strings.Fields(extension)
+		if len(fields) > 0 && strings.EqualFold(fields[0], name) {
+			return true
+		}
+	}
+
+	return false
+}
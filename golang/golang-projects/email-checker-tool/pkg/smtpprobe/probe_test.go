@@ -0,0 +1,178 @@
+package smtpprobe
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/smtptest"
+)
+
+// fakeResolver sends every host but "unknown.example.test" to the loopback address
+type fakeResolver struct{}
+
+func (fakeResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	if host == "unknown.example.test" {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return []net.IP{net.IPv4(127, 0, 0, 1)}, nil
+}
+
+// startServer serves smtptest until the test ends and returns a Prober that reaches it
+// for any host. With tlsHosts, STARTTLS is offered with a certificate valid for them.
+func startServer(t *testing.T, config smtptest.Config, tlsHosts ...string) *Prober {
+	t.Helper()
+
+	var rootCAs *x509.CertPool
+	if len(tlsHosts) > 0 {
+		tlsConfig, pool, err := smtptest.SelfSignedTLS(24*time.Hour, tlsHosts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config.TLS, rootCAs = tlsConfig, pool
+	}
+
+	server, err := smtptest.Start("127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	_, port, _ := net.SplitHostPort(server.Addr())
+
+	return &Prober{
+		HELOName: "probe.example.test",
+		Port:     port,
+		Timeout:  2 * time.Second,
+		RootCAs:  rootCAs,
+		Resolver: fakeResolver{},
+	}
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name       string
+		tlsHosts   []string
+		host       string
+		reachable  bool
+		starttls   bool
+		valid      bool
+		extensions []string
+		err        string
+	}{
+		{name: "plain", host: "mx.example.test", reachable: true, extensions: []string{"SIZE 10240000", "8BITMIME"}},
+		{name: "valid certificate", tlsHosts: []string{"mx.example.test"}, host: "mx.example.test", reachable: true, starttls: true, valid: true, extensions: []string{"SIZE 10240000", "8BITMIME", "STARTTLS"}},
+		{name: "certificate of another host", tlsHosts: []string{"other.example.test"}, host: "mx.example.test", reachable: true, starttls: true, extensions: []string{"SIZE 10240000", "8BITMIME", "STARTTLS"}},
+		{name: "unknown host", host: "unknown.example.test", err: "connect: lookup unknown.example.test: no such host"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prober := startServer(t, smtptest.Config{Extensions: []string{"SIZE 10240000", "8BITMIME"}}, test.tlsHosts...)
+			result := prober.Probe(context.Background(), test.host)
+
+			if result.Reachable != test.reachable || result.STARTTLS != test.starttls || result.Error != test.err {
+				t.Fatalf("Probe(%s) = reachable %t, starttls %t, error %q, want %t, %t, %q",
+					test.host, result.Reachable, result.STARTTLS, result.Error, test.reachable, test.starttls, test.err)
+			}
+
+			if !reflect.DeepEqual(result.Extensions, test.extensions) {
+				t.Errorf("Probe(%s) extensions = %q, want %q", test.host, result.Extensions, test.extensions)
+			}
+
+			if !test.starttls {
+				if result.TLS != nil {
+					t.Errorf("Probe(%s) TLS = %+v, want none", test.host, result.TLS)
+				}
+				return
+			}
+
+			if result.TLS == nil || result.TLS.Certificate == nil {
+				t.Fatalf("Probe(%s) TLS = %+v, want the certificate", test.host, result.TLS)
+			}
+
+			certificate := result.TLS.Certificate
+			if certificate.Valid != test.valid || (certificate.VerifyError == "") != test.valid {
+				t.Errorf("Probe(%s) certificate valid = %t (%s), want %t", test.host, certificate.Valid, certificate.VerifyError, test.valid)
+			}
+
+			if certificate.DaysLeft < 0 || certificate.DaysLeft > 1 {
+				t.Errorf("Probe(%s) DaysLeft = %d, want 0 or 1", test.host, certificate.DaysLeft)
+			}
+		})
+	}
+}
+
+func TestProbeAll(t *testing.T) {
+	prober := startServer(t, smtptest.Config{})
+
+	mxRecords := []*net.MX{
+		{Host: "mx1.example.test.", Pref: 10},
+		{Host: ".", Pref: 0},
+		{Host: "unknown.example.test.", Pref: 20},
+	}
+
+	results := prober.ProbeAll(context.Background(), mxRecords)
+	if len(results) != 2 {
+		t.Fatalf("ProbeAll() = %+v, want 2 results without the null MX", results)
+	}
+
+	if results[0].Host != "mx1.example.test" || results[0].Preference != 10 || !results[0].Reachable {
+		t.Errorf("ProbeAll()[0] = %+v, want mx1.example.test reachable", results[0])
+	}
+
+	if results[1].Host != "unknown.example.test" || results[1].Preference != 20 || results[1].Reachable {
+		t.Errorf("ProbeAll()[1] = %+v, want unknown.example.test unreachable", results[1])
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   smtptest.Config
+		tlsHosts []string
+		codes    []int
+	}{
+		{name: "mailboxes", config: smtptest.Config{Mailboxes: []string{"alice@example.test"}}, codes: []int{250, 550}},
+		{name: "over starttls", config: smtptest.Config{Mailboxes: []string{"ALICE@example.test"}}, tlsHosts: []string{"mx.example.test"}, codes: []int{250, 550}},
+		{name: "catch-all", config: smtptest.Config{CatchAll: true}, codes: []int{250, 250}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prober := startServer(t, test.config, test.tlsHosts...)
+
+			replies, err := prober.Recipients(context.Background(), "mx.example.test", "", []string{"alice@example.test", "bob@example.test"})
+			if err != nil {
+				t.Fatalf("Recipients() = %v", err)
+			}
+
+			var codes []int
+			for _, reply := range replies {
+				codes = append(codes, reply.Code)
+			}
+
+			if !reflect.DeepEqual(codes, test.codes) {
+				t.Errorf("Recipients() = %+v, want the codes %v", replies, test.codes)
+			}
+		})
+	}
+}
+
+func TestRecipientsUnreachable(t *testing.T) {
+	prober := startServer(t, smtptest.Config{})
+
+	replies, err := prober.Recipients(context.Background(), "unknown.example.test", "", []string{"alice@example.test"})
+	if err == nil || !strings.HasPrefix(err.Error(), "connect: ") || len(replies) != 0 {
+		t.Errorf("Recipients() = %v, %v, want a connect error", replies, err)
+	}
+}
@@ -0,0 +1,111 @@
+package smtpprobe
+
+import (
+	"context"
+	"fmt"
+	"net/textproto"
+)
+
+// Reply is the reply of an SMTP server to a command.
+type Reply struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Accepted tells if the command succeeded, i.e. the reply is 2xx.
+func (r Reply) Accepted() bool {
+	return r.Code >= 200 && r.Code < 300
+}
+
+// Permanent tells if the command failed for good, i.e. the reply is 5xx.
+func (r Reply) Permanent() bool {
+	return r.Code >= 500 && r.Code < 600
+}
+
+// Recipients opens a session with the host, sends MAIL FROM and a RCPT TO for
+// every recipient, and returns the reply to each of them. The session is reset
+// before DATA, so no message is ever sent. An empty from is the null reverse-path.
+func (p *Prober) Recipients(ctx context.Context, host, from string, recipients []string) ([]Reply, error) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	conn, err := p.dial(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %v", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	text := textproto.NewConn(conn)
+
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return nil, fmt.Errorf("banner: %v", err)
+	}
+
+	extensions, err := p.ehlo(text)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some hosts only accept recipients over an encrypted session
+	if hasExtension(extensions, "STARTTLS") {
+		tlsConn, err := startTLS(ctx, text, conn, host)
+		if err != nil {
+			return nil, err
+		}
+
+		text = textproto.NewConn(tlsConn)
+		if _, err := p.ehlo(text); err != nil {
+			return nil, fmt.Errorf("after starttls: %v", err)
+		}
+	}
+
+	reply, err := command(text, "MAIL FROM:<%s>", from)
+	if err != nil {
+		return nil, fmt.Errorf("mail from: %v", err)
+	}
+
+	if !reply.Accepted() {
+		return nil, fmt.Errorf("mail from: %d %s", reply.Code, reply.Message)
+	}
+
+	replies := make([]Reply, 0, len(recipients))
+	for _, recipient := range recipients {
+		reply, err := command(text, "RCPT TO:<%s>", recipient)
+		if err != nil {
+			return replies, fmt.Errorf("rcpt to: %v", err)
+		}
+
+		replies = append(replies, reply)
+	}
+
+	command(text, "RSET")
+	quit(text)
+
+	return replies, nil
+}
+
+// command sends a command and returns its reply whatever the code is.
+// The error is only set when the dialogue itself failed.
+func command(text *textproto.Conn, format string, args ...interface{}) (Reply, error) {
+	id, err := text.Cmd(format, args...)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+
+	code, message, err := text.ReadResponse(0)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	return Reply{Code: code, Message: message}, nil
+}
@@ -0,0 +1,253 @@
+package smtptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config describes how the fake SMTP server behaves.
+type Config struct {
+	// Hostname is announced in the banner and the EHLO response, "mx.example.test" when empty.
+	Hostname string
+	// Extensions are advertised in the EHLO response. STARTTLS is added when TLS is set.
+	Extensions []string
+	// TLS enables STARTTLS with this configuration.
+	TLS *tls.Config
+	// Mailboxes are the addresses accepted by RCPT TO, the others are rejected with 550.
+	Mailboxes []string
+	// CatchAll accepts every recipient.
+	CatchAll bool
+}
+
+// Server is an in-process SMTP server that only speaks enough of the protocol
+// to be probed and to answer RCPT TO. It never accepts a message.
+type Server struct {
+	config   Config
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+// Start listens on addr, e.g. "127.0.0.1:0", and serves in the background.
+func Start(addr string, config Config) (*Server, error) {
+	if config.Hostname == "" {
+		config.Hostname = "mx.example.test"
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{config: config, listener: listener}
+
+	server.wg.Add(1)
+	go server.serve()
+
+	return server, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and waits for the open sessions to end.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+// serve accepts the connections until the listener is closed
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+
+			// Keeps a stuck client from blocking Close forever
+			conn.SetDeadline(time.Now().Add(30 * time.Second))
+			s.session(conn)
+		}()
+	}
+}
+
+// session runs the dialogue with a single client
+func (s *Server) session(conn net.Conn) {
+	text := textproto.NewConn(conn)
+	encrypted := false
+
+	text.PrintfLine("220 %s ESMTP smtptest", s.config.Hostname)
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb := line
+		if fields := strings.Fields(line); len(fields) > 0 {
+			verb = strings.ToUpper(fields[0])
+		}
+
+		switch verb {
+		case "EHLO":
+			lines := []string{s.config.Hostname + " greets you"}
+			lines = append(lines, s.config.Extensions...)
+
+			if s.config.TLS != nil && !encrypted {
+				lines = append(lines, "STARTTLS")
+			}
+
+			writeMultiline(text, 250, lines)
+
+		case "HELO":
+			text.PrintfLine("250 %s", s.config.Hostname)
+
+		case "STARTTLS":
+			if s.config.TLS == nil || encrypted {
+				text.PrintfLine("502 5.5.1 STARTTLS not available")
+				continue
+			}
+
+			text.PrintfLine("220 2.0.0 Ready to start TLS")
+
+			tlsConn := tls.Server(conn, s.config.TLS)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+
+			conn = tlsConn
+			text = textproto.NewConn(tlsConn)
+			encrypted = true
+
+		case "MAIL":
+			text.PrintfLine("250 2.1.0 OK")
+
+		case "RCPT":
+			if s.accepts(line) {
+				text.PrintfLine("250 2.1.5 OK")
+			} else {
+				text.PrintfLine("550 5.1.1 No such user here")
+			}
+
+		case "DATA":
+			text.PrintfLine("554 5.5.1 smtptest never accepts a message")
+
+		case "NOOP", "RSET":
+			text.PrintfLine("250 2.0.0 OK")
+
+		case "QUIT":
+			text.PrintfLine("221 2.0.0 Bye")
+			return
+
+		default:
+			text.PrintfLine("502 5.5.2 Command not implemented")
+		}
+	}
+}
+
+// accepts tells if the recipient of the "RCPT TO:<address>" command is one of the mailboxes
+func (s *Server) accepts(line string) bool {
+	if s.config.CatchAll {
+		return true
+	}
+
+	recipient := line
+	if start := strings.IndexByte(line, '<'); start >= 0 {
+		if end := strings.IndexByte(line[start:], '>'); end > 0 {
+			recipient = line[start+1 : start+end]
+		}
+	}
+
+	for _, mailbox := range s.config.Mailboxes {
+		if strings.EqualFold(mailbox, recipient) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeMultiline writes "250-line" for every line but the last one, which is "250 line"
+func writeMultiline(text *textproto.Conn, code int, lines []string) {
+	for i, line := range lines {
+		separator := "-"
+		if i == len(lines)-1 {
+			separator = " "
+		}
+
+		text.PrintfLine("%d%s%s", code, separator, line)
+	}
+}
+
+// SelfSignedTLS returns a TLS configuration with a certificate for the hosts that
+// is valid for the given duration, and the pool that trusts it.
+func SelfSignedTLS(validFor time.Duration, hosts ...string) (*tls.Config, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "smtptest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}},
+	}
+
+	return config, pool, nil
+}
@@ -0,0 +1,210 @@
+package spf
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// Analysis describes the SPF record of a domain with the records it includes
+// or redirects to, without evaluating it for a specific IP address.
+type Analysis struct {
+	Domain string `json:"domain"`
+	// Via is the term that led to this record: include or redirect. It is empty for the checked domain.
+	Via    string `json:"via,omitempty"`
+	Record string `json:"record,omitempty"`
+	// Lookups and VoidLookups are the totals of the whole tree, they are only set on the checked domain.
+	Lookups     int         `json:"lookups,omitempty"`
+	VoidLookups int         `json:"voidLookups,omitempty"`
+	Errors      []string    `json:"errors,omitempty"`
+	Includes    []*Analysis `json:"includes,omitempty"`
+}
+
+// AllErrors returns the errors of the whole tree, prefixed with the domain they were found in.
+func (a *Analysis) AllErrors() []string {
+	var errs []string
+
+	for _, err := range a.Errors {
+		errs = append(errs, a.Domain+": "+err)
+	}
+
+	for _, include := range a.Includes {
+		errs = append(errs, include.AllErrors()...)
+	}
+
+	return errs
+}
+
+// analyzer holds the counters shared by the whole tree
+type analyzer struct {
+	checker     *Checker
+	lookups     int
+	voidLookups int
+	exceeded    bool
+}
+
+// Analyze parses the SPF record of the domain, expands its include and redirect terms
+// recursively and reports syntax errors and the DNS lookup limits being exceeded.
+// It returns nil if the domain does not have an SPF record.
+func (c *Checker) Analyze(ctx context.Context, domain string) *Analysis {
+	a := &analyzer{checker: c}
+	analysis := a.analyze(ctx, strings.TrimSuffix(domain, "."), "", map[string]bool{})
+
+	if analysis.Record == "" && len(analysis.Errors) == 0 {
+		return nil
+	}
+
+	analysis.Lookups = a.lookups
+	analysis.VoidLookups = a.voidLookups
+
+	if max := c.maxLookups(); a.lookups > max {
+		analysis.Errors = append(analysis.Errors, fmt.Sprintf("%d DNS lookups, the limit is %d", a.lookups, max))
+	}
+
+	if max := c.maxVoidLookups(); a.voidLookups > max {
+		analysis.Errors = append(analysis.Errors, fmt.Sprintf("%d void DNS lookups, the limit is %d", a.voidLookups, max))
+	}
+
+	return analysis
+}
+
+// analyze builds the analysis of a single record. path holds the domains being
+// analyzed above this one, to stop include loops.
+func (a *analyzer) analyze(ctx context.Context, domain, via string, path map[string]bool) *Analysis {
+	analysis := &Analysis{Domain: domain, Via: via}
+
+	txtRecords, err := a.checker.Resolver.LookupTXT(ctx, domain)
+	if err != nil && !resolver.IsNotFound(err) {
+		analysis.Errors = append(analysis.Errors, fmt.Sprintf("could not look up the TXT records: %v", err))
+		return analysis
+	}
+
+	var spfRecords []string
+	for _, txt := range txtRecords {
+		if IsSPF(txt) {
+			spfRecords = append(spfRecords, txt)
+		}
+	}
+
+	switch len(spfRecords) {
+	case 0:
+		if via != "" {
+			a.voidLookups++
+			analysis.Errors = append(analysis.Errors, "no SPF record")
+		}
+		return analysis
+	case 1:
+	default:
+		analysis.Errors = append(analysis.Errors, fmt.Sprintf("%d SPF records, only one is allowed", len(spfRecords)))
+	}
+
+	analysis.Record = spfRecords[0]
+
+	record, err := Parse(analysis.Record)
+	if err != nil {
+		analysis.Errors = append(analysis.Errors, err.Error())
+		return analysis
+	}
+
+	path[domain] = true
+	defer delete(path, domain)
+
+	for _, mechanism := range record.Mechanisms {
+		if mechanism.countsLookup() {
+			a.lookups++
+		}
+
+		// Targets with macros depend on the sender and can only be resolved by CheckHost
+		target := domain
+		if mechanism.Domain != "" {
+			if strings.Contains(mechanism.Domain, "%") {
+				continue
+			}
+			target = strings.TrimSuffix(mechanism.Domain, ".")
+		}
+
+		switch mechanism.Name {
+		case MechanismInclude:
+			analysis.Includes = append(analysis.Includes, a.follow(ctx, target, MechanismInclude, path))
+		case MechanismA:
+			if _, err := a.checker.Resolver.LookupIP(ctx, "ip", target); resolver.IsNotFound(err) {
+				a.voidLookups++
+			}
+		case MechanismMX:
+			mxRecords, err := a.checker.Resolver.LookupMX(ctx, target)
+			if resolver.IsNotFound(err) {
+				a.voidLookups++
+			}
+
+			if len(mxRecords) > maxMXNames {
+				analysis.Errors = append(analysis.Errors, fmt.Sprintf("%s has %d MX records, mx allows at most %d", target, len(mxRecords), maxMXNames))
+			}
+		case MechanismExists:
+			if _, err := a.checker.Resolver.LookupIP(ctx, "ip4", target); resolver.IsNotFound(err) {
+				a.voidLookups++
+			}
+		case MechanismPTR:
+			analysis.Errors = append(analysis.Errors, "the ptr mechanism is deprecated and should not be used")
+		}
+	}
+
+	if record.Redirect != "" {
+		a.lookups++
+
+		if hasAll(record) {
+			analysis.Errors = append(analysis.Errors, "redirect is ignored because the record has an all mechanism")
+		} else if !strings.Contains(record.Redirect, "%") {
+			analysis.Includes = append(analysis.Includes, a.follow(ctx, strings.TrimSuffix(record.Redirect, "."), ModifierRedirect, path))
+		}
+	}
+
+	return analysis
+}
+
+// follow analyzes the record of an include or redirect target
+func (a *analyzer) follow(ctx context.Context, target, via string, path map[string]bool) *Analysis {
+	if path[target] {
+		return &Analysis{Domain: target, Via: via, Errors: []string{"include loop"}}
+	}
+
+	// Stops expanding once the limit is exceeded, the evaluation would stop there too
+	if a.lookups > a.checker.maxLookups() {
+		if !a.exceeded {
+			a.exceeded = true
+			return &Analysis{Domain: target, Via: via, Errors: []string{"not expanded, the DNS lookup limit is already exceeded"}}
+		}
+
+		return &Analysis{Domain: target, Via: via}
+	}
+
+	return a.analyze(ctx, target, via, path)
+}
+
+// hasAll tells if the record has an all mechanism
+func hasAll(record *Record) bool {
+	for _, mechanism := range record.Mechanisms {
+		if mechanism.Name == MechanismAll {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Checker) maxLookups() int {
+	if c.MaxLookups > 0 {
+		return c.MaxLookups
+	}
+
+	return DefaultMaxLookups
+}
+
+func (c *Checker) maxVoidLookups() int {
+	if c.MaxVoidLookups > 0 {
+		return c.MaxVoidLookups
+	}
+
+	return DefaultMaxVoidLookups
+}
@@ -0,0 +1,489 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// Result is the result of check_host() defined by RFC 7208 section 2.6.
+type Result string
+
+// Results of check_host()
+const (
+	None      Result = "none"
+	Neutral   Result = "neutral"
+	Pass      Result = "pass"
+	Fail      Result = "fail"
+	SoftFail  Result = "softfail"
+	TempError Result = "temperror"
+	PermError Result = "permerror"
+)
+
+// Limits of RFC 7208 section 4.6.4
+const (
+	DefaultMaxLookups     = 10
+	DefaultMaxVoidLookups = 2
+	maxMXNames            = 10
+	maxPTRNames           = 10
+)
+
+// Resolver looks up the records needed to evaluate an SPF record.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// Checker evaluates SPF records.
+type Checker struct {
+	Resolver Resolver
+	// MaxLookups is the number of terms causing DNS lookups allowed in one evaluation, 10 when zero.
+	MaxLookups int
+	// MaxVoidLookups is the number of lookups returning no answer allowed in one evaluation, 2 when zero.
+	MaxVoidLookups int
+	// Receiver is the domain of the host running the check, used by the r macro of explanations.
+	Receiver string
+}
+
+// Verdict is the outcome of CheckHost.
+type Verdict struct {
+	Result Result `json:"result"`
+	// Mechanism is the mechanism that matched, if any.
+	Mechanism string `json:"mechanism,omitempty"`
+	// Explanation is the expanded exp string of a fail result.
+	Explanation string `json:"explanation,omitempty"`
+	// Reason tells why the result is a temperror or a permerror.
+	Reason      string `json:"reason,omitempty"`
+	Lookups     int    `json:"lookups"`
+	VoidLookups int    `json:"voidLookups"`
+}
+
+// evaluation holds the state shared by the nested check_host() calls of a single check
+type evaluation struct {
+	checker     *Checker
+	ip          net.IP
+	sender      string
+	helo        string
+	lookups     int
+	voidLookups int
+}
+
+// errorResult is returned from the evaluation to stop it with a temperror or permerror
+type errorResult struct {
+	result Result
+	reason string
+}
+
+func (e *errorResult) Error() string {
+	return string(e.result) + ": " + e.reason
+}
+
+func permError(format string, args ...interface{}) error {
+	return &errorResult{result: PermError, reason: fmt.Sprintf(format, args...)}
+}
+
+func tempError(format string, args ...interface{}) error {
+	return &errorResult{result: TempError, reason: fmt.Sprintf(format, args...)}
+}
+
+// CheckHost evaluates the SPF record of the domain for a message sent from ip by the
+// sender (the MAIL FROM address, or empty for bounces) after the HELO/EHLO helo.
+func (c *Checker) CheckHost(ctx context.Context, ip net.IP, domain, sender, helo string) Verdict {
+	if sender == "" {
+		sender = "postmaster@" + helo
+	}
+
+	e := &evaluation{checker: c, ip: ip, sender: sender, helo: helo}
+	verdict := Verdict{}
+
+	result, mechanism, explanation, err := e.checkHost(ctx, domain)
+	verdict.Result = result
+	verdict.Mechanism = mechanism
+	verdict.Explanation = explanation
+
+	var errResult *errorResult
+	if errors.As(err, &errResult) {
+		verdict.Result = errResult.result
+		verdict.Reason = errResult.reason
+	}
+
+	verdict.Lookups = e.lookups
+	verdict.VoidLookups = e.voidLookups
+
+	return verdict
+}
+
+// checkHost implements check_host() of RFC 7208 section 4
+func (e *evaluation) checkHost(ctx context.Context, domain string) (Result, string, string, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	if !isValidDomain(domain) {
+		return None, "", "", nil
+	}
+
+	record, err := e.fetchRecord(ctx, domain)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if record == nil {
+		return None, "", "", nil
+	}
+
+	macros := &macroContext{
+		sender:   e.sender,
+		domain:   domain,
+		ip:       e.ip,
+		helo:     e.helo,
+		receiver: e.checker.Receiver,
+	}
+	macros.validatedName = func() string {
+		return e.validatedName(ctx, domain)
+	}
+
+	for _, mechanism := range record.Mechanisms {
+		if mechanism.countsLookup() {
+			if err := e.countLookup(); err != nil {
+				return "", "", "", err
+			}
+		}
+
+		matched, err := e.matches(ctx, mechanism, macros)
+		if err != nil {
+			return "", "", "", err
+		}
+
+		if !matched {
+			continue
+		}
+
+		result := mechanism.Qualifier.Result()
+		explanation := ""
+		if result == Fail && record.Explanation != "" {
+			explanation = e.explain(ctx, record.Explanation, macros)
+		}
+
+		return result, mechanism.String(), explanation, nil
+	}
+
+	// The redirect modifier is only used when no mechanism matched, and is ignored if the record has an all mechanism
+	if record.Redirect != "" {
+		if err := e.countLookup(); err != nil {
+			return "", "", "", err
+		}
+
+		target, err := macros.expandDomain(record.Redirect)
+		if err != nil {
+			return "", "", "", permError("invalid redirect %q: %v", record.Redirect, err)
+		}
+
+		result, mechanism, explanation, err := e.checkHost(ctx, target)
+		if err != nil {
+			return "", "", "", err
+		}
+
+		if result == None {
+			return "", "", "", permError("redirect=%s has no SPF record", target)
+		}
+
+		return result, mechanism, explanation, nil
+	}
+
+	return Neutral, "", "", nil
+}
+
+// fetchRecord returns the parsed SPF record of the domain, or nil if it does not have one
+func (e *evaluation) fetchRecord(ctx context.Context, domain string) (*Record, error) {
+	txtRecords, err := e.checker.Resolver.LookupTXT(ctx, domain)
+	if err != nil && !resolver.IsNotFound(err) {
+		return nil, tempError("could not look up the TXT records of %s: %v", domain, err)
+	}
+
+	var spfRecords []string
+	for _, txt := range txtRecords {
+		if IsSPF(txt) {
+			spfRecords = append(spfRecords, txt)
+		}
+	}
+
+	switch len(spfRecords) {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, permError("%s has %d SPF records", domain, len(spfRecords))
+	}
+
+	record, err := Parse(spfRecords[0])
+	if err != nil {
+		return nil, permError("%s: %v", domain, err)
+	}
+
+	return record, nil
+}
+
+// matches tells if the mechanism matches the IP address
+func (e *evaluation) matches(ctx context.Context, mechanism Mechanism, macros *macroContext) (bool, error) {
+	target := macros.domain
+	if mechanism.Domain != "" {
+		var err error
+		if target, err = macros.expandDomain(mechanism.Domain); err != nil {
+			return false, permError("invalid %s: %v", mechanism.Name, err)
+		}
+	}
+
+	switch mechanism.Name {
+	case MechanismAll:
+		return true, nil
+
+	case MechanismIP4, MechanismIP6:
+		return mechanism.Network.Contains(normalizeIP(e.ip)), nil
+
+	case MechanismA:
+		ips, err := e.lookupIP(ctx, target)
+		if err != nil {
+			return false, err
+		}
+
+		return e.containsIP(ips, mechanism), nil
+
+	case MechanismMX:
+		mxRecords, err := e.checker.Resolver.LookupMX(ctx, target)
+		if err != nil {
+			if !resolver.IsNotFound(err) {
+				return false, tempError("could not look up the MX records of %s: %v", target, err)
+			}
+
+			return false, e.countVoidLookup()
+		}
+
+		if len(mxRecords) > maxMXNames {
+			return false, permError("%s has more than %d MX records", target, maxMXNames)
+		}
+
+		for _, mx := range mxRecords {
+			ips, err := e.lookupIP(ctx, strings.TrimSuffix(mx.Host, "."))
+			if err != nil {
+				return false, err
+			}
+
+			if e.containsIP(ips, mechanism) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+
+	case MechanismPTR:
+		for _, name := range e.validatedNames(ctx) {
+			if name == target || strings.HasSuffix(name, "."+target) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+
+	case MechanismExists:
+		// exists always uses an A lookup, even when the client connected over IPv6
+		ips, err := e.checker.Resolver.LookupIP(ctx, "ip4", target)
+		if err != nil {
+			if !resolver.IsNotFound(err) {
+				return false, tempError("could not look up %s: %v", target, err)
+			}
+
+			return false, e.countVoidLookup()
+		}
+
+		return len(ips) > 0, nil
+
+	case MechanismInclude:
+		result, _, _, err := e.checkHost(ctx, target)
+		if err != nil {
+			return false, err
+		}
+
+		switch result {
+		case Pass:
+			return true, nil
+		case None:
+			return false, permError("include:%s has no SPF record", target)
+		default:
+			return false, nil
+		}
+	}
+
+	return false, permError("unknown mechanism %q", mechanism.Name)
+}
+
+// lookupIP looks up the addresses of the same family as the client IP
+func (e *evaluation) lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	network := "ip6"
+	if e.ip.To4() != nil {
+		network = "ip4"
+	}
+
+	ips, err := e.checker.Resolver.LookupIP(ctx, network, host)
+	if err != nil {
+		if !resolver.IsNotFound(err) {
+			return nil, tempError("could not look up %s: %v", host, err)
+		}
+
+		return nil, e.countVoidLookup()
+	}
+
+	return ips, nil
+}
+
+// containsIP tells if the client IP is in the network of any address using the mechanism prefix lengths
+func (e *evaluation) containsIP(ips []net.IP, mechanism Mechanism) bool {
+	client := normalizeIP(e.ip)
+
+	for _, ip := range ips {
+		ip = normalizeIP(ip)
+		if len(ip) != len(client) {
+			continue
+		}
+
+		bits := len(ip) * 8
+		length := mechanism.CIDR6
+		if len(ip) == net.IPv4len {
+			length = mechanism.CIDR4
+		}
+
+		if length < 0 {
+			length = bits
+		}
+
+		network := net.IPNet{IP: ip.Mask(net.CIDRMask(length, bits)), Mask: net.CIDRMask(length, bits)}
+		if network.Contains(client) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validatedNames returns the PTR names of the client IP that resolve back to it
+func (e *evaluation) validatedNames(ctx context.Context) []string {
+	names, err := e.checker.Resolver.LookupAddr(ctx, e.ip.String())
+	if err != nil {
+		return nil
+	}
+
+	if len(names) > maxPTRNames {
+		names = names[:maxPTRNames]
+	}
+
+	var validated []string
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+		ips, err := e.checker.Resolver.LookupIP(ctx, "ip", name)
+		if err != nil {
+			continue
+		}
+
+		for _, ip := range ips {
+			if ip.Equal(e.ip) {
+				validated = append(validated, name)
+				break
+			}
+		}
+	}
+
+	return validated
+}
+
+// validatedName returns the value of the p macro
+func (e *evaluation) validatedName(ctx context.Context, domain string) string {
+	names := e.validatedNames(ctx)
+
+	// Prefers the name within the domain being checked
+	for _, name := range names {
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return name
+		}
+	}
+
+	if len(names) > 0 {
+		return names[0]
+	}
+
+	return "unknown"
+}
+
+// explain returns the expanded explanation string of a fail result, or empty if it cannot be fetched
+func (e *evaluation) explain(ctx context.Context, spec string, macros *macroContext) string {
+	target, err := macros.expandDomain(spec)
+	if err != nil {
+		return ""
+	}
+
+	txtRecords, err := e.checker.Resolver.LookupTXT(ctx, target)
+	if err != nil || len(txtRecords) != 1 {
+		return ""
+	}
+
+	explanation, err := macros.expand(txtRecords[0], true)
+	if err != nil {
+		return ""
+	}
+
+	return explanation
+}
+
+// countLookup counts a term causing DNS lookups
+func (e *evaluation) countLookup() error {
+	e.lookups++
+
+	if max := e.checker.maxLookups(); e.lookups > max {
+		return permError("more than %d DNS lookups", max)
+	}
+
+	return nil
+}
+
+// countVoidLookup counts a lookup that returned no answer
+func (e *evaluation) countVoidLookup() error {
+	e.voidLookups++
+
+	if max := e.checker.maxVoidLookups(); e.voidLookups > max {
+		return permError("more than %d void DNS lookups", max)
+	}
+
+	return nil
+}
+
+// normalizeIP returns the 4-byte form of IPv4 addresses, including the IPv4-mapped IPv6 ones
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+
+	return ip.To16()
+}
+
+// isValidDomain tells if the domain can be used by check_host()
+func isValidDomain(domain string) bool {
+	if domain == "" || len(domain) > maxDomainLength {
+		return false
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+
+	for _, label := range labels {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+	}
+
+	return true
+}
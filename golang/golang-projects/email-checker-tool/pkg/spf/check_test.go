@@ -0,0 +1,163 @@
+package spf
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dnsstub"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// testRecords are the records the evaluation is tested against
+var testRecords = []string{
+	`ip.test. IN TXT "v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 -all"`,
+	`soft.test. IN TXT "v=spf1 ~all"`,
+	`neutral.test. IN TXT "v=spf1 ip4:192.0.2.1"`,
+	`none.test. IN TXT "google-site-verification=abc123"`,
+	`include.test. IN TXT "v=spf1 include:ip.test ?all"`,
+	`redirect.test. IN TXT "v=spf1 redirect=ip.test"`,
+	`mx.test. IN MX 10 mail.mx.test.`,
+	`mail.mx.test. IN A 203.0.113.25`,
+	`mx.test. IN TXT "v=spf1 mx -all"`,
+	`a.test. IN A 203.0.113.5`,
+	`a.test. IN TXT "v=spf1 a/24 -all"`,
+	`multiple.test. IN TXT "v=spf1 -all"`,
+	`multiple.test. IN TXT "v=spf1 ~all"`,
+	`syntax.test. IN TXT "v=spf1 ip4:192.0.2.300 -all"`,
+	`loop.test. IN TXT "v=spf1 include:loop.test -all"`,
+	`void.test. IN TXT "v=spf1 a:void1.test a:void2.test a:void3.test -all"`,
+	`missing-include.test. IN TXT "v=spf1 include:nothing.test -all"`,
+	`bogus-include.test. IN TXT "v=spf1 include:bogus.test -all"`,
+	`macro.test. IN TXT "v=spf1 exists:%{ir}.%{l1r+-}._spf.%{d} -all exp=explain.%{d}"`,
+	`1.2.0.192.strong._spf.macro.test. IN A 127.0.0.2`,
+	`explain.macro.test. IN TXT "%{i} is not allowed to send mail for %{d}"`,
+}
+
+// newTestChecker serves the records on a free port until the test ends, and returns a Checker resolving them
+func newTestChecker(t *testing.T) *Checker {
+	t.Helper()
+
+	zone := dnsstub.NewZone()
+	if err := zone.Add(testRecords...); err != nil {
+		t.Fatal(err)
+	}
+	zone.AddBogus("bogus.test")
+
+	server, err := dnsstub.Start("127.0.0.1:0", zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	return &Checker{Resolver: resolver.NewNameserver(server.Addr(), "udp", 2*time.Second)}
+}
+
+func TestCheckHost(t *testing.T) {
+	checker := newTestChecker(t)
+
+	tests := []struct {
+		name        string
+		ip          string
+		domain      string
+		sender      string
+		result      Result
+		mechanism   string
+		explanation string
+	}{
+		{name: "ip4 pass", ip: "192.0.2.10", domain: "ip.test", result: Pass, mechanism: "ip4:192.0.2.0/24"},
+		{name: "ip6 pass", ip: "2001:db8::25", domain: "ip.test", result: Pass, mechanism: "ip6:2001:db8::/32"},
+		{name: "ipv4-mapped ipv6", ip: "::ffff:192.0.2.10", domain: "ip.test", result: Pass, mechanism: "ip4:192.0.2.0/24"},
+		{name: "fail", ip: "198.51.100.1", domain: "ip.test", result: Fail, mechanism: "-all"},
+		{name: "softfail", ip: "198.51.100.1", domain: "soft.test", result: SoftFail, mechanism: "~all"},
+		{name: "neutral without all", ip: "198.51.100.1", domain: "neutral.test", result: Neutral},
+		{name: "no record", ip: "198.51.100.1", domain: "none.test", result: None},
+		{name: "missing domain", ip: "198.51.100.1", domain: "missing.test", result: None},
+		{name: "include pass", ip: "192.0.2.10", domain: "include.test", result: Pass, mechanism: "include:ip.test"},
+		// An include that does not match goes on with the next mechanism, whatever its result
+		{name: "include no match", ip: "198.51.100.1", domain: "include.test", result: Neutral, mechanism: "?all"},
+		{name: "redirect", ip: "198.51.100.1", domain: "redirect.test", result: Fail, mechanism: "-all"},
+		{name: "mx", ip: "203.0.113.25", domain: "mx.test", result: Pass, mechanism: "mx"},
+		{name: "a with prefix", ip: "203.0.113.200", domain: "a.test", result: Pass, mechanism: "a/24"},
+		{name: "multiple records", ip: "192.0.2.10", domain: "multiple.test", result: PermError},
+		{name: "syntax error", ip: "192.0.2.10", domain: "syntax.test", result: PermError},
+		{name: "include loop", ip: "192.0.2.10", domain: "loop.test", result: PermError},
+		{name: "void lookups", ip: "192.0.2.10", domain: "void.test", result: PermError},
+		{name: "include without record", ip: "192.0.2.10", domain: "missing-include.test", result: PermError},
+		{name: "include failing", ip: "192.0.2.10", domain: "bogus-include.test", result: TempError},
+		{name: "exists macro", ip: "192.0.2.1", domain: "macro.test", sender: "strong-bad@macro.test", result: Pass, mechanism: "exists:%{ir}.%{l1r+-}._spf.%{d}"},
+		{
+			name:        "explanation",
+			ip:          "192.0.2.9",
+			domain:      "macro.test",
+			sender:      "strong-bad@macro.test",
+			result:      Fail,
+			mechanism:   "-all",
+			explanation: "192.0.2.9 is not allowed to send mail for macro.test",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verdict := checker.CheckHost(context.Background(), net.ParseIP(test.ip), test.domain, test.sender, test.domain)
+
+			if verdict.Result != test.result {
+				t.Fatalf("CheckHost(%s, %s) = %s (%s), want %s", test.ip, test.domain, verdict.Result, verdict.Reason, test.result)
+			}
+
+			if verdict.Mechanism != test.mechanism {
+				t.Errorf("CheckHost(%s, %s) mechanism = %q, want %q", test.ip, test.domain, verdict.Mechanism, test.mechanism)
+			}
+
+			if verdict.Explanation != test.explanation {
+				t.Errorf("CheckHost(%s, %s) explanation = %q, want %q", test.ip, test.domain, verdict.Explanation, test.explanation)
+			}
+		})
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	checker := newTestChecker(t)
+
+	tests := []struct {
+		domain   string
+		lookups  int
+		includes int
+		errors   int
+		none     bool
+	}{
+		{domain: "ip.test"},
+		{domain: "include.test", lookups: 1, includes: 1},
+		{domain: "redirect.test", lookups: 1, includes: 1},
+		{domain: "mx.test", lookups: 1},
+		{domain: "multiple.test", errors: 1},
+		{domain: "syntax.test", errors: 1},
+		{domain: "loop.test", lookups: 1, includes: 1, errors: 1},
+		{domain: "none.test", none: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.domain, func(t *testing.T) {
+			analysis := checker.Analyze(context.Background(), test.domain)
+			if analysis == nil {
+				if !test.none {
+					t.Fatalf("Analyze(%s) = nil, want an analysis", test.domain)
+				}
+				return
+			}
+
+			if test.none {
+				t.Fatalf("Analyze(%s) = %+v, want nil", test.domain, analysis)
+			}
+
+			if analysis.Lookups != test.lookups || len(analysis.Includes) != test.includes || len(analysis.AllErrors()) != test.errors {
+				t.Errorf("Analyze(%s) = %d lookups, %d includes, errors %q, want %d lookups, %d includes, %d errors",
+					test.domain, analysis.Lookups, len(analysis.Includes), analysis.AllErrors(), test.lookups, test.includes, test.errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package spf
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDomainLength is the longest domain name a macro expansion can produce
+const maxDomainLength = 253
+
+// macroDelimiters are the characters a macro can split its value on
+const macroDelimiters = ".-+,/_="
+
+// macroToken is either a literal or a macro of a macro string
+type macroToken struct {
+	literal    string
+	letter     byte
+	keep       int
+	reverse    bool
+	delimiters string
+	escape     bool
+}
+
+// parseMacroString splits a macro string (RFC 7208 section 7.1) into its tokens.
+// The c, r and t macro letters are only allowed in explanation strings.
+func parseMacroString(value string, explanation bool) ([]macroToken, error) {
+	var tokens []macroToken
+	var literal strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+
+		if c != '%' {
+			// Visible characters only, except in explanation strings which can contain spaces
+			if (c < 0x21 || c > 0x7e) && !(explanation && c == ' ') {
+				return nil, fmt.Errorf("invalid character %q", c)
+			}
+
+			literal.WriteByte(c)
+			continue
+		}
+
+		if i+1 >= len(value) {
+			return nil, fmt.Errorf("incomplete macro at the end of %q", value)
+		}
+
+		i++
+		switch value[i] {
+		case '%':
+			literal.WriteByte('%')
+		case '_':
+			literal.WriteByte(' ')
+		case '-':
+			literal.WriteString("%20")
+		case '{':
+			end := strings.IndexByte(value[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated macro in %q", value)
+			}
+
+			token, err := parseMacro(value[i+1:i+end], explanation)
+			if err != nil {
+				return nil, err
+			}
+
+			if literal.Len() > 0 {
+				tokens = append(tokens, macroToken{literal: literal.String()})
+				literal.Reset()
+			}
+
+			tokens = append(tokens, token)
+			i += end
+		default:
+			return nil, fmt.Errorf("invalid macro %q", "%"+string(value[i]))
+		}
+	}
+
+	if literal.Len() > 0 {
+		tokens = append(tokens, macroToken{literal: literal.String()})
+	}
+
+	return tokens, nil
+}
+
+// parseMacro parses the content of %{...}: a letter, optional transformers and delimiters
+func parseMacro(body string, explanation bool) (macroToken, error) {
+	if body == "" {
+		return macroToken{}, fmt.Errorf("empty macro")
+	}
+
+	token := macroToken{letter: body[0]}
+
+	// Upper case letters are URL-escaped
+	if 'A' <= token.letter && token.letter <= 'Z' {
+		token.escape = true
+		token.letter += 'a' - 'A'
+	}
+
+	switch token.letter {
+	case 's', 'l', 'o', 'd', 'i', 'p', 'h', 'v':
+	case 'c', 'r', 't':
+		if !explanation {
+			return macroToken{}, fmt.Errorf("macro %q is only allowed in explanations", body[0])
+		}
+	default:
+		return macroToken{}, fmt.Errorf("unknown macro letter %q", body[0])
+	}
+
+	rest := body[1:]
+
+	digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+	if digits > 0 {
+		keep, err := strconv.Atoi(rest[:digits])
+		if err != nil || keep == 0 {
+			return macroToken{}, fmt.Errorf("invalid macro transformer in %%{%s}", body)
+		}
+
+		token.keep = keep
+		rest = rest[digits:]
+	}
+
+	if strings.HasPrefix(rest, "r") {
+		token.reverse = true
+		rest = rest[1:]
+	}
+
+	if strings.Trim(rest, macroDelimiters) != "" {
+		return macroToken{}, fmt.Errorf("invalid macro delimiter in %%{%s}", body)
+	}
+
+	token.delimiters = rest
+	return token, nil
+}
+
+// macroContext holds the values the macro letters expand to
+type macroContext struct {
+	sender   string
+	domain   string
+	ip       net.IP
+	helo     string
+	receiver string
+	// validatedName returns the value of the p macro, it is only called when needed
+	validatedName func() string
+}
+
+// expand expands the macros of the value
+func (m *macroContext) expand(value string, explanation bool) (string, error) {
+	tokens, err := parseMacroString(value, explanation)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, token := range tokens {
+		if token.letter == 0 {
+			b.WriteString(token.literal)
+			continue
+		}
+
+		b.WriteString(token.apply(m.letter(token.letter)))
+	}
+
+	return b.String(), nil
+}
+
+// expandDomain expands a domain-spec and shortens the result to a valid domain length
+func (m *macroContext) expandDomain(value string) (string, error) {
+	domain, err := m.expand(value, false)
+	if err != nil {
+		return "", err
+	}
+
+	domain = strings.TrimSuffix(domain, ".")
+
+	// Drops the left-most labels until the domain is short enough
+	for len(domain) > maxDomainLength {
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+
+	return domain, nil
+}
+
+// letter returns the value of a macro letter
+func (m *macroContext) letter(letter byte) string {
+	localPart, senderDomain := splitSender(m.sender, m.helo)
+
+	switch letter {
+	case 's':
+		return localPart + "@" + senderDomain
+	case 'l':
+		return localPart
+	case 'o':
+		return senderDomain
+	case 'd':
+		return m.domain
+	case 'i':
+		return dottedIP(m.ip)
+	case 'p':
+		if m.validatedName == nil {
+			return "unknown"
+		}
+		return m.validatedName()
+	case 'v':
+		if m.ip.To4() != nil {
+			return "in-addr"
+		}
+		return "ip6"
+	case 'h':
+		return m.helo
+	case 'c':
+		return m.ip.String()
+	case 'r':
+		if m.receiver == "" {
+			return "unknown"
+		}
+		return m.receiver
+	case 't':
+		return strconv.FormatInt(time.Now().Unix(), 10)
+	default:
+		return ""
+	}
+}
+
+// apply transforms the value of the macro letter
+func (t macroToken) apply(value string) string {
+	delimiters := t.delimiters
+	if delimiters == "" {
+		delimiters = "."
+	}
+
+	parts := strings.FieldsFunc(value, func(r rune) bool {
+		return strings.ContainsRune(delimiters, r)
+	})
+
+	if t.reverse {
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+	}
+
+	// Keeps the right-most parts
+	if t.keep > 0 && t.keep < len(parts) {
+		parts = parts[len(parts)-t.keep:]
+	}
+
+	result := strings.Join(parts, ".")
+
+	if t.escape {
+		result = strings.ReplaceAll(url.QueryEscape(result), "+", "%20")
+	}
+
+	return result
+}
+
+// splitSender returns the local-part and domain of the sender. A sender without
+// a local-part uses "postmaster", and an empty sender uses the HELO domain.
+func splitSender(sender, helo string) (string, string) {
+	if sender == "" {
+		return "postmaster", helo
+	}
+
+	i := strings.LastIndexByte(sender, '@')
+	if i < 0 {
+		return "postmaster", sender
+	}
+
+	localPart := sender[:i]
+	if localPart == "" {
+		localPart = "postmaster"
+	}
+
+	return localPart, sender[i+1:]
+}
+
+// dottedIP returns the IPv4 address as is, and the IPv6 address as dot-separated nibbles
+func dottedIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return ""
+	}
+
+	nibbles := make([]string, 0, 32)
+	for _, b := range ip16 {
+		nibbles = append(nibbles, strconv.FormatInt(int64(b>>4), 16), strconv.FormatInt(int64(b&0x0f), 16))
+	}
+
+	return strings.Join(nibbles, ".")
+}
@@ -0,0 +1,101 @@
+package spf
+
+import (
+	"net"
+	"testing"
+)
+
+// The examples of RFC 7208 section 7.4
+func TestMacroExpand(t *testing.T) {
+	macros := &macroContext{
+		sender: "strong-bad@email.example.com",
+		domain: "email.example.com",
+		ip:     net.ParseIP("192.0.2.3"),
+		helo:   "mx.example.org",
+	}
+
+	ipv6 := *macros
+	ipv6.ip = net.ParseIP("2001:db8::cb01")
+
+	tests := []struct {
+		macros *macroContext
+		value  string
+		want   string
+	}{
+		{macros, "%{s}", "strong-bad@email.example.com"},
+		{macros, "%{o}", "email.example.com"},
+		{macros, "%{d}", "email.example.com"},
+		{macros, "%{d4}", "email.example.com"},
+		{macros, "%{d3}", "email.example.com"},
+		{macros, "%{d2}", "example.com"},
+		{macros, "%{d1}", "com"},
+		{macros, "%{dr}", "com.example.email"},
+		{macros, "%{d2r}", "example.email"},
+		{macros, "%{l}", "strong-bad"},
+		{macros, "%{l-}", "strong.bad"},
+		{macros, "%{lr}", "strong-bad"},
+		{macros, "%{lr-}", "bad.strong"},
+		{macros, "%{l1r-}", "strong"},
+		{macros, "%{h}", "mx.example.org"},
+		{macros, "%{v}", "in-addr"},
+		{macros, "%{ir}.%{v}._spf.%{d2}", "3.2.0.192.in-addr._spf.example.com"},
+		{macros, "%{lr-}.lp._spf.%{d2}", "bad.strong.lp._spf.example.com"},
+		{macros, "%{lr-}.lp.%{ir}.%{v}._spf.%{d2}", "bad.strong.lp.3.2.0.192.in-addr._spf.example.com"},
+		{macros, "%{ir}.%{v}.%{l1r-}.lp._spf.%{d2}", "3.2.0.192.in-addr.strong.lp._spf.example.com"},
+		{macros, "%{d2}.trusted-domains.example.net", "example.com.trusted-domains.example.net"},
+		{&ipv6, "%{ir}.%{v}._spf.%{d2}", "1.0.b.c.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6._spf.example.com"},
+		{macros, "%%%_%-", "% %20"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := test.macros.expand(test.value, false)
+			if err != nil {
+				t.Fatalf("expand(%q) = %v", test.value, err)
+			}
+
+			if got != test.want {
+				t.Errorf("expand(%q) = %q, want %q", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMacroExplanation(t *testing.T) {
+	macros := &macroContext{
+		sender:   "strong-bad@email.example.com",
+		domain:   "email.example.com",
+		ip:       net.ParseIP("192.0.2.3"),
+		receiver: "mx.example.org",
+	}
+
+	tests := []struct {
+		value       string
+		explanation bool
+		want        string
+		err         bool
+	}{
+		{value: "%{c} is not allowed by %{r}", explanation: true, want: "192.0.2.3 is not allowed by mx.example.org"},
+		// c, r and t are only allowed in explanations
+		{value: "%{c}.example.com", err: true},
+		{value: "%{z}", err: true},
+		{value: "%{d", err: true},
+		{value: "%x", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := macros.expand(test.value, test.explanation)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expand(%q) = %q, want an error", test.value, got)
+				}
+				return
+			}
+
+			if err != nil || got != test.want {
+				t.Errorf("expand(%q) = %q, %v, want %q", test.value, got, err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package spf
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// dualCIDRSuffix splits the argument of the a and mx mechanisms from its "/24//64" suffix
+var dualCIDRSuffix = regexp.MustCompile(`^(.*?)((?:/[0-9]*)?(?://[0-9]*)?)$`)
+
+// Qualifier is the result a mechanism gives when it matches.
+type Qualifier byte
+
+// Qualifiers defined by RFC 7208 section 4.6.2
+const (
+	QualifierPass     Qualifier = '+'
+	QualifierFail     Qualifier = '-'
+	QualifierSoftFail Qualifier = '~'
+	QualifierNeutral  Qualifier = '?'
+)
+
+// Result returns the check_host() result of a matching mechanism with the qualifier.
+func (q Qualifier) Result() Result {
+	switch q {
+	case QualifierFail:
+		return Fail
+	case QualifierSoftFail:
+		return SoftFail
+	case QualifierNeutral:
+		return Neutral
+	default:
+		return Pass
+	}
+}
+
+// Mechanism names defined by RFC 7208 section 5
+const (
+	MechanismAll     = "all"
+	MechanismInclude = "include"
+	MechanismA       = "a"
+	MechanismMX      = "mx"
+	MechanismPTR     = "ptr"
+	MechanismIP4     = "ip4"
+	MechanismIP6     = "ip6"
+	MechanismExists  = "exists"
+)
+
+// Modifier names defined by RFC 7208 section 6
+const (
+	ModifierRedirect    = "redirect"
+	ModifierExplanation = "exp"
+)
+
+// Mechanism is a single mechanism of an SPF record, e.g. "~all" or "mx:example.com/24".
+type Mechanism struct {
+	Qualifier Qualifier `json:"qualifier"`
+	Name      string    `json:"name"`
+	// Domain is the domain-spec of the mechanism. It can contain macros.
+	Domain string `json:"domain,omitempty"`
+	// Network is the address range of the ip4 and ip6 mechanisms.
+	Network *net.IPNet `json:"-"`
+	// CIDR4 and CIDR6 are the prefix lengths of the a and mx mechanisms, -1 when not set.
+	CIDR4 int `json:"cidr4"`
+	CIDR6 int `json:"cidr6"`
+}
+
+// String returns the mechanism as it would be written in a record.
+func (m Mechanism) String() string {
+	var b strings.Builder
+
+	if m.Qualifier != QualifierPass {
+		b.WriteByte(byte(m.Qualifier))
+	}
+
+	b.WriteString(m.Name)
+
+	switch {
+	case m.Network != nil:
+		b.WriteString(":" + m.Network.String())
+	case m.Domain != "":
+		b.WriteString(":" + m.Domain)
+	}
+
+	if m.CIDR4 >= 0 {
+		b.WriteString("/" + strconv.Itoa(m.CIDR4))
+	}
+
+	if m.CIDR6 >= 0 {
+		b.WriteString("//" + strconv.Itoa(m.CIDR6))
+	}
+
+	return b.String()
+}
+
+// countsLookup tells if evaluating the mechanism counts toward the DNS lookup limit
+func (m Mechanism) countsLookup() bool {
+	switch m.Name {
+	case MechanismInclude, MechanismA, MechanismMX, MechanismPTR, MechanismExists:
+		return true
+	default:
+		return false
+	}
+}
+
+// Modifier is a name=value term of an SPF record that is not redirect or exp.
+type Modifier struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Record is a parsed SPF record.
+type Record struct {
+	Raw        string      `json:"raw"`
+	Mechanisms []Mechanism `json:"mechanisms"`
+	// Redirect is the domain-spec of the redirect modifier, if any.
+	Redirect string `json:"redirect,omitempty"`
+	// Explanation is the domain-spec of the exp modifier, if any.
+	Explanation string `json:"exp,omitempty"`
+	// Modifiers are the unknown modifiers, which are ignored during the evaluation.
+	Modifiers []Modifier `json:"modifiers,omitempty"`
+}
+
+// SyntaxError describes an invalid term of an SPF record.
+type SyntaxError struct {
+	Term   string
+	Reason string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Term == "" {
+		return "spf: " + e.Reason
+	}
+
+	return fmt.Sprintf("spf: invalid term %q: %s", e.Term, e.Reason)
+}
+
+// IsSPF tells if the TXT record is an SPF version 1 record.
+func IsSPF(txt string) bool {
+	if len(txt) < len("v=spf1") || !strings.EqualFold(txt[:len("v=spf1")], "v=spf1") {
+		return false
+	}
+
+	// "v=spf10" is not an SPF version 1 record
+	return len(txt) == len("v=spf1") || txt[len("v=spf1")] == ' '
+}
+
+// Parse parses an SPF record. It returns a *SyntaxError if any term of the record is invalid.
+func Parse(txt string) (*Record, error) {
+	if !IsSPF(txt) {
+		return nil, &SyntaxError{Reason: "record does not start with v=spf1"}
+	}
+
+	record := &Record{Raw: txt}
+
+	for _, term := range strings.Fields(txt[len("v=spf1"):]) {
+		if err := record.parseTerm(term); err != nil {
+			return nil, err
+		}
+	}
+
+	return record, nil
+}
+
+// parseTerm adds a single mechanism or modifier to the record
+func (r *Record) parseTerm(term string) error {
+	// A modifier is name=value where the name does not contain ':' or '/'
+	if i := strings.IndexAny(term, "=:/"); i > 0 && term[i] == '=' {
+		return r.parseModifier(term, term[:i], term[i+1:])
+	}
+
+	mechanism, err := parseMechanism(term)
+	if err != nil {
+		return err
+	}
+
+	r.Mechanisms = append(r.Mechanisms, mechanism)
+	return nil
+}
+
+// parseModifier adds the modifier to the record
+func (r *Record) parseModifier(term, name, value string) error {
+	if !isModifierName(name) {
+		return &SyntaxError{Term: term, Reason: "invalid modifier name"}
+	}
+
+	switch strings.ToLower(name) {
+	case ModifierRedirect:
+		if r.Redirect != "" {
+			return &SyntaxError{Term: term, Reason: "redirect appears more than once"}
+		}
+
+		if err := checkDomainSpec(value); err != nil {
+			return &SyntaxError{Term: term, Reason: err.Error()}
+		}
+
+		r.Redirect = value
+	case ModifierExplanation:
+		if r.Explanation != "" {
+			return &SyntaxError{Term: term, Reason: "exp appears more than once"}
+		}
+
+		if err := checkDomainSpec(value); err != nil {
+			return &SyntaxError{Term: term, Reason: err.Error()}
+		}
+
+		r.Explanation = value
+	default:
+		// Unknown modifiers must still be valid macro strings
+		if _, err := parseMacroString(value, false); err != nil {
+			return &SyntaxError{Term: term, Reason: err.Error()}
+		}
+
+		r.Modifiers = append(r.Modifiers, Modifier{Name: name, Value: value})
+	}
+
+	return nil
+}
+
+// parseMechanism parses a mechanism with its optional qualifier
+func parseMechanism(term string) (Mechanism, error) {
+	mechanism := Mechanism{Qualifier: QualifierPass, CIDR4: -1, CIDR6: -1}
+	rest := term
+
+	switch Qualifier(rest[0]) {
+	case QualifierPass, QualifierFail, QualifierSoftFail, QualifierNeutral:
+		mechanism.Qualifier = Qualifier(rest[0])
+		rest = rest[1:]
+	}
+
+	// Splits the name from its argument, which starts with ':' or '/'
+	name, argument := rest, ""
+	if i := strings.IndexAny(rest, ":/"); i >= 0 {
+		name, argument = rest[:i], rest[i:]
+	}
+
+	mechanism.Name = strings.ToLower(name)
+	invalid := func(reason string) (Mechanism, error) {
+		return Mechanism{}, &SyntaxError{Term: term, Reason: reason}
+	}
+
+	switch mechanism.Name {
+	case MechanismAll:
+		if argument != "" {
+			return invalid("all does not take an argument")
+		}
+
+	case MechanismInclude, MechanismExists:
+		if !strings.HasPrefix(argument, ":") || len(argument) == 1 {
+			return invalid(mechanism.Name + " needs a domain")
+		}
+
+		if err := checkDomainSpec(argument[1:]); err != nil {
+			return invalid(err.Error())
+		}
+
+		mechanism.Domain = argument[1:]
+
+	case MechanismA, MechanismMX, MechanismPTR:
+		domain, cidr := argument, ""
+
+		// Splits the dual-cidr-length from the end of the domain
+		if mechanism.Name != MechanismPTR {
+			match := dualCIDRSuffix.FindStringSubmatch(argument)
+			domain, cidr = match[1], match[2]
+		}
+
+		if domain != "" {
+			if !strings.HasPrefix(domain, ":") || len(domain) == 1 {
+				return invalid("expected ':' followed by a domain")
+			}
+
+			if err := checkDomainSpec(domain[1:]); err != nil {
+				return invalid(err.Error())
+			}
+
+			mechanism.Domain = domain[1:]
+		}
+
+		if cidr != "" {
+			var err error
+			if mechanism.CIDR4, mechanism.CIDR6, err = parseDualCIDR(cidr); err != nil {
+				return invalid(err.Error())
+			}
+		}
+
+	case MechanismIP4, MechanismIP6:
+		if !strings.HasPrefix(argument, ":") {
+			return invalid(mechanism.Name + " needs an address")
+		}
+
+		network, err := parseNetwork(mechanism.Name, argument[1:])
+		if err != nil {
+			return invalid(err.Error())
+		}
+
+		mechanism.Network = network
+
+	default:
+		return invalid("unknown mechanism")
+	}
+
+	return mechanism, nil
+}
+
+// parseNetwork parses the address and optional prefix length of an ip4 or ip6 mechanism
+func parseNetwork(name, value string) (*net.IPNet, error) {
+	address, prefix := value, ""
+	if i := strings.Index(value, "/"); i >= 0 {
+		address, prefix = value[:i], value[i+1:]
+	}
+
+	ip := net.ParseIP(address)
+	bits := 32
+
+	if name == MechanismIP6 {
+		bits = 128
+		if ip == nil || !strings.Contains(address, ":") {
+			return nil, fmt.Errorf("invalid IPv6 address")
+		}
+	} else {
+		if ip == nil || ip.To4() == nil || strings.Contains(address, ":") {
+			return nil, fmt.Errorf("invalid IPv4 address")
+		}
+		ip = ip.To4()
+	}
+
+	length := bits
+	if prefix != "" {
+		var err error
+		if length, err = parseCIDRLength(prefix, bits); err != nil {
+			return nil, err
+		}
+	}
+
+	mask := net.CIDRMask(length, bits)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+}
+
+// parseDualCIDR parses "/24", "//64" or "/24//64"
+func parseDualCIDR(value string) (int, int, error) {
+	cidr4, cidr6 := -1, -1
+	var err error
+
+	if i := strings.Index(value, "//"); i >= 0 {
+		if cidr6, err = parseCIDRLength(value[i+2:], 128); err != nil {
+			return 0, 0, err
+		}
+		value = value[:i]
+	}
+
+	if value != "" {
+		if cidr4, err = parseCIDRLength(strings.TrimPrefix(value, "/"), 32); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return cidr4, cidr6, nil
+}
+
+// parseCIDRLength parses a prefix length that must not be greater than max
+func parseCIDRLength(value string, max int) (int, error) {
+	// Leading zeroes are not allowed by the grammar
+	if value == "" || (len(value) > 1 && value[0] == '0') {
+		return 0, fmt.Errorf("invalid prefix length %q", value)
+	}
+
+	length, err := strconv.Atoi(value)
+	if err != nil || length < 0 || length > max {
+		return 0, fmt.Errorf("invalid prefix length %q", value)
+	}
+
+	return length, nil
+}
+
+// isModifierName tells if the name matches ALPHA *( ALPHA / DIGIT / "-" / "_" / "." )
+func isModifierName(name string) bool {
+	for i, c := range name {
+		isAlpha := ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+		isOther := ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.'
+
+		if !isAlpha && (i == 0 || !isOther) {
+			return false
+		}
+	}
+
+	return name != ""
+}
+
+// checkDomainSpec validates the syntax of a domain-spec
+func checkDomainSpec(spec string) error {
+	if _, err := parseMacroString(spec, false); err != nil {
+		return err
+	}
+
+	// The domain-end must be a top label or a macro
+	if strings.HasSuffix(spec, "}") {
+		return nil
+	}
+
+	labels := strings.Split(strings.TrimSuffix(spec, "."), ".")
+	if len(labels) < 2 {
+		return fmt.Errorf("invalid domain %q", spec)
+	}
+
+	top := labels[len(labels)-1]
+	if top == "" || strings.HasPrefix(top, "-") || strings.HasSuffix(top, "-") || strings.Trim(top, "0123456789") == "" {
+		return fmt.Errorf("invalid top label in domain %q", spec)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package spf
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		record     string
+		mechanisms []string
+		redirect   string
+		err        bool
+	}{
+		{record: "v=spf1 -all", mechanisms: []string{"-all"}},
+		{record: "V=SPF1 +a mx/24 ~ip4:192.0.2.0/24 ?ip6:2001:db8::/32 all", mechanisms: []string{"a", "mx/24", "~ip4:192.0.2.0/24", "?ip6:2001:db8::/32", "all"}},
+		{record: "v=spf1 include:_spf.example.com exists:%{i}.example.com -all", mechanisms: []string{"include:_spf.example.com", "exists:%{i}.example.com", "-all"}},
+		{record: "v=spf1 a:example.com/24//64 -all", mechanisms: []string{"a:example.com/24//64", "-all"}},
+		{record: "v=spf1 redirect=_spf.example.com", redirect: "_spf.example.com"},
+		{record: "v=spf1 unknown=ignored -all", mechanisms: []string{"-all"}},
+		{record: "v=spf1 ip4:192.0.2.300 -all", err: true},
+		{record: "v=spf1 ip4:192.0.2.0/33", err: true},
+		{record: "v=spf1 include -all", err: true},
+		{record: "v=spf1 foo:bar", err: true},
+		{record: "v=spf1 redirect=a.example.com redirect=b.example.com", err: true},
+		{record: "v=spf1 exists:%{z}.example.com", err: true},
+		{record: "v=spf10 -all", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.record, func(t *testing.T) {
+			record, err := Parse(test.record)
+			if test.err {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %+v, want an error", test.record, record)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Parse(%q) = %v", test.record, err)
+			}
+
+			var mechanisms []string
+			for _, mechanism := range record.Mechanisms {
+				mechanisms = append(mechanisms, mechanism.String())
+			}
+
+			if len(mechanisms) != len(test.mechanisms) {
+				t.Fatalf("Parse(%q) mechanisms = %q, want %q", test.record, mechanisms, test.mechanisms)
+			}
+
+			for i := range mechanisms {
+				if mechanisms[i] != test.mechanisms[i] {
+					t.Errorf("Parse(%q) mechanisms = %q, want %q", test.record, mechanisms, test.mechanisms)
+					break
+				}
+			}
+
+			if record.Redirect != test.redirect {
+				t.Errorf("Parse(%q) redirect = %q, want %q", test.record, record.Redirect, test.redirect)
+			}
+		})
+	}
+}
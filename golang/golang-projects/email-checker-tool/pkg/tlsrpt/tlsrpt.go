@@ -0,0 +1,143 @@
+package tlsrpt
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// Resolver looks up the _smtp._tls TXT record.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Record is a parsed TLS-RPT record (RFC 8460 section 3).
+type Record struct {
+	Raw string `json:"raw"`
+	// ReportURIs are the mailto: and https: destinations of the rua field.
+	ReportURIs []string `json:"rua"`
+}
+
+// Report is the outcome of checking the TLS-RPT record of a domain.
+type Report struct {
+	Record *Record  `json:"record,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// IsRecord tells if the TXT record is a TLS-RPT record.
+func IsRecord(txt string) bool {
+	first := strings.TrimSpace(strings.SplitN(txt, ";", 2)[0])
+	return strings.ReplaceAll(first, " ", "") == "v=TLSRPTv1"
+}
+
+// Parse parses a TLS-RPT record and validates its destinations.
+func Parse(txt string) (*Record, error) {
+	record := &Record{Raw: txt}
+	hasRUA := false
+
+	for i, field := range strings.Split(txt, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		j := strings.IndexByte(field, '=')
+		if j < 0 {
+			return nil, fmt.Errorf("tls-rpt: invalid field %q", field)
+		}
+
+		name, value := strings.TrimSpace(field[:j]), strings.TrimSpace(field[j+1:])
+
+		switch {
+		case i == 0 && (name != "v" || value != "TLSRPTv1"):
+			return nil, fmt.Errorf("tls-rpt: the record must start with v=TLSRPTv1")
+		case name == "rua":
+			if hasRUA {
+				return nil, fmt.Errorf("tls-rpt: rua appears more than once")
+			}
+			hasRUA = true
+
+			for _, uri := range strings.Split(value, ",") {
+				uri = strings.TrimSpace(uri)
+				if err := checkURI(uri); err != nil {
+					return nil, err
+				}
+
+				record.ReportURIs = append(record.ReportURIs, uri)
+			}
+		}
+	}
+
+	if !hasRUA {
+		return nil, fmt.Errorf("tls-rpt: the rua field is missing")
+	}
+
+	return record, nil
+}
+
+// checkURI validates a single rua destination
+func checkURI(uri string) error {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("tls-rpt: invalid rua destination %q: %v", uri, err)
+	}
+
+	switch strings.ToLower(parsed.Scheme) {
+	case "mailto":
+		if i := strings.LastIndexByte(parsed.Opaque, '@'); i <= 0 || i == len(parsed.Opaque)-1 {
+			return fmt.Errorf("tls-rpt: invalid rua address %q", uri)
+		}
+	case "https":
+		if parsed.Host == "" {
+			return fmt.Errorf("tls-rpt: invalid rua URL %q", uri)
+		}
+	default:
+		return fmt.Errorf("tls-rpt: invalid rua destination %q, expected a mailto: or https: URI", uri)
+	}
+
+	return nil
+}
+
+// Check looks up the _smtp._tls record of the domain. It returns nil when the domain has none.
+func Check(ctx context.Context, dns Resolver, domain string) *Report {
+	name := "_smtp._tls." + strings.TrimSuffix(domain, ".")
+
+	txtRecords, err := dns.LookupTXT(ctx, name)
+	if err != nil {
+		if resolver.IsNotFound(err) {
+			return nil
+		}
+
+		return &Report{Errors: []string{fmt.Sprintf("%s: %v", name, err)}}
+	}
+
+	var rptRecords []string
+	for _, txt := range txtRecords {
+		if IsRecord(txt) {
+			rptRecords = append(rptRecords, txt)
+		}
+	}
+
+	if len(rptRecords) == 0 {
+		return nil
+	}
+
+	report := &Report{}
+
+	// Senders ignore the records when there is more than one
+	if len(rptRecords) > 1 {
+		report.Errors = append(report.Errors, fmt.Sprintf("%d TLS-RPT records found, senders ignore all of them when there is more than one", len(rptRecords)))
+	}
+
+	record, err := Parse(rptRecords[0])
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+
+	report.Record = record
+	return report
+}
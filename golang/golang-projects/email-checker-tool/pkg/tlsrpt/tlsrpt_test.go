@@ -0,0 +1,86 @@
+package tlsrpt
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		txt  string
+		uris []string
+		err  string
+	}{
+		{txt: "v=TLSRPTv1; rua=mailto:tlsrpt@example.test", uris: []string{"mailto:tlsrpt@example.test"}},
+		{txt: "v=TLSRPTv1;rua=mailto:a@example.test, https://reports.example.test/tls", uris: []string{"mailto:a@example.test", "https://reports.example.test/tls"}},
+		{txt: "rua=mailto:a@example.test; v=TLSRPTv1", err: "tls-rpt: the record must start with v=TLSRPTv1"},
+		{txt: "v=TLSRPTv1", err: "tls-rpt: the rua field is missing"},
+		{txt: "v=TLSRPTv1; rua=mailto:a@example.test; rua=mailto:b@example.test", err: "tls-rpt: rua appears more than once"},
+		{txt: "v=TLSRPTv1; rua=ftp://reports.example.test", err: `tls-rpt: invalid rua destination "ftp://reports.example.test", expected a mailto: or https: URI`},
+		{txt: "v=TLSRPTv1; rua=mailto:example.test", err: `tls-rpt: invalid rua address "mailto:example.test"`},
+		{txt: "v=TLSRPTv1; rua=https:///tls", err: `tls-rpt: invalid rua URL "https:///tls"`},
+		{txt: "v=TLSRPTv1; rua", err: `tls-rpt: invalid field "rua"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.txt, func(t *testing.T) {
+			record, err := Parse(test.txt)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("Parse(%q) = %v, want %s", test.txt, err, test.err)
+				}
+				return
+			}
+
+			if err != nil || !reflect.DeepEqual(record.ReportURIs, test.uris) {
+				t.Errorf("Parse(%q) = %+v, %v, want %q", test.txt, record, err, test.uris)
+			}
+		})
+	}
+}
+
+// fakeResolver answers the TXT lookups from a map, the missing names do not exist
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := r[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return records, nil
+}
+
+func TestCheck(t *testing.T) {
+	resolver := fakeResolver{
+		"_smtp._tls.valid.test":   {"v=spf1 -all", "v=TLSRPTv1; rua=mailto:tlsrpt@valid.test"},
+		"_smtp._tls.twice.test":   {"v=TLSRPTv1; rua=mailto:a@twice.test", "v=TLSRPTv1; rua=mailto:b@twice.test"},
+		"_smtp._tls.invalid.test": {"v=TLSRPTv1; rua=ftp://invalid.test"},
+		"_smtp._tls.other.test":   {"v=spf1 -all"},
+	}
+
+	tests := []struct {
+		domain string
+		record bool
+		errors int
+	}{
+		{domain: "valid.test", record: true},
+		{domain: "twice.test", record: true, errors: 1},
+		{domain: "invalid.test", errors: 1},
+	}
+
+	for _, test := range tests {
+		report := Check(context.Background(), resolver, test.domain)
+		if report == nil || (report.Record != nil) != test.record || len(report.Errors) != test.errors {
+			t.Errorf("Check(%s) = %+v, want a record: %t and %d errors", test.domain, report, test.record, test.errors)
+		}
+	}
+
+	for _, domain := range []string{"other.test", "missing.test"} {
+		if report := Check(context.Background(), resolver, domain); report != nil {
+			t.Errorf("Check(%s) = %+v, want nil", domain, report)
+		}
+	}
+}
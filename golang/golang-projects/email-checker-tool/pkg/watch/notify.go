@@ -0,0 +1,88 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Notifier sends the change events of a run somewhere.
+type Notifier interface {
+	Notify(ctx context.Context, events []Event) error
+}
+
+// WriterNotifier writes every event as a line of JSON.
+type WriterNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterNotifier returns a Notifier writing to w, e.g. os.Stdout or a file opened for appending.
+func NewWriterNotifier(w io.Writer) *WriterNotifier {
+	return &WriterNotifier{w: w}
+}
+
+// Notify writes the events as JSON lines.
+func (n *WriterNotifier) Notify(ctx context.Context, events []Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	encoder := json.NewEncoder(n.w)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Webhook posts the events of a run as a single JSON body {"events": [...]}.
+type Webhook struct {
+	URL string
+	// Client sends the requests, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// webhookBody is the body posted to the webhook
+type webhookBody struct {
+	Events []Event `json:"events"`
+}
+
+// Notify posts the events, the webhook must answer with a 2xx status.
+func (w *Webhook) Notify(ctx context.Context, events []Event) error {
+	body, err := json.Marshal(webhookBody{Events: events})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	client := http.DefaultClient
+	if w.Client != nil {
+		client = w.Client
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// Drains the body so that the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("watch: the webhook %s answered %s", w.URL, response.Status)
+	}
+
+	return nil
+}
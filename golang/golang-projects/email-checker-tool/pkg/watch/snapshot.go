@@ -0,0 +1,157 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of change events
+const (
+	// Added is a field that had no value in the last snapshot.
+	Added = "added"
+	// Changed is a field whose value is different from the last snapshot.
+	Changed = "changed"
+	// Removed is a field that no longer has a value.
+	Removed = "removed"
+)
+
+// Snapshot is what was found for a domain at some point, as a flat list of fields.
+type Snapshot struct {
+	Domain string    `json:"domain"`
+	Time   time.Time `json:"time"`
+	// Fields only hold the fields with a value, a missing field is absent.
+	Fields map[string]string `json:"fields"`
+	// Unknown are the fields that could not be checked, e.g. because a lookup
+	// failed. They are never reported as changed.
+	Unknown []string `json:"unknown,omitempty"`
+}
+
+// Event is a field that changed between two snapshots of a domain.
+type Event struct {
+	Kind   string    `json:"kind"`
+	Domain string    `json:"domain"`
+	Field  string    `json:"field"`
+	Old    string    `json:"old,omitempty"`
+	New    string    `json:"new,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Diff returns the changes from the old snapshot to the new one, sorted by field.
+// The unknown fields of the new snapshot take their value from the old one, so that
+// a failed lookup neither raises an event nor loses the value for the next run.
+func Diff(old, new *Snapshot) []Event {
+	for _, field := range new.Unknown {
+		if value, ok := old.Fields[field]; ok {
+			new.Fields[field] = value
+		}
+	}
+
+	unknown := make(map[string]bool)
+	for _, field := range new.Unknown {
+		unknown[field] = true
+	}
+
+	var events []Event
+
+	for field, value := range new.Fields {
+		previous, ok := old.Fields[field]
+
+		switch {
+		case !ok:
+			events = append(events, Event{Kind: Added, Field: field, New: value})
+		case previous != value:
+			events = append(events, Event{Kind: Changed, Field: field, Old: previous, New: value})
+		}
+	}
+
+	for field, value := range old.Fields {
+		if _, ok := new.Fields[field]; !ok && !unknown[field] {
+			events = append(events, Event{Kind: Removed, Field: field, Old: value})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Field < events[j].Field
+	})
+
+	for i := range events {
+		events[i].Domain = new.Domain
+		events[i].Time = new.Time
+	}
+
+	return events
+}
+
+// Store keeps the last snapshot of every domain in a directory, one JSON file per domain.
+type Store struct {
+	Dir string
+}
+
+// Load returns the last snapshot of the domain, or nil if there is none yet.
+func (s *Store) Load(domain string) (*Snapshot, error) {
+	data, err := os.ReadFile(s.path(domain))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("watch: invalid snapshot %s: %v", s.path(domain), err)
+	}
+
+	if snapshot.Fields == nil {
+		snapshot.Fields = make(map[string]string)
+	}
+
+	return &snapshot, nil
+}
+
+// Save replaces the last snapshot of its domain, creating the directory if needed.
+func (s *Store) Save(snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+
+	path := s.path(snapshot.Domain)
+
+	// Writes to a temporary file first so that an interrupted run does not leave a truncated snapshot
+	tmp, err := os.CreateTemp(s.Dir, filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// path returns the file of the domain. Domains cannot contain a slash, but the
+// input is not trusted to be a valid domain.
+func (s *Store) path(domain string) string {
+	name := strings.ToLower(strings.TrimSuffix(domain, "."))
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+
+	return filepath.Join(s.Dir, name+".json")
+}
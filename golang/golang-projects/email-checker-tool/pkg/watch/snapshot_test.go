@@ -0,0 +1,124 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     map[string]string
+		new     map[string]string
+		unknown []string
+		events  []Event
+		fields  map[string]string
+	}{
+		{
+			name: "unchanged",
+			old:  map[string]string{"mx": "mx1.example.test.", "spf": "v=spf1 -all"},
+			new:  map[string]string{"mx": "mx1.example.test.", "spf": "v=spf1 -all"},
+		},
+		{
+			name: "added, changed and removed",
+			old:  map[string]string{"mx": "mx1.example.test.", "spf": "v=spf1 -all", "bimi": "v=BIMI1; l=https://example.test/logo.svg"},
+			new:  map[string]string{"mx": "mx2.example.test.", "spf": "v=spf1 -all", "dmarc": "v=DMARC1; p=reject"},
+			events: []Event{
+				{Kind: Removed, Field: "bimi", Old: "v=BIMI1; l=https://example.test/logo.svg"},
+				{Kind: Added, Field: "dmarc", New: "v=DMARC1; p=reject"},
+				{Kind: Changed, Field: "mx", Old: "mx1.example.test.", New: "mx2.example.test."},
+			},
+		},
+		{
+			// A failed lookup keeps the value of the last snapshot for the next run
+			name:    "unknown",
+			old:     map[string]string{"mx": "mx1.example.test.", "dmarc": "v=DMARC1; p=reject"},
+			new:     map[string]string{"mx": "mx1.example.test."},
+			unknown: []string{"dmarc", "grade"},
+			fields:  map[string]string{"mx": "mx1.example.test.", "dmarc": "v=DMARC1; p=reject"},
+		},
+		{
+			name:    "unknown without a previous value",
+			old:     map[string]string{},
+			new:     map[string]string{"spf": "v=spf1 -all"},
+			unknown: []string{"dmarc"},
+			events:  []Event{{Kind: Added, Field: "spf", New: "v=spf1 -all"}},
+		},
+	}
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			old := &Snapshot{Domain: "example.test", Fields: test.old}
+			new := &Snapshot{Domain: "example.test", Time: now, Fields: test.new, Unknown: test.unknown}
+
+			want := append([]Event{}, test.events...)
+			for i := range want {
+				want[i].Domain, want[i].Time = "example.test", now
+			}
+
+			if events := Diff(old, new); len(events) != len(want) || (len(want) > 0 && !reflect.DeepEqual(events, want)) {
+				t.Errorf("Diff() = %+v, want %+v", events, want)
+			}
+
+			if test.fields != nil && !reflect.DeepEqual(new.Fields, test.fields) {
+				t.Errorf("Diff() fields = %v, want %v", new.Fields, test.fields)
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := &Store{Dir: filepath.Join(t.TempDir(), "snapshots")}
+
+	if snapshot, err := store.Load("example.test"); snapshot != nil || err != nil {
+		t.Fatalf("Load() = %+v, %v, want nil without a snapshot", snapshot, err)
+	}
+
+	saved := &Snapshot{
+		Domain:  "example.test",
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Fields:  map[string]string{"mx": "mx1.example.test."},
+		Unknown: []string{"dmarc"},
+	}
+
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+
+	// The domain is case insensitive
+	loaded, err := store.Load("Example.TEST.")
+	if err != nil || !reflect.DeepEqual(loaded, saved) {
+		t.Errorf("Load() = %+v, %v, want %+v", loaded, err, saved)
+	}
+
+	// No temporary file is left behind
+	if files, _ := os.ReadDir(store.Dir); len(files) != 1 || files[0].Name() != "example.test.json" {
+		t.Errorf("the directory holds %v, want example.test.json", files)
+	}
+
+	if err := os.WriteFile(filepath.Join(store.Dir, "broken.test.json"), []byte(`{"domain": "broken.test"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if snapshot, err := store.Load("broken.test"); snapshot != nil || err == nil {
+		t.Errorf("Load() = %+v, %v, want an error for a truncated snapshot", snapshot, err)
+	}
+
+	// Fields is never nil, so that Diff can fill it
+	if err := os.WriteFile(filepath.Join(store.Dir, "empty.test.json"), []byte(`{"domain": "empty.test"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if snapshot, err := store.Load("empty.test"); err != nil || snapshot.Fields == nil {
+		t.Errorf("Load() = %+v, %v, want empty fields", snapshot, err)
+	}
+
+	if path := store.path("../etc/passwd"); filepath.Dir(path) != store.Dir {
+		t.Errorf("path(../etc/passwd) = %s, want a file of %s", path, store.Dir)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// job is a domain waiting to be checked and its position in the input
+type job struct {
+	index  int
+	domain string
+}
+
+// result is the report of a job
+type result struct {
+	index  int
+	report DomainReport
+}
+
+// checkAll checks every domain received from domains using a pool of workers and
+// passes the reports to write in the same order the domains were received. It stops
+// at the first error returned by write, or when the context is cancelled.
+func checkAll(ctx context.Context, checker *Checker, domains <-chan string, workers int, progress *Progress, write func(DomainReport) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan job)
+	results := make(chan result)
+
+	// window bounds how far the workers can get ahead of the slowest domain,
+	// so that the reports waiting to be written in order do not grow forever
+	window := make(chan struct{}, workers*4)
+
+	// Dispatches the domains to the workers
+	go func() {
+		defer close(jobs)
+
+		index := 0
+		for domain := range domains {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			progress.queued()
+
+			select {
+			case jobs <- job{index: index, domain: domain}:
+			case <-ctx.Done():
+				return
+			}
+
+			index++
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range jobs {
+				results <- result{index: j.index, report: checker.check(ctx, j.domain)}
+			}
+		}()
+	}
+
+	// Closes the results once every worker is done
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var writeErr error
+	pending := make(map[int]DomainReport)
+	next := 0
+
+	for r := range results {
+		pending[r.index] = r.report
+
+		// Writes every report that is next in line
+		for {
+			report, ok := pending[next]
+			if !ok {
+				break
+			}
+
+			delete(pending, next)
+			next++
+			<-window
+			progress.done(report)
+
+			if writeErr != nil {
+				continue
+			}
+
+			if err := write(report); err != nil {
+				// Keeps reading the results so that the workers can stop
+				writeErr = err
+				cancel()
+			}
+		}
+	}
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	return ctx.Err()
+}
+
+// Progress reports how many domains have been checked. A nil *Progress
+// reports nothing.
+type Progress struct {
+	w       io.Writer
+	start   time.Time
+	total   int64
+	checked int64
+	failed  int64
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewProgress returns a Progress that prints to w on every interval until Stop is called.
+func NewProgress(w io.Writer, interval time.Duration) *Progress {
+	p := &Progress{
+		w:       w,
+		start:   time.Now(),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(p.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.print()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+// queued counts a domain read from the input
+func (p *Progress) queued() {
+	if p != nil {
+		atomic.AddInt64(&p.total, 1)
+	}
+}
+
+// done counts a checked domain
+func (p *Progress) done(report DomainReport) {
+	if p == nil {
+		return
+	}
+
+	atomic.AddInt64(&p.checked, 1)
+
+	if len(report.Errors) > 0 {
+		atomic.AddInt64(&p.failed, 1)
+	}
+}
+
+// print writes the current counters
+func (p *Progress) print() {
+	checked := atomic.LoadInt64(&p.checked)
+	elapsed := time.Since(p.start)
+	rate := float64(checked) / elapsed.Seconds()
+
+	fmt.Fprintf(p.w, "progress: %d/%d checked, %d with errors, %.1f domains/s, %s elapsed\n",
+		checked, atomic.LoadInt64(&p.total), atomic.LoadInt64(&p.failed), rate, elapsed.Round(time.Second))
+}
+
+// Stop stops the periodic report and prints the final counters.
+func (p *Progress) Stop() {
+	if p == nil {
+		return
+	}
+
+	close(p.stop)
+	<-p.stopped
+	p.print()
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProgressDone(t *testing.T) {
+	checker := newStubChecker(startStubDNS(t))
+	progress := &Progress{}
+
+	for _, domain := range []string{"secure.example.test", "nospf.example.test", "long.example.test", "missing.example.test"} {
+		progress.done(checker.check(context.Background(), domain))
+	}
+
+	// Only missing.example.test does not exist, the missing records of the others are no errors
+	if progress.checked != 4 || progress.failed != 1 {
+		t.Errorf("done() counted %d checked and %d with errors, want 4 and 1", progress.checked, progress.failed)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// MaxRate is the highest number of events per second a RateLimiter can space out,
+// one per nanosecond, the resolution of its ticker.
+const MaxRate = int(time.Second)
+
+// RateLimiter spaces out events so that no more than a fixed number
+// happen per second. A nil *RateLimiter never blocks.
+type RateLimiter struct {
+	ticker *time.Ticker
+}
+
+// NewRateLimiter returns a RateLimiter that allows perSecond events per second.
+// It returns nil, meaning unlimited, if perSecond is zero or negative, and
+// a rate above MaxRate is lowered to MaxRate.
+func NewRateLimiter(perSecond int) *RateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+
+	if perSecond > MaxRate {
+		perSecond = MaxRate
+	}
+
+	return &RateLimiter{ticker: time.NewTicker(time.Second / time.Duration(perSecond))}
+}
+
+// Wait blocks until the next event is allowed or the context is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	select {
+	case <-l.ticker.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop releases the resources of the RateLimiter.
+func (l *RateLimiter) Stop() {
+	if l != nil {
+		l.ticker.Stop()
+	}
+}
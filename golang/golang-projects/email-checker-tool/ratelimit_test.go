@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	tests := []struct {
+		name      string
+		perSecond int
+		unlimited bool
+	}{
+		{name: "zero is unlimited", perSecond: 0, unlimited: true},
+		{name: "negative is unlimited", perSecond: -5, unlimited: true},
+		{name: "one per second", perSecond: 1},
+		{name: "max rate", perSecond: MaxRate},
+		// time.Second / (MaxRate + 1) is 0, which time.NewTicker refuses with a panic
+		{name: "above max rate", perSecond: MaxRate + 1},
+		{name: "max int", perSecond: int(^uint(0) >> 1)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := NewRateLimiter(test.perSecond)
+			defer limiter.Stop()
+
+			if (limiter == nil) != test.unlimited {
+				t.Fatalf("NewRateLimiter(%d) = %v, unlimited %v", test.perSecond, limiter, test.unlimited)
+			}
+		})
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	limiter := NewRateLimiter(MaxRate)
+	defer limiter.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := limiter.Wait(ctx); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+
+	var unlimited *RateLimiter
+
+	cancel()
+	if err := unlimited.Wait(ctx); err != context.Canceled {
+		t.Errorf("Wait() of a nil limiter with a canceled context = %v, want %v", err, context.Canceled)
+	}
+}
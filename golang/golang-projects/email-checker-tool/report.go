@@ -0,0 +1,97 @@
+package main
+
+import (
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/bimi"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dkim"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dmarc"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/mailbox"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/mtasts"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/reputation"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/score"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/smtpprobe"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/spf"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/tlsrpt"
+)
+
+// Names of the lookups made by checkDomain. They are used to tell
+// which lookup a LookupError came from.
+const (
+	LookupMX    = "mx"
+	LookupSPF   = "spf"
+	LookupDMARC = "dmarc"
+)
+
+// DomainReport contains the result of every check made for a single domain.
+// When an email address was given, Address and Mailbox describe the mailbox.
+// Lookups tells the class of the MX, SPF and DMARC lookups, see resolver.Classify.
+type DomainReport struct {
+	Domain      string             `json:"domain"`
+	Address     string             `json:"address,omitempty"`
+	Mailbox     *mailbox.Result    `json:"mailbox,omitempty"`
+	HasMX       bool               `json:"hasMX"`
+	MXRecords   []string           `json:"mxRecords,omitempty"`
+	SMTP        []smtpprobe.Result `json:"smtp,omitempty"`
+	Reputation  *reputation.Report `json:"reputation,omitempty"`
+	HasSPF      bool               `json:"hasSPF"`
+	SPFRecord   string             `json:"spfRecord"`
+	SPF         *spf.Analysis      `json:"spf,omitempty"`
+	SPFResult   *spf.Verdict       `json:"spfResult,omitempty"`
+	HasDMARC    bool               `json:"hasDMARC"`
+	DMARCRecord string             `json:"dmarcRecord"`
+	DMARC       *dmarc.Report      `json:"dmarc,omitempty"`
+	HasMTASTS   bool               `json:"hasMtaSts"`
+	MTASTS      *mtasts.Report     `json:"mtaSts,omitempty"`
+	HasTLSRPT   bool               `json:"hasTlsRpt"`
+	TLSRPT      *tlsrpt.Report     `json:"tlsRpt,omitempty"`
+	HasBIMI     bool               `json:"hasBimi"`
+	BIMI        *bimi.Report       `json:"bimi,omitempty"`
+	HasDKIM     bool               `json:"hasDKIM"`
+	DKIM        *dkim.Report       `json:"dkim,omitempty"`
+	DNSSEC      []RecordSecurity   `json:"dnssec,omitempty"`
+	Score       *score.Result      `json:"score,omitempty"`
+	Lookups     map[string]string  `json:"lookups,omitempty"`
+	Errors      []LookupError      `json:"errors,omitempty"`
+}
+
+// RecordSecurity is the DNSSEC status of the answer to one of the lookups:
+// secure, insecure, bogus or indeterminate.
+type RecordSecurity struct {
+	Lookup string `json:"lookup"`
+	Query  string `json:"query"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// LookupError describes a DNS lookup that failed while checking a domain.
+type LookupError struct {
+	Lookup string `json:"lookup"`
+	Query  string `json:"query"`
+	Error  string `json:"error"`
+	// Class tells whether the records do not exist or the lookup failed, see resolver.Classify.
+	Class string `json:"class"`
+}
+
+// addLookup records the class of the lookup to the report, and the error when the lookup
+// failed. A missing record is not an error, e.g. most domains have no _dmarc subdomain,
+// unless the name of the domain itself does not exist.
+func (report *DomainReport) addLookup(lookup, query string, err error) {
+	class := resolver.Classify(err)
+
+	if report.Lookups == nil {
+		report.Lookups = make(map[string]string)
+	}
+	report.Lookups[lookup] = class
+
+	if !resolver.Failed(class) && (class != resolver.ClassNXDomain || query != report.Domain) {
+		return
+	}
+
+	report.Errors = append(report.Errors, LookupError{
+		Lookup: lookup,
+		Query:  query,
+		Error:  err.Error(),
+		Class:  class,
+	})
+}
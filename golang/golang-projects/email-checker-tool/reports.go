@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dmarc"
+)
+
+// reportExtensions are the files read from the directories given to the reports subcommand
+var reportExtensions = map[string]bool{".xml": true, ".gz": true, ".zip": true}
+
+// runReports reads the DMARC aggregate reports of the files and directories given as
+// arguments and prints their messages by source IP and by domain
+func runReports(args []string) {
+	flags := flag.NewFlagSet("reports", flag.ExitOnError)
+	format := flags.String("format", FormatTable, "output format: table or json")
+	top := flags.Int("top", 20, "number of rows of every table, 0 prints them all")
+	flags.Parse(args)
+
+	if *format != FormatTable && *format != FormatJSON {
+		log.Fatalf("Error unknown output format %q, expected table or json\n", *format)
+	}
+
+	if flags.NArg() == 0 {
+		log.Fatalf("Error expected the aggregate report files or directories as arguments\n")
+	}
+
+	paths, err := reportFiles(flags.Args())
+	if err != nil {
+		log.Fatalf("Error %v\n", err)
+	}
+
+	aggregate := dmarc.NewAggregate()
+	duplicates := 0
+
+	for _, path := range paths {
+		reports, err := dmarc.ReadAggregateFile(path)
+		if err != nil {
+			log.Printf("Error skipping %v\n", err)
+			continue
+		}
+
+		for _, report := range reports {
+			if !aggregate.Add(report) {
+				duplicates++
+			}
+		}
+	}
+
+	if aggregate.Reports == 0 {
+		log.Fatalf("Error no aggregate report could be read\n")
+	}
+
+	if duplicates > 0 {
+		log.Printf("Skipped %d reports that were already read from another file\n", duplicates)
+	}
+
+	aggregate.Sort()
+
+	if *format == FormatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(aggregate); err != nil {
+			log.Fatalf("Error could not write the summary: %v\n", err)
+		}
+
+		return
+	}
+
+	if err := writeAggregateTables(os.Stdout, aggregate, *top); err != nil {
+		log.Fatalf("Error could not write the summary: %v\n", err)
+	}
+}
+
+// reportFiles expands the directories of the arguments to the report files they contain.
+// The files given explicitly are always read, whatever their extension.
+func reportFiles(args []string) ([]string, error) {
+	var paths []string
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+
+		err = filepath.WalkDir(arg, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !entry.IsDir() && reportExtensions[strings.ToLower(filepath.Ext(path))] {
+				paths = append(paths, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// writeAggregateTables prints the totals and the tables by source IP and by domain, at most top rows each
+func writeAggregateTables(w io.Writer, aggregate *dmarc.Aggregate, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "%d reports from %s, %s to %s\n", aggregate.Reports, strings.Join(aggregate.Organizations, ", "),
+		aggregate.Begin.Format("2006-01-02"), aggregate.End.Format("2006-01-02"))
+	fmt.Fprintf(tw, "%d messages, %d passed DMARC (%s), %d failed\n\n", aggregate.Total.Messages, aggregate.Total.Passed,
+		formatRate(&aggregate.Total), aggregate.Total.Failed)
+
+	fmt.Fprintln(tw, "SOURCE IP\tDOMAINS\tMESSAGES\tPASS\tFAIL\tPASS RATE\tDKIM PASS\tSPF PASS\tDISPOSITIONS")
+	for i, source := range aggregate.BySource {
+		if top > 0 && i == top {
+			fmt.Fprintf(tw, "... %d more\n", len(aggregate.BySource)-top)
+			break
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", source.SourceIP, strings.Join(source.Domains, " "), formatCounts(&source.Counts))
+	}
+
+	fmt.Fprintln(tw, "\nDOMAIN\tSOURCES\tMESSAGES\tPASS\tFAIL\tPASS RATE\tDKIM PASS\tSPF PASS\tDISPOSITIONS")
+	for i, domain := range aggregate.ByDomain {
+		if top > 0 && i == top {
+			fmt.Fprintf(tw, "... %d more\n", len(aggregate.ByDomain)-top)
+			break
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", domain.Domain, domain.Sources, formatCounts(&domain.Counts))
+	}
+
+	return tw.Flush()
+}
+
+// formatCounts returns the columns of the counts, from MESSAGES to DISPOSITIONS
+func formatCounts(counts *dmarc.Counts) string {
+	dispositions := make([]string, 0, len(counts.Dispositions))
+	for _, disposition := range []string{dmarc.PolicyNone, dmarc.PolicyQuarantine, dmarc.PolicyReject} {
+		if count := counts.Dispositions[disposition]; count > 0 {
+			dispositions = append(dispositions, fmt.Sprintf("%s:%d", disposition, count))
+		}
+	}
+
+	return fmt.Sprintf("%d\t%d\t%d\t%s\t%d\t%d\t%s", counts.Messages, counts.Passed, counts.Failed, formatRate(counts),
+		counts.DKIMPassed, counts.SPFPassed, strings.Join(dispositions, " "))
+}
+
+// formatRate returns the pass rate of the counts as a percentage
+func formatRate(counts *dmarc.Counts) string {
+	return fmt.Sprintf("%.1f%%", 100*counts.PassRate())
+}
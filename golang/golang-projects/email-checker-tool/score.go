@@ -0,0 +1,369 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/mtasts"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/score"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/spf"
+)
+
+// scoreChecks turns the findings of the report into the checks of every category.
+// The categories that were not checked, or whose lookup failed, are left out.
+func (c *Checker) scoreChecks(report DomainReport) []score.Check {
+	var checks []score.Check
+
+	if !report.failed(LookupMX) {
+		checks = append(checks, scoreMX(report))
+	}
+
+	if c.SMTP != nil && len(report.SMTP) > 0 {
+		checks = append(checks, scoreSMTP(report))
+	}
+
+	if !report.failed(LookupSPF) {
+		checks = append(checks, scoreSPF(report))
+	}
+
+	if !report.failed(LookupDMARC) {
+		checks = append(checks, scoreDMARC(report))
+	}
+
+	if report.DKIM != nil {
+		checks = append(checks, scoreDKIM(report))
+	}
+
+	// A report with errors but neither a record nor a policy tells that the lookup failed
+	if c.MTASTS != nil && (report.MTASTS == nil || report.MTASTS.Record != nil || report.MTASTS.Policy != nil) {
+		checks = append(checks, scoreMTASTS(report))
+	}
+
+	if report.TLSRPT == nil || report.TLSRPT.Record != nil || len(report.TLSRPT.Errors) == 0 {
+		checks = append(checks, scoreTLSRPT(report))
+	}
+
+	if c.BIMI != nil {
+		checks = append(checks, scoreBIMI(report))
+	}
+
+	if len(report.DNSSEC) > 0 {
+		checks = append(checks, scoreDNSSEC(report))
+	}
+
+	return checks
+}
+
+// failed tells if a lookup of the report failed for another reason than the records
+// not existing, the category can then not be scored
+func (report *DomainReport) failed(lookup string) bool {
+	for _, err := range report.Errors {
+		if err.Lookup == lookup && resolver.Failed(err.Class) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scoreMX gives the points of the MX records, a null MX counts as a deliberate choice.
+// The addresses of the MX hosts that are listed, or lack a forward-confirmed reverse DNS, lose points.
+func scoreMX(report DomainReport) score.Check {
+	check := score.Check{Category: score.CategoryMX, Points: 1}
+
+	if !report.HasMX {
+		check.Points = 0
+		check.Remediation = []string{`publish MX records, or a null MX "0 ." if the domain does not receive email`}
+	}
+
+	if report.Reputation == nil {
+		return check
+	}
+
+	// Every address of the MX hosts gets an equal share of the points
+	var addresses, lost float64
+
+	for _, host := range report.Reputation.Hosts {
+		for _, address := range host.Addresses {
+			addresses++
+
+			switch {
+			case len(address.Listings) > 0:
+				lost++
+
+				for _, listing := range address.Listings {
+					check.Remediation = append(check.Remediation, fmt.Sprintf("get the address %s of %s removed from %s", address.IP, host.Host, listing.Blocklist))
+				}
+			case address.PTRError == "" && !address.FCrDNS:
+				lost += 0.5
+				check.Remediation = append(check.Remediation, fmt.Sprintf("publish a PTR record for %s that points to %s, which resolves back to it", address.IP, host.Host))
+			}
+		}
+	}
+
+	if addresses > 0 {
+		check.Points -= lost / addresses
+	}
+
+	return check
+}
+
+// scoreSMTP gives the share of the MX hosts that are reachable with a valid STARTTLS certificate
+func scoreSMTP(report DomainReport) score.Check {
+	check := score.Check{Category: score.CategorySMTP}
+
+	for _, result := range report.SMTP {
+		switch {
+		case !result.Reachable:
+			check.Remediation = append(check.Remediation, fmt.Sprintf("make the MX host %s reachable on the SMTP port", result.Host))
+		case !result.STARTTLS:
+			check.Remediation = append(check.Remediation, fmt.Sprintf("enable STARTTLS on the MX host %s", result.Host))
+		case result.TLS == nil || result.TLS.Certificate == nil || !result.TLS.Certificate.Valid:
+			check.Points += 0.5
+			check.Remediation = append(check.Remediation, fmt.Sprintf("install a certificate valid for %s, issued by a trusted authority", result.Host))
+		default:
+			check.Points++
+		}
+	}
+
+	check.Points /= float64(len(report.SMTP))
+	return check
+}
+
+// scoreSPF gives the points of the SPF record, depending on its errors and on its all mechanism
+func scoreSPF(report DomainReport) score.Check {
+	check := score.Check{Category: score.CategorySPF}
+
+	if !report.HasSPF {
+		check.Remediation = []string{`publish an SPF record listing the senders of the domain, e.g. "v=spf1 mx -all", or "v=spf1 -all" if it sends no email`}
+		return check
+	}
+
+	if report.SPF != nil {
+		if errs := report.SPF.AllErrors(); len(errs) > 0 {
+			check.Points = 0.3
+			for _, err := range errs {
+				check.Remediation = append(check.Remediation, "fix the SPF record: "+err)
+			}
+
+			return check
+		}
+	}
+
+	record, err := spf.Parse(report.SPFRecord)
+	if err != nil {
+		check.Remediation = []string{"fix the SPF record: " + err.Error()}
+		return check
+	}
+
+	check.Points = 1
+
+	// A record that ends with a redirect takes the all mechanism of the other record
+	if record.Redirect != "" {
+		return check
+	}
+
+	qualifier := spf.QualifierNeutral
+	for _, mechanism := range record.Mechanisms {
+		if mechanism.Name == spf.MechanismAll {
+			qualifier = mechanism.Qualifier
+		}
+	}
+
+	switch qualifier {
+	case spf.QualifierPass:
+		check.Points = 0
+		check.Remediation = []string{"replace +all with -all, the record lets anyone send email for the domain"}
+	case spf.QualifierNeutral:
+		check.Points = 0.5
+		check.Remediation = []string{"end the SPF record with -all, or ~all while the senders are being listed"}
+	case spf.QualifierSoftFail:
+		check.Points = 0.9
+		check.Remediation = []string{"replace ~all with -all once every sender is listed in the SPF record"}
+	}
+
+	return check
+}
+
+// scoreDMARC gives the points of the DMARC record, mostly depending on how strict its policy is
+func scoreDMARC(report DomainReport) score.Check {
+	check := score.Check{Category: score.CategoryDMARC}
+
+	if !report.HasDMARC || report.DMARC == nil {
+		check.Remediation = []string{fmt.Sprintf(`publish a DMARC record at _dmarc.%s, starting with "v=DMARC1; p=none; rua=mailto:..." to collect the reports`, report.Domain)}
+		return check
+	}
+
+	for _, err := range report.DMARC.Errors {
+		check.Remediation = append(check.Remediation, "fix the DMARC record: "+err)
+	}
+
+	record := report.DMARC.Record
+	if record == nil {
+		check.Points = 0.1
+		return check
+	}
+
+	switch record.Policy {
+	case "reject":
+		check.Points = 1
+	case "quarantine":
+		check.Points = 0.8
+		check.Remediation = append(check.Remediation, "move the DMARC policy to p=reject")
+	default:
+		check.Points = 0.4
+		check.Remediation = append(check.Remediation, "move the DMARC policy to p=quarantine, then p=reject, once the aggregate reports show that the legitimate email passes")
+	}
+
+	if record.Percent < 100 && record.Policy != "none" {
+		check.Points -= 0.1
+		check.Remediation = append(check.Remediation, fmt.Sprintf("raise pct=%d to 100 so that the policy applies to every message", record.Percent))
+	}
+
+	if len(record.AggregateURIs) == 0 {
+		check.Points -= 0.1
+		check.Remediation = append(check.Remediation, "add rua=mailto:... to the DMARC record to receive the aggregate reports")
+	}
+
+	check.Points -= 0.2 * float64(len(report.DMARC.Errors))
+	for _, warning := range report.DMARC.Warnings {
+		check.Remediation = append(check.Remediation, "DMARC: "+warning)
+	}
+
+	return check
+}
+
+// scoreDKIM gives the points of the DKIM keys, a domain without any key gets none
+func scoreDKIM(report DomainReport) score.Check {
+	check := score.Check{Category: score.CategoryDKIM}
+
+	if !report.HasDKIM {
+		check.Remediation = []string{fmt.Sprintf("sign the outgoing email with DKIM and publish the key at <selector>._domainkey.%s, or pass the selector with --dkim-selectors", report.Domain)}
+		return check
+	}
+
+	check.Points = 1
+
+	for _, selector := range report.DKIM.Selectors {
+		if len(selector.Errors) > 0 {
+			check.Points -= 0.2
+		} else if len(selector.Warnings) > 0 {
+			check.Points -= 0.1
+		}
+
+		for _, issue := range append(append([]string{}, selector.Errors...), selector.Warnings...) {
+			check.Remediation = append(check.Remediation, fmt.Sprintf("DKIM selector %s: %s", selector.Selector, issue))
+		}
+	}
+
+	if check.Points < 0.5 {
+		check.Points = 0.5
+	}
+
+	return check
+}
+
+// scoreMTASTS gives the points of the MTA-STS policy, which only protects the domain in enforce mode
+func scoreMTASTS(report DomainReport) score.Check {
+	check := score.Check{Category: score.CategoryMTASTS}
+
+	if report.MTASTS == nil {
+		check.Remediation = []string{fmt.Sprintf("publish an MTA-STS policy at %s and its _mta-sts TXT record, so that senders require TLS", mtasts.PolicyURL(report.Domain))}
+		return check
+	}
+
+	for _, err := range report.MTASTS.Errors {
+		check.Remediation = append(check.Remediation, "MTA-STS: "+err)
+	}
+
+	for _, warning := range report.MTASTS.Warnings {
+		check.Remediation = append(check.Remediation, "MTA-STS: "+warning)
+	}
+
+	switch {
+	case len(report.MTASTS.Errors) > 0 || report.MTASTS.Policy == nil:
+		check.Points = 0.3
+	case report.MTASTS.Policy.Mode == mtasts.ModeEnforce:
+		check.Points = 1
+		if len(report.MTASTS.Warnings) > 0 {
+			check.Points = 0.9
+		}
+	case report.MTASTS.Policy.Mode == mtasts.ModeTesting:
+		check.Points = 0.6
+	default:
+		check.Points = 0.3
+	}
+
+	return check
+}
+
+// scoreTLSRPT gives the points of the TLS-RPT record
+func scoreTLSRPT(report DomainReport) score.Check {
+	check := score.Check{Category: score.CategoryTLSRPT}
+
+	switch {
+	case report.TLSRPT == nil:
+		check.Remediation = []string{fmt.Sprintf(`publish "v=TLSRPTv1; rua=mailto:..." at _smtp._tls.%s to receive the TLS failure reports`, report.Domain)}
+	case len(report.TLSRPT.Errors) > 0:
+		check.Points = 0.3
+		for _, err := range report.TLSRPT.Errors {
+			check.Remediation = append(check.Remediation, "fix the TLS-RPT record: "+err)
+		}
+	default:
+		check.Points = 1
+	}
+
+	return check
+}
+
+// scoreBIMI gives the points of the BIMI record and logo
+func scoreBIMI(report DomainReport) score.Check {
+	check := score.Check{Category: score.CategoryBIMI}
+
+	if report.BIMI == nil {
+		check.Remediation = []string{fmt.Sprintf("publish a BIMI record at default._bimi.%s to show the logo of the domain in the mailboxes", report.Domain)}
+		return check
+	}
+
+	if report.BIMI.Ready() {
+		check.Points = 1
+		return check
+	}
+
+	check.Points = 0.5
+	for _, err := range report.BIMI.Errors {
+		check.Remediation = append(check.Remediation, "BIMI: "+err)
+	}
+
+	if report.BIMI.Logo != nil {
+		for _, problem := range report.BIMI.Logo.Problems {
+			check.Remediation = append(check.Remediation, "BIMI logo: "+problem)
+		}
+	}
+
+	return check
+}
+
+// scoreDNSSEC gives the share of the answers that are DNSSEC-secure, a bogus answer is the worst case
+func scoreDNSSEC(report DomainReport) score.Check {
+	check := score.Check{Category: score.CategoryDNSSEC}
+	insecure := false
+
+	for _, record := range report.DNSSEC {
+		switch record.Status {
+		case resolver.SecuritySecure:
+			check.Points++
+		case resolver.SecurityInsecure:
+			insecure = true
+		case resolver.SecurityBogus:
+			check.Remediation = append(check.Remediation, fmt.Sprintf("fix the DNSSEC signatures of %s %s, validating resolvers refuse the answer", record.Query, record.Type))
+		}
+	}
+
+	if insecure {
+		check.Remediation = append(check.Remediation, fmt.Sprintf("sign the zone of %s with DNSSEC", report.Domain))
+	}
+
+	check.Points /= float64(len(report.DNSSEC))
+	return check
+}
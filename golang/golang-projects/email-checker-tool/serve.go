@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/input"
+)
+
+// maxBatchBody bounds the size of a POST /v1/domains body
+const maxBatchBody = 1 << 20
+
+// runServe serves the checks over HTTP until it is interrupted
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", "127.0.0.1:8080", "address the HTTP server listens on")
+	concurrency := flags.Int("concurrency", 10, "number of domains checked at the same time across all requests")
+	requestTimeout := flags.Duration("request-timeout", 30*time.Second, "timeout of a single request, including the time waiting for a free check")
+	cacheTTL := flags.Duration("cache-ttl", 5*time.Minute, "how long a report is served from the cache, 0 disables the cache")
+	maxBatch := flags.Int("max-batch", 100, "maximum number of domains in a single POST /v1/domains request")
+	options := addCheckerFlags(flags)
+	flags.Parse(args)
+
+	checker := options.newChecker()
+	defer options.closeChecker(checker)
+
+	api := newAPI(checker, *concurrency, *requestTimeout, *cacheTTL, *maxBatch)
+
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           api.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		// The reports are written once the request timeout is over at the latest
+		WriteTimeout: *requestTimeout + 10*time.Second,
+	}
+
+	go func() {
+		log.Printf("Serving the checks on http://%s\n", *listen)
+
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Error %v\n", err)
+		}
+	}()
+
+	waitForSignal()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *requestTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error could not stop the HTTP server: %v\n", err)
+	}
+}
+
+// waitForSignal blocks until the process is interrupted
+func waitForSignal() {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+}
+
+// api serves the checks of a Checker as JSON
+type api struct {
+	checker  *Checker
+	cache    *reportCache
+	timeout  time.Duration
+	maxBatch int
+	// slots bounds the number of domains checked at the same time
+	slots chan struct{}
+	// inflight are the checks in progress by cache key, a domain asked again meanwhile waits for them
+	mu       sync.Mutex
+	inflight map[string]*flight
+}
+
+// flight is a check in progress, done is closed once its report or error is set
+type flight struct {
+	done   chan struct{}
+	report DomainReport
+	err    error
+}
+
+// batchRequest is the body of POST /v1/domains
+type batchRequest struct {
+	Domains []string `json:"domains"`
+}
+
+// batchResponse is the answer to POST /v1/domains, the reports are in the order of the request
+type batchResponse struct {
+	Reports []batchReport `json:"reports"`
+}
+
+// batchReport is the report of a domain of the batch. When its check did not finish,
+// only the domain and the error are set, the other domains keep their report.
+type batchReport struct {
+	DomainReport
+	Error string `json:"error,omitempty"`
+}
+
+// errorResponse is the body of every failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// newAPI returns the API of the checker
+func newAPI(checker *Checker, concurrency int, timeout, cacheTTL time.Duration, maxBatch int) *api {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &api{
+		checker:  checker,
+		cache:    newReportCache(cacheTTL),
+		timeout:  timeout,
+		maxBatch: maxBatch,
+		slots:    make(chan struct{}, concurrency),
+		inflight: make(map[string]*flight),
+	}
+}
+
+// routes returns the handler of every endpoint
+func (a *api) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/domains", a.handleBatch)
+	mux.HandleFunc("/v1/domains/", a.handleDomain)
+
+	return mux
+}
+
+// handleDomain answers GET /v1/domains/{domain}
+func (a *api) handleDomain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, "only GET is allowed")
+		return
+	}
+
+	domain := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/v1/domains/"))
+	if domain == "" || strings.Contains(domain, "/") {
+		writeError(w, http.StatusNotFound, "expected /v1/domains/{domain}")
+		return
+	}
+
+	domain, err := a.normalize(domain)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.timeout)
+	defer cancel()
+
+	report, cached, err := a.report(ctx, domain)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	if cached {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleBatch answers POST /v1/domains with the reports of every domain of the body
+func (a *api) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "only POST is allowed")
+		return
+	}
+
+	var request batchRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody))
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+		return
+	}
+
+	if len(request.Domains) == 0 {
+		writeError(w, http.StatusBadRequest, "domains is empty")
+		return
+	}
+
+	if a.maxBatch > 0 && len(request.Domains) > a.maxBatch {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("at most %d domains can be checked at once", a.maxBatch))
+		return
+	}
+
+	for i, domain := range request.Domains {
+		normalized, err := a.normalize(domain)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("domains[%d]: %v", i, err))
+			return
+		}
+
+		request.Domains[i] = normalized
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.timeout)
+	defer cancel()
+
+	response := batchResponse{Reports: make([]batchReport, len(request.Domains))}
+
+	// The slots already bound the number of checks, so every domain gets its own goroutine
+	var wg sync.WaitGroup
+	for i, domain := range request.Domains {
+		wg.Add(1)
+
+		go func(i int, domain string) {
+			defer wg.Done()
+
+			report, _, err := a.report(ctx, domain)
+			if err != nil {
+				report = DomainReport{Domain: domain}
+				response.Reports[i].Error = err.Error()
+			}
+
+			response.Reports[i].DomainReport = report
+		}(i, domain)
+	}
+	wg.Wait()
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// normalize cleans up a domain or an address of a request the same way as the command line
+// input. Addresses are reduced to their domain unless the mailboxes are verified.
+func (a *api) normalize(raw string) (string, error) {
+	entry, err := input.Normalize(raw)
+	if err != nil {
+		return "", err
+	}
+
+	if a.checker.Mailbox == nil {
+		entry.Local = ""
+	}
+
+	return entry.String(), nil
+}
+
+// report returns the report of the domain from the cache, or checks it once a slot
+// is free. It tells whether the report came from the cache. The requests asking for a
+// domain that is being checked share the result of that check instead of starting another.
+func (a *api) report(ctx context.Context, domain string) (DomainReport, bool, error) {
+	key := cacheKey(domain)
+
+	if report, ok := a.cache.get(key); ok {
+		return report, true, nil
+	}
+
+	a.mu.Lock()
+	if current, ok := a.inflight[key]; ok {
+		a.mu.Unlock()
+
+		select {
+		case <-current.done:
+			return current.report, false, current.err
+		case <-ctx.Done():
+			return DomainReport{}, false, fmt.Errorf("checking %s took too long: %v", domain, ctx.Err())
+		}
+	}
+
+	current := &flight{done: make(chan struct{})}
+	a.inflight[key] = current
+	a.mu.Unlock()
+
+	current.report, current.err = a.check(ctx, key, domain)
+
+	a.mu.Lock()
+	delete(a.inflight, key)
+	a.mu.Unlock()
+	close(current.done)
+
+	return current.report, false, current.err
+}
+
+// check checks the domain once a slot is free and caches its report
+func (a *api) check(ctx context.Context, key, domain string) (DomainReport, error) {
+	select {
+	case a.slots <- struct{}{}:
+	case <-ctx.Done():
+		return DomainReport{}, fmt.Errorf("too many checks in progress, %s was not checked: %v", domain, ctx.Err())
+	}
+
+	report := a.checker.check(ctx, domain)
+	<-a.slots
+
+	// A report cut short by the timeout is incomplete, it must not be served again
+	if ctx.Err() != nil {
+		return DomainReport{}, fmt.Errorf("checking %s took too long: %v", domain, ctx.Err())
+	}
+
+	a.cache.put(key, report)
+	return report, nil
+}
+
+// cacheKey normalizes the input so that the same domain is only cached once.
+// The local part of an address is case sensitive, so addresses are kept as is.
+func cacheKey(input string) string {
+	if strings.Contains(input, "@") {
+		return input
+	}
+
+	return strings.ToLower(strings.TrimSuffix(input, "."))
+}
+
+// writeJSON writes the value as the JSON body of the response
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Error could not write response: %v\n", err)
+	}
+}
+
+// writeError writes the message as a JSON error
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+// reportCache keeps the reports in memory for a while
+type reportCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a cached report and when it expires
+type cacheEntry struct {
+	report  DomainReport
+	expires time.Time
+}
+
+// newReportCache returns a cache that keeps the reports for ttl. A zero ttl caches nothing.
+func newReportCache(ttl time.Duration) *reportCache {
+	return &reportCache{ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+// get returns the report of the key if it has not expired yet
+func (c *reportCache) get(key string) (DomainReport, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return DomainReport{}, false
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return DomainReport{}, false
+	}
+
+	return entry.report, true
+}
+
+// put caches the report of the key, and drops the expired reports along the way
+func (c *reportCache) put(key string, report DomainReport) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for other, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, other)
+		}
+	}
+
+	c.entries[key] = cacheEntry{report: report, expires: now.Add(c.ttl)}
+}
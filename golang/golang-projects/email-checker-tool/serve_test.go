@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+)
+
+// gatedResolver counts the MX lookups and holds the ones of the gated names until the gate is
+// closed or the lookup is cancelled. The other lookups are sent to the stub nameserver.
+type gatedResolver struct {
+	resolver.Resolver
+	gated   map[string]bool
+	gate    chan struct{}
+	mu      sync.Mutex
+	lookups map[string]int
+}
+
+func (r *gatedResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.mu.Lock()
+	r.lookups[name]++
+	r.mu.Unlock()
+
+	if r.gated[name] {
+		select {
+		case <-r.gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return r.Resolver.LookupMX(ctx, name)
+}
+
+// count returns the number of MX lookups of the name
+func (r *gatedResolver) count(name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lookups[name]
+}
+
+// newTestAPI returns the API of a checker using the stub nameserver, with the MX lookups
+// of the gated names held until the gate is closed
+func newTestAPI(t *testing.T, timeout, cacheTTL time.Duration, gated ...string) (*api, *gatedResolver) {
+	t.Helper()
+
+	checker := newStubChecker(startStubDNS(t))
+	checker.Timeout = 0
+
+	dns := &gatedResolver{Resolver: checker.Resolver, gated: make(map[string]bool), gate: make(chan struct{}), lookups: make(map[string]int)}
+	for _, name := range gated {
+		dns.gated[name] = true
+	}
+	checker.Resolver = dns
+
+	return newAPI(checker, 4, timeout, cacheTTL, 3), dns
+}
+
+func TestServeDomain(t *testing.T) {
+	a, _ := newTestAPI(t, 2*time.Second, time.Minute)
+	handler := a.routes()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		cache  string
+		domain string
+		err    string
+	}{
+		{name: "miss", method: http.MethodGet, path: "/v1/domains/secure.example.test", status: http.StatusOK, cache: "MISS", domain: "secure.example.test"},
+		{name: "hit", method: http.MethodGet, path: "/v1/domains/SECURE.example.test.", status: http.StatusOK, cache: "HIT", domain: "secure.example.test"},
+		{name: "address without verification", method: http.MethodGet, path: "/v1/domains/alice@nospf.example.test", status: http.StatusOK, cache: "MISS", domain: "nospf.example.test"},
+		{name: "method", method: http.MethodPost, path: "/v1/domains/secure.example.test", status: http.StatusMethodNotAllowed, err: "only GET is allowed"},
+		{name: "no domain", method: http.MethodGet, path: "/v1/domains/", status: http.StatusNotFound, err: "expected /v1/domains/{domain}"},
+		{name: "path", method: http.MethodGet, path: "/v1/domains/secure.example.test/mx", status: http.StatusNotFound, err: "expected /v1/domains/{domain}"},
+		{name: "invalid domain", method: http.MethodGet, path: "/v1/domains/exa_mple.test", status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+
+			if recorder.Code != test.status || recorder.Header().Get("X-Cache") != test.cache {
+				t.Fatalf("%s %s = %d, X-Cache %q, want %d, %q: %s", test.method, test.path, recorder.Code, recorder.Header().Get("X-Cache"), test.status, test.cache, recorder.Body)
+			}
+
+			var body struct {
+				Domain string `json:"domain"`
+				HasMX  bool   `json:"hasMX"`
+				Error  string `json:"error"`
+			}
+
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatalf("%s %s = %q, want JSON: %v", test.method, test.path, recorder.Body, err)
+			}
+
+			if body.Domain != test.domain || (test.err != "" && body.Error != test.err) || (test.status == http.StatusOK && !body.HasMX) {
+				t.Errorf("%s %s = %s", test.method, test.path, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestServeBatch(t *testing.T) {
+	a, _ := newTestAPI(t, 2*time.Second, 0)
+	handler := a.routes()
+
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		status  int
+		domains []string
+		err     string
+	}{
+		{name: "reports", method: http.MethodPost, body: `{"domains": ["secure.example.test", "nospf.example.test."]}`, status: http.StatusOK, domains: []string{"secure.example.test", "nospf.example.test"}},
+		{name: "method", method: http.MethodGet, status: http.StatusMethodNotAllowed, err: "only POST is allowed"},
+		{name: "invalid body", method: http.MethodPost, body: `{"domains": "secure.example.test"}`, status: http.StatusBadRequest},
+		{name: "empty", method: http.MethodPost, body: `{"domains": []}`, status: http.StatusBadRequest, err: "domains is empty"},
+		{name: "too many", method: http.MethodPost, body: `{"domains": ["a.test", "b.test", "c.test", "d.test"]}`, status: http.StatusRequestEntityTooLarge, err: "at most 3 domains can be checked at once"},
+		{name: "invalid domain", method: http.MethodPost, body: `{"domains": ["secure.example.test", "exa_mple.test"]}`, status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(test.method, "/v1/domains", strings.NewReader(test.body)))
+
+			if recorder.Code != test.status {
+				t.Fatalf("%s /v1/domains = %d, want %d: %s", test.method, recorder.Code, test.status, recorder.Body)
+			}
+
+			var body struct {
+				Reports []batchReport `json:"reports"`
+				Error   string        `json:"error"`
+			}
+
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatalf("%s /v1/domains = %q, want JSON: %v", test.method, recorder.Body, err)
+			}
+
+			if test.err != "" && body.Error != test.err {
+				t.Errorf("%s /v1/domains error = %q, want %q", test.method, body.Error, test.err)
+			}
+
+			if test.status != http.StatusOK {
+				if body.Error == "" {
+					t.Errorf("%s /v1/domains = %s, want an error", test.method, recorder.Body)
+				}
+				return
+			}
+
+			if len(body.Reports) != len(test.domains) {
+				t.Fatalf("%s /v1/domains = %s, want %d reports", test.method, recorder.Body, len(test.domains))
+			}
+
+			for i, report := range body.Reports {
+				if report.Domain != test.domains[i] || !report.HasMX || report.Error != "" {
+					t.Errorf("reports[%d] = %s, %t, %q, want %s with its MX records", i, report.Domain, report.HasMX, report.Error, test.domains[i])
+				}
+			}
+		})
+	}
+}
+
+func TestServeBatchTimeout(t *testing.T) {
+	a, _ := newTestAPI(t, 200*time.Millisecond, time.Minute, "long.example.test")
+
+	recorder := httptest.NewRecorder()
+	body := `{"domains": ["secure.example.test", "long.example.test"]}`
+	a.routes().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/domains", strings.NewReader(body)))
+
+	var response batchResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); recorder.Code != http.StatusOK || err != nil || len(response.Reports) != 2 {
+		t.Fatalf("POST /v1/domains = %d %s, want the two reports", recorder.Code, recorder.Body)
+	}
+
+	// The domain that was checked in time keeps its report
+	if report := response.Reports[0]; report.Domain != "secure.example.test" || !report.HasMX || report.Error != "" {
+		t.Errorf("reports[0] = %+v, want the report of secure.example.test", report)
+	}
+
+	if report := response.Reports[1]; report.Domain != "long.example.test" || report.HasSPF || !strings.HasPrefix(report.Error, "checking long.example.test took too long") {
+		t.Errorf("reports[1] = %+v, want only the domain and the error", report)
+	}
+
+	// The report cut short is not cached, the complete one is
+	if _, ok := a.cache.get("long.example.test"); ok {
+		t.Error("the report of long.example.test was cached")
+	}
+
+	if _, ok := a.cache.get("secure.example.test"); !ok {
+		t.Error("the report of secure.example.test was not cached")
+	}
+}
+
+func TestServeSharesChecks(t *testing.T) {
+	a, dns := newTestAPI(t, 2*time.Second, 0, "secure.example.test")
+
+	const requests = 5
+
+	reports := make([]DomainReport, requests)
+	errs := make([]error, requests)
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			reports[i], _, errs[i] = a.report(context.Background(), "secure.example.test")
+		}(i)
+	}
+
+	// Lets every request find the check in progress before it finishes
+	for dns.count("secure.example.test") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(dns.gate)
+
+	wg.Wait()
+
+	if count := dns.count("secure.example.test"); count != 1 {
+		t.Errorf("%d requests made %d MX lookups, want 1", requests, count)
+	}
+
+	for i := range reports {
+		if errs[i] != nil || !reports[i].HasMX {
+			t.Errorf("request %d = %+v, %v, want the shared report", i, reports[i], errs[i])
+		}
+	}
+
+	if len(a.inflight) != 0 {
+		t.Errorf("inflight = %v, want the finished check to be removed", a.inflight)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/smtptest"
+)
+
+func TestCheckSMTP(t *testing.T) {
+	checker := newStubChecker(startStubDNS(t))
+	checker.SMTP = startStubSMTP(t, smtptest.Config{}, "mx1.secure.example.test", "mx2.secure.example.test")
+
+	report := checker.check(context.Background(), "secure.example.test")
+
+	if want := "mx1.secure.example.test:starttls/valid-cert/89d mx2.secure.example.test:starttls/valid-cert/89d"; formatSMTP(report.SMTP) != want {
+		t.Errorf("SMTP = %s, want %s", formatSMTP(report.SMTP), want)
+	}
+
+	// Without MX records there is nothing to probe
+	if report := checker.check(context.Background(), "long.example.test"); report.SMTP != nil {
+		t.Errorf("SMTP of long.example.test = %+v, want none", report.SMTP)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/dnsstub"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/resolver"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/smtpprobe"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/smtptest"
+)
+
+// fixtureZone describes the domains the tests check, see the comments of the file
+const fixtureZone = "testdata/example.test.zone"
+
+// startStubDNS serves the fixture zone on a free port until the test ends. configure
+// can change the zone before it is served, e.g. to make its answers authenticated.
+func startStubDNS(t *testing.T, configure ...func(zone *dnsstub.Zone)) *dnsstub.Server {
+	t.Helper()
+
+	zone := dnsstub.NewZone()
+	if err := zone.LoadFile(fixtureZone); err != nil {
+		t.Fatalf("could not load the fixture zone: %v", err)
+	}
+
+	for _, change := range configure {
+		change(zone)
+	}
+
+	server, err := dnsstub.Start("127.0.0.1:0", zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	return server
+}
+
+// newStubChecker returns a Checker that only makes the DNS checks, through the stub server
+func newStubChecker(server *dnsstub.Server) *Checker {
+	return &Checker{
+		Resolver: resolver.NewNameserver(server.Addr(), "udp", 2*time.Second),
+		Timeout:  2 * time.Second,
+	}
+}
+
+// startStubSMTP serves a fake SMTP server until the test ends. The MX hosts of the fixture
+// zone resolve to the loopback address, so the returned Prober reaches it for any of them.
+// With certHosts, STARTTLS is offered with a certificate valid for these names.
+func startStubSMTP(t *testing.T, config smtptest.Config, certHosts ...string) *smtpprobe.Prober {
+	t.Helper()
+
+	prober := &smtpprobe.Prober{Timeout: 2 * time.Second}
+
+	if len(certHosts) > 0 {
+		tlsConfig, rootCAs, err := smtptest.SelfSignedTLS(90*24*time.Hour, certHosts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config.TLS, prober.RootCAs = tlsConfig, rootCAs
+	}
+
+	server, err := smtptest.Start("127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	_, prober.Port, _ = net.SplitHostPort(server.Addr())
+	return prober
+}
+
+// wwwDir holds the files served by startStubHTTPS, in a subdirectory named after every host
+const wwwDir = "testdata/www"
+
+// startStubHTTPS serves the files of wwwDir over HTTPS until the test ends, with a certificate
+// valid for certHosts. The returned client trusts the certificate and sends every request to
+// the server, like --https-connect, so the certificate is still verified against the host.
+func startStubHTTPS(t *testing.T, certHosts ...string) *http.Client {
+	t.Helper()
+
+	tlsConfig, rootCAs, err := smtptest.SelfSignedTLS(90*24*time.Hour, certHosts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := strings.ToLower(r.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		http.FileServer(http.Dir(filepath.Join(wwwDir, host))).ServeHTTP(w, r)
+	}))
+
+	server.TLS = tlsConfig
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	dialer := &net.Dialer{}
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: rootCAs},
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}
+	t.Cleanup(transport.CloseIdleConnections)
+
+	return &http.Client{Transport: transport, Timeout: 2 * time.Second}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/input"
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/watch"
+)
+
+// Fields of the snapshots taken by the watch mode
+const (
+	FieldMX         = "mx"
+	FieldSPF        = "spf"
+	FieldDMARC      = "dmarc"
+	FieldMTASTS     = "mtaSts"
+	FieldMTASTSMode = "mtaStsMode"
+	FieldTLSRPT     = "tlsRpt"
+	FieldBIMI       = "bimi"
+	FieldDKIM       = "dkim"
+	FieldBlocklists = "blocklists"
+	FieldGrade      = "grade"
+)
+
+// runWatch checks the domains of a file again and again, and reports what changed since the last run
+func runWatch(args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	domainsFile := flags.String("domains", "", "file of the domains to watch, one per line, read again before every run")
+	interval := flags.Duration("interval", time.Hour, "time between the start of two runs")
+	once := flags.Bool("once", false, "check the domains a single time and exit, e.g. when run from cron")
+	concurrency := flags.Int("concurrency", 10, "number of domains checked at the same time")
+	snapshotDir := flags.String("snapshot-dir", defaultSnapshotDir(), "directory the last snapshot of every domain is kept in")
+	quiet := flags.Bool("quiet", false, "do not write the events to stdout")
+	eventsFile := flags.String("events-file", "", "file the events are appended to, one JSON object per line")
+	webhookURL := flags.String("webhook", "", "URL the events of every run are posted to as {\"events\": [...]}")
+	webhookTimeout := flags.Duration("webhook-timeout", 10*time.Second, "timeout of a single webhook request")
+	options := addCheckerFlags(flags)
+	flags.Parse(args)
+
+	if *domainsFile == "" {
+		log.Fatalf("Error --domains is required\n")
+	}
+
+	if *interval <= 0 {
+		log.Fatalf("Error --interval must be positive\n")
+	}
+
+	var notifiers []watch.Notifier
+
+	if !*quiet {
+		notifiers = append(notifiers, watch.NewWriterNotifier(os.Stdout))
+	}
+
+	if *eventsFile != "" {
+		file, err := os.OpenFile(*eventsFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalf("Error could not open the events file: %v\n", err)
+		}
+		defer file.Close()
+
+		notifiers = append(notifiers, watch.NewWriterNotifier(file))
+	}
+
+	if *webhookURL != "" {
+		notifiers = append(notifiers, &watch.Webhook{URL: *webhookURL, Client: &http.Client{Timeout: *webhookTimeout}})
+	}
+
+	checker := options.newChecker()
+	defer options.closeChecker(checker)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	w := &watcher{
+		checker:     checker,
+		store:       &watch.Store{Dir: *snapshotDir},
+		notifiers:   notifiers,
+		concurrency: *concurrency,
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.run(ctx, *domainsFile); err != nil && ctx.Err() == nil {
+			log.Printf("Error %v\n", err)
+		}
+
+		if *once {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watcher runs the checks of the watch mode
+type watcher struct {
+	checker     *Checker
+	store       *watch.Store
+	notifiers   []watch.Notifier
+	concurrency int
+}
+
+// run checks every domain of the file once, compares them with their last snapshot and
+// sends the changes to the notifiers
+func (w *watcher) run(ctx context.Context, domainsFile string) error {
+	domains, err := readDomains(domainsFile, w.checker.Mailbox != nil)
+	if err != nil {
+		return fmt.Errorf("could not read the domains: %v", err)
+	}
+
+	queue := make(chan string, len(domains))
+	for _, domain := range domains {
+		queue <- domain
+	}
+	close(queue)
+
+	var events []watch.Event
+	var snapshots []*watch.Snapshot
+	first := 0
+
+	err = checkAll(ctx, w.checker, queue, w.concurrency, nil, func(report DomainReport) error {
+		snapshot := w.checker.snapshot(report)
+
+		last, err := w.store.Load(snapshot.Domain)
+		if err != nil {
+			log.Printf("Error %v, starting over\n", err)
+		}
+
+		// The first snapshot of a domain is the baseline, there is nothing to compare it with
+		if last == nil {
+			first++
+		} else {
+			events = append(events, watch.Diff(last, snapshot)...)
+		}
+
+		snapshots = append(snapshots, snapshot)
+		return nil
+	})
+
+	// An interrupted run saves nothing, the next one compares every domain with the same snapshots again
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Checked %d domains, %d seen for the first time, %d changes\n", len(domains), first, len(events))
+
+	// The snapshots are only saved once the events are sent, so that the events that
+	// could not be sent are found again by the next run
+	if err := w.notify(ctx, events); err != nil {
+		return err
+	}
+
+	for _, snapshot := range snapshots {
+		if err := w.store.Save(snapshot); err != nil {
+			return fmt.Errorf("could not save the snapshot of %s: %v", snapshot.Domain, err)
+		}
+	}
+
+	return nil
+}
+
+// notify sends the events to every notifier, even when one of them fails
+func (w *watcher) notify(ctx context.Context, events []watch.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	failed := 0
+	for _, notifier := range w.notifiers {
+		if err := notifier.Notify(ctx, events); err != nil {
+			log.Printf("Error could not send the events: %v\n", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d notifiers failed, the snapshots are kept for the next run", failed, len(w.notifiers))
+	}
+
+	return nil
+}
+
+// snapshot keeps the fields of the report that are watched. The checks that are
+// turned off, or whose lookup failed, are unknown rather than missing.
+func (c *Checker) snapshot(report DomainReport) *watch.Snapshot {
+	snapshot := &watch.Snapshot{
+		Domain: cacheKey(report.Domain),
+		Time:   time.Now().UTC(),
+		Fields: make(map[string]string),
+	}
+
+	set := func(field, value string, known bool) {
+		switch {
+		case !known:
+			snapshot.Unknown = append(snapshot.Unknown, field)
+		case value != "":
+			snapshot.Fields[field] = value
+		}
+	}
+
+	mxHosts := append([]string{}, report.MXRecords...)
+	sort.Strings(mxHosts)
+
+	set(FieldMX, strings.Join(mxHosts, " "), !report.failed(LookupMX))
+	set(FieldSPF, report.SPFRecord, !report.failed(LookupSPF))
+	set(FieldDMARC, report.DMARCRecord, !report.failed(LookupDMARC))
+
+	var stsRecord, stsMode string
+	stsKnown := c.MTASTS != nil
+
+	if report.MTASTS != nil {
+		if report.MTASTS.Record != nil {
+			stsRecord = report.MTASTS.Record.Raw
+		}
+
+		if report.MTASTS.Policy != nil {
+			stsMode = report.MTASTS.Policy.Mode
+		}
+
+		// Without a record the report only tells that the lookup failed
+		stsKnown = stsKnown && report.MTASTS.Record != nil
+	}
+
+	set(FieldMTASTS, stsRecord, stsKnown)
+	set(FieldMTASTSMode, stsMode, stsKnown)
+
+	var rptRecord string
+	rptKnown := true
+
+	if report.TLSRPT != nil {
+		if report.TLSRPT.Record != nil {
+			rptRecord = report.TLSRPT.Record.Raw
+		} else {
+			rptKnown = len(report.TLSRPT.Errors) == 0
+		}
+	}
+
+	set(FieldTLSRPT, rptRecord, rptKnown)
+
+	var bimiRecord string
+	if report.BIMI != nil && report.BIMI.Record != nil {
+		bimiRecord = report.BIMI.Record.Raw
+	}
+
+	set(FieldBIMI, bimiRecord, c.BIMI != nil)
+
+	var selectors []string
+	if report.DKIM != nil {
+		for _, selector := range report.DKIM.Selectors {
+			selectors = append(selectors, selector.Selector)
+		}
+	}
+
+	sort.Strings(selectors)
+	set(FieldDKIM, strings.Join(selectors, " "), report.DKIM != nil && len(report.DKIM.Errors) == 0)
+
+	var listings []string
+	listingsKnown := c.Reputation != nil && report.Reputation != nil
+
+	if report.Reputation != nil {
+		for _, host := range report.Reputation.Hosts {
+			// A blocklist that could not be queried may still list the address
+			listingsKnown = listingsKnown && len(host.Errors) == 0
+
+			for _, address := range host.Addresses {
+				listingsKnown = listingsKnown && len(address.Errors) == 0
+
+				for _, listing := range address.Listings {
+					listings = append(listings, address.IP+"@"+listing.Blocklist)
+				}
+			}
+		}
+	}
+
+	sort.Strings(listings)
+	set(FieldBlocklists, strings.Join(listings, " "), listingsKnown)
+
+	var grade string
+	if report.Score != nil {
+		grade = report.Score.Grade
+	}
+
+	set(FieldGrade, grade, report.Score != nil)
+
+	return snapshot
+}
+
+// readDomains reads the normalized domains of the file, one per line, without the
+// blank lines, the comments and the duplicates
+func readDomains(path string, keepAddresses bool) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var domains []string
+
+	reader := &input.Reader{KeepAddresses: keepAddresses, OnInvalid: logInvalidInput}
+	err = reader.Read(file, path, func(domain string) error {
+		domains = append(domains, domain)
+		return nil
+	})
+
+	return domains, err
+}
+
+// defaultSnapshotDir returns the directory of the snapshots in the user cache directory
+func defaultSnapshotDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "snapshots"
+	}
+
+	return filepath.Join(dir, "email-checker-tool", "snapshots")
+}
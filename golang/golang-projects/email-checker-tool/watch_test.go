@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rmarasigan/freecodecamp/email-checker-tool/pkg/watch"
+)
+
+// fakeNotifier records the events it is sent, and fails while err is set
+type fakeNotifier struct {
+	err    error
+	events []watch.Event
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, events []watch.Event) error {
+	if n.err != nil {
+		return n.err
+	}
+
+	n.events = append(n.events, events...)
+	return nil
+}
+
+// newTestWatcher returns a watcher of the domains through the stub nameserver. The last
+// snapshot of secure.example.test has another DMARC record, so every run finds a change.
+func newTestWatcher(t *testing.T, notifiers ...watch.Notifier) (*watcher, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	domainsFile := filepath.Join(dir, "domains.txt")
+	if err := os.WriteFile(domainsFile, []byte("secure.example.test\nnospf.example.test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &watcher{
+		checker:     newStubChecker(startStubDNS(t)),
+		store:       &watch.Store{Dir: filepath.Join(dir, "snapshots")},
+		notifiers:   notifiers,
+		concurrency: 2,
+	}
+
+	last := &watch.Snapshot{
+		Domain: "secure.example.test",
+		Time:   time.Now().Add(-time.Hour).UTC(),
+		Fields: map[string]string{
+			FieldMX:     "mx1.secure.example.test. mx2.secure.example.test.",
+			FieldSPF:    "v=spf1 ip4:192.0.2.0/24 include:_spf.secure.example.test -all",
+			FieldDMARC:  "v=DMARC1; p=none",
+			FieldTLSRPT: "v=TLSRPTv1; rua=mailto:tlsrpt@secure.example.test",
+		},
+	}
+
+	if err := w.store.Save(last); err != nil {
+		t.Fatal(err)
+	}
+
+	return w, domainsFile
+}
+
+// dmarcField returns the DMARC record of the last snapshot of the domain
+func dmarcField(t *testing.T, store *watch.Store, domain string) (string, bool) {
+	t.Helper()
+
+	snapshot, err := store.Load(domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if snapshot == nil {
+		return "", false
+	}
+
+	return snapshot.Fields[FieldDMARC], true
+}
+
+func TestWatcherRun(t *testing.T) {
+	notifier := &fakeNotifier{}
+	w, domainsFile := newTestWatcher(t, notifier)
+
+	if err := w.run(context.Background(), domainsFile); err != nil {
+		t.Fatalf("run() = %v", err)
+	}
+
+	if len(notifier.events) != 1 || notifier.events[0].Domain != "secure.example.test" || notifier.events[0].Field != FieldDMARC || notifier.events[0].Kind != watch.Changed {
+		t.Fatalf("events = %+v, want the DMARC record of secure.example.test changed", notifier.events)
+	}
+
+	if dmarc, _ := dmarcField(t, w.store, "secure.example.test"); dmarc != "v=DMARC1; p=reject; pct=100; rua=mailto:dmarc@secure.example.test" {
+		t.Errorf("DMARC of the snapshot = %q, want the new record", dmarc)
+	}
+
+	if _, ok := dmarcField(t, w.store, "nospf.example.test"); !ok {
+		t.Error("no baseline snapshot of nospf.example.test")
+	}
+
+	// Nothing changed since
+	if err := w.run(context.Background(), domainsFile); err != nil || len(notifier.events) != 1 {
+		t.Errorf("run() again = %v, %d events, want no new event", err, len(notifier.events))
+	}
+}
+
+func TestWatcherRunNotifyFailed(t *testing.T) {
+	working, failing := &fakeNotifier{}, &fakeNotifier{err: errors.New("webhook unreachable")}
+	w, domainsFile := newTestWatcher(t, working, failing)
+
+	if err := w.run(context.Background(), domainsFile); err == nil {
+		t.Fatal("run() = nil, want the notifier error")
+	}
+
+	// The other notifiers are still sent the events
+	if len(working.events) != 1 {
+		t.Errorf("working notifier got %d events, want 1", len(working.events))
+	}
+
+	if dmarc, _ := dmarcField(t, w.store, "secure.example.test"); dmarc != "v=DMARC1; p=none" {
+		t.Errorf("DMARC of the snapshot = %q, want the old record to be kept", dmarc)
+	}
+
+	if _, ok := dmarcField(t, w.store, "nospf.example.test"); ok {
+		t.Error("the baseline of nospf.example.test was saved")
+	}
+
+	// The next run finds the same change again and sends it
+	failing.err = nil
+
+	if err := w.run(context.Background(), domainsFile); err != nil {
+		t.Fatalf("run() again = %v", err)
+	}
+
+	if len(failing.events) != 1 || failing.events[0].Field != FieldDMARC {
+		t.Errorf("events = %+v, want the DMARC change sent again", failing.events)
+	}
+
+	if dmarc, _ := dmarcField(t, w.store, "secure.example.test"); dmarc == "v=DMARC1; p=none" {
+		t.Error("the snapshot was not saved once the events were sent")
+	}
+}
+
+func TestWatcherRunInterrupted(t *testing.T) {
+	notifier := &fakeNotifier{}
+	w, domainsFile := newTestWatcher(t, notifier)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := w.run(ctx, domainsFile); err == nil {
+		t.Fatal("run() = nil, want the context error")
+	}
+
+	if len(notifier.events) != 0 {
+		t.Errorf("events = %+v, want none", notifier.events)
+	}
+
+	if dmarc, _ := dmarcField(t, w.store, "secure.example.test"); dmarc != "v=DMARC1; p=none" {
+		t.Errorf("DMARC of the snapshot = %q, want the old record to be kept", dmarc)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// requireToken only lets the requests with the bearer token through, the others get 401
+func requireToken(token string) middleware {
+	expected := []byte("Bearer " + token)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="submissions"`)
+				writeJSONError(w, http.StatusUnauthorized, "a valid bearer token is required")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// submissionsHandler answers GET /api/submissions with every submission, the oldest first
+func (a *app) submissionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "only GET is allowed")
+		return
+	}
+
+	submissions, err := a.store.List(r.Context())
+	if err != nil {
+		a.storeError(w, r, err)
+		return
+	}
+
+	// An empty store is an empty array, not null
+	if submissions == nil {
+		submissions = []Submission{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"submissions": submissions})
+}
+
+// submissionHandler answers GET and DELETE /api/submissions/{id}
+func (a *app) submissionHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/submissions/")
+	if id == "" || strings.Contains(id, "/") {
+		writeJSONError(w, http.StatusNotFound, "expected /api/submissions/{id}")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		submission, err := a.store.Get(r.Context(), id)
+		if err != nil {
+			a.storeError(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, submission)
+	case http.MethodDelete:
+		submission, err := a.store.Get(r.Context(), id)
+		if err != nil {
+			a.storeError(w, r, err)
+			return
+		}
+
+		if err := a.store.Delete(r.Context(), id); err != nil {
+			a.storeError(w, r, err)
+			return
+		}
+
+		// The files are only removed once nothing points to them anymore
+		a.uploads.removeAll(submission.Attachments)
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "only GET and DELETE are allowed")
+	}
+}
+
+// storeError answers 404 for an unknown submission, and 500 for the other errors of the store
+func (a *app) storeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errNotFound) {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	log.Printf("Error the store failed (request %s): %v", requestIDFrom(r.Context()), err)
+	writeJSONError(w, http.StatusInternalServerError, "the store failed")
+}
+
+// writeJSON writes the value as the JSON body of the response
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Error could not write the response: %v", err)
+	}
+}
+
+// writeJSONError writes {"error": message} with the status
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	const token = "0123456789abcdef0123456789abcdef"
+
+	handler := requireToken(token)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{name: "valid", authorization: "Bearer " + token, status: http.StatusNoContent},
+		{name: "missing", status: http.StatusUnauthorized},
+		{name: "wrong", authorization: "Bearer " + token[1:] + "0", status: http.StatusUnauthorized},
+		{name: "prefix", authorization: "Bearer " + token[:16], status: http.StatusUnauthorized},
+		{name: "not bearer", authorization: "Basic " + token, status: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/submissions", nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Errorf("Authorization %q = %d, want %d", test.authorization, w.Code, test.status)
+			}
+
+			if test.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("Authorization %q has no WWW-Authenticate header", test.authorization)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// envPrefix is the prefix of the environment variables read by the server
+const envPrefix = "GO_SERVER_"
+
+// minCSRFKeyLength is the shortest CSRF key accepted, shorter ones can be guessed
+const minCSRFKeyLength = 32
+
+// minAPITokenLength is the shortest API token accepted, shorter ones can be guessed
+const minAPITokenLength = 32
+
+// config holds the settings of the server. A flag wins over its environment
+// variable, which wins over the default value.
+type config struct {
+	Addr              string
+	StaticDir         string
+	StaticEmbed       bool
+	SPA               bool
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+	Store             string
+	StorePath         string
+	CSRFKey           string
+	UploadDir         string
+	APIToken          string
+	MaxUploadSize     int64
+}
+
+// loadConfig reads the settings from the environment and then from the command line arguments
+func loadConfig(args []string) (*config, error) {
+	cfg := &config{}
+	flags := flag.NewFlagSet("go-server", flag.ExitOnError)
+
+	// The environment variables only change the default value of the flags
+	env := &envReader{}
+
+	flags.StringVar(&cfg.Addr, "addr", env.String("ADDR", ":8080"), "address the server listens on (env "+envPrefix+"ADDR)")
+	flags.StringVar(&cfg.StaticDir, "static-dir", env.String("STATIC_DIR", "./static"), "directory the static files are served from (env "+envPrefix+"STATIC_DIR)")
+	flags.BoolVar(&cfg.StaticEmbed, "static-embed", env.Bool("STATIC_EMBED", false), "serve the static files built into the binary instead of --static-dir (env "+envPrefix+"STATIC_EMBED)")
+	flags.BoolVar(&cfg.SPA, "spa", env.Bool("SPA", false), "serve index.html for the unknown paths without extension, for a single page application (env "+envPrefix+"SPA)")
+	flags.DurationVar(&cfg.ReadTimeout, "read-timeout", env.Duration("READ_TIMEOUT", 10*time.Second), "maximum time to read a whole request, body included (env "+envPrefix+"READ_TIMEOUT)")
+	flags.DurationVar(&cfg.ReadHeaderTimeout, "read-header-timeout", env.Duration("READ_HEADER_TIMEOUT", 5*time.Second), "maximum time to read the headers of a request (env "+envPrefix+"READ_HEADER_TIMEOUT)")
+	flags.DurationVar(&cfg.WriteTimeout, "write-timeout", env.Duration("WRITE_TIMEOUT", 10*time.Second), "maximum time to write a response (env "+envPrefix+"WRITE_TIMEOUT)")
+	flags.DurationVar(&cfg.IdleTimeout, "idle-timeout", env.Duration("IDLE_TIMEOUT", 60*time.Second), "maximum time a keep-alive connection waits for the next request (env "+envPrefix+"IDLE_TIMEOUT)")
+	flags.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", env.Duration("SHUTDOWN_TIMEOUT", 15*time.Second), "maximum time to drain the requests in flight on SIGTERM (env "+envPrefix+"SHUTDOWN_TIMEOUT)")
+	flags.StringVar(&cfg.Store, "store", env.String("STORE", "jsonl"), "where the submissions are kept: jsonl, or sqlite when built with -tags sqlite (env "+envPrefix+"STORE)")
+	flags.StringVar(&cfg.StorePath, "store-path", env.String("STORE_PATH", ""), "file of the store, submissions.jsonl or submissions.db by default (env "+envPrefix+"STORE_PATH)")
+	flags.StringVar(&cfg.CSRFKey, "csrf-key", env.String("CSRF_KEY", ""), "secret the CSRF tokens are signed with, at least 32 characters, random when empty (env "+envPrefix+"CSRF_KEY)")
+	flags.StringVar(&cfg.UploadDir, "upload-dir", env.String("UPLOAD_DIR", "./uploads"), "directory the files attached to the forms are saved to (env "+envPrefix+"UPLOAD_DIR)")
+	flags.StringVar(&cfg.APIToken, "api-token", env.String("API_TOKEN", ""), "bearer token of the /api/submissions endpoints, at least 32 characters, the API is off when empty (env "+envPrefix+"API_TOKEN)")
+	flags.Int64Var(&cfg.MaxUploadSize, "max-upload-size", env.Int64("MAX_UPLOAD_SIZE", 10<<20), "maximum size in bytes of a multipart form, attachments included (env "+envPrefix+"MAX_UPLOAD_SIZE)")
+
+	if env.err != nil {
+		return nil, env.err
+	}
+
+	flags.Parse(args)
+
+	if cfg.CSRFKey != "" && len(cfg.CSRFKey) < minCSRFKeyLength {
+		return nil, fmt.Errorf("the CSRF key must be at least %d characters", minCSRFKeyLength)
+	}
+
+	if cfg.APIToken != "" && len(cfg.APIToken) < minAPITokenLength {
+		return nil, fmt.Errorf("the API token must be at least %d characters", minAPITokenLength)
+	}
+
+	if cfg.MaxUploadSize <= 0 {
+		return nil, fmt.Errorf("the maximum upload size must be positive")
+	}
+
+	// The embedded files do not need the directory
+	if cfg.StaticEmbed {
+		return cfg, nil
+	}
+
+	info, err := os.Stat(cfg.StaticDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid static directory: %v", err)
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("invalid static directory: %s is not a directory", cfg.StaticDir)
+	}
+
+	return cfg, nil
+}
+
+// envReader reads the environment variables and keeps the first invalid one
+type envReader struct {
+	err error
+}
+
+// String returns the value of the variable, or fallback when it is not set
+func (e *envReader) String(name, fallback string) string {
+	if value, ok := os.LookupEnv(envPrefix + name); ok {
+		return value
+	}
+
+	return fallback
+}
+
+// Bool returns the value of the variable, e.g. true or 1, or fallback when it is not set
+func (e *envReader) Bool(name string, fallback bool) bool {
+	value, ok := os.LookupEnv(envPrefix + name)
+	if !ok {
+		return fallback
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		if e.err == nil {
+			e.err = fmt.Errorf("invalid %s%s: %v", envPrefix, name, err)
+		}
+
+		return fallback
+	}
+
+	return enabled
+}
+
+// Duration returns the value of the variable, e.g. 30s, or fallback when it is not set
+func (e *envReader) Duration(name string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(envPrefix + name)
+	if !ok {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		if e.err == nil {
+			e.err = fmt.Errorf("invalid %s%s: %v", envPrefix, name, err)
+		}
+
+		return fallback
+	}
+
+	return duration
+}
+
+// Int64 returns the value of the variable, or fallback when it is not set
+func (e *envReader) Int64(name string, fallback int64) int64 {
+	value, ok := os.LookupEnv(envPrefix + name)
+	if !ok {
+		return fallback
+	}
+
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		if e.err == nil {
+			e.err = fmt.Errorf("invalid %s%s: %v", envPrefix, name, err)
+		}
+
+		return fallback
+	}
+
+	return number
+}
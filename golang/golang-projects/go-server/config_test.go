@@ -0,0 +1,105 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		args  []string
+		check func(cfg *config) bool
+	}{
+		{
+			name: "defaults",
+			check: func(cfg *config) bool {
+				return cfg.Addr == ":8080" && cfg.ReadTimeout == 10*time.Second && cfg.Store == "jsonl" && cfg.APIToken == ""
+			},
+		},
+		{
+			name: "env over the default",
+			env:  map[string]string{"ADDR": "127.0.0.1:8181", "READ_TIMEOUT": "30s", "SPA": "true", "MAX_UPLOAD_SIZE": "1024"},
+			check: func(cfg *config) bool {
+				return cfg.Addr == "127.0.0.1:8181" && cfg.ReadTimeout == 30*time.Second && cfg.SPA && cfg.MaxUploadSize == 1024
+			},
+		},
+		{
+			name: "flag over the env",
+			env:  map[string]string{"ADDR": "127.0.0.1:8181", "READ_TIMEOUT": "30s", "SPA": "true"},
+			args: []string{"--addr", "127.0.0.1:9090", "--read-timeout", "5s", "--spa=false"},
+			check: func(cfg *config) bool {
+				return cfg.Addr == "127.0.0.1:9090" && cfg.ReadTimeout == 5*time.Second && !cfg.SPA
+			},
+		},
+		{
+			name: "long enough secrets",
+			env:  map[string]string{"CSRF_KEY": strings.Repeat("k", minCSRFKeyLength)},
+			args: []string{"--api-token", strings.Repeat("t", minAPITokenLength)},
+			check: func(cfg *config) bool {
+				return len(cfg.CSRFKey) == minCSRFKeyLength && len(cfg.APIToken) == minAPITokenLength
+			},
+		},
+		{
+			// The embedded files need no directory
+			name:  "embedded static files",
+			args:  []string{"--static-embed", "--static-dir", "missing"},
+			check: func(cfg *config) bool { return cfg.StaticEmbed },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(envPrefix+name, value)
+			}
+
+			cfg, err := loadConfig(test.args)
+			if err != nil {
+				t.Fatalf("loadConfig(%q) = %v", test.args, err)
+			}
+
+			if !test.check(cfg) {
+				t.Errorf("loadConfig(%q) with %v = %+v", test.args, test.env, cfg)
+			}
+		})
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		err  string
+	}{
+		{name: "invalid duration", env: map[string]string{"READ_TIMEOUT": "soon"}, err: "invalid GO_SERVER_READ_TIMEOUT"},
+		{name: "invalid bool", env: map[string]string{"SPA": "maybe"}, err: "invalid GO_SERVER_SPA"},
+		{name: "invalid number", env: map[string]string{"MAX_UPLOAD_SIZE": "10MB"}, err: "invalid GO_SERVER_MAX_UPLOAD_SIZE"},
+		// An invalid env var is an error even when a flag overrides it
+		{name: "invalid env under a flag", env: map[string]string{"READ_TIMEOUT": "soon"}, args: []string{"--read-timeout", "5s"}, err: "invalid GO_SERVER_READ_TIMEOUT"},
+		{name: "short CSRF key", env: map[string]string{"CSRF_KEY": "secret"}, err: "the CSRF key must be at least 32 characters"},
+		{name: "short CSRF key flag", args: []string{"--csrf-key", strings.Repeat("k", minCSRFKeyLength-1)}, err: "the CSRF key must be at least 32 characters"},
+		{name: "short API token", args: []string{"--api-token", "token"}, err: "the API token must be at least 32 characters"},
+		{name: "short API token env", env: map[string]string{"API_TOKEN": strings.Repeat("t", minAPITokenLength-1)}, err: "the API token must be at least 32 characters"},
+		{name: "zero upload size", args: []string{"--max-upload-size", "0"}, err: "the maximum upload size must be positive"},
+		{name: "missing static directory", args: []string{"--static-dir", "missing"}, err: "invalid static directory"},
+		{name: "static file", args: []string{"--static-dir", filepath.Join("static", indexFile)}, err: "is not a directory"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(envPrefix+name, value)
+			}
+
+			cfg, err := loadConfig(test.args)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("loadConfig(%q) with %v = %+v, %v, want an error with %q", test.args, test.env, cfg, err, test.err)
+			}
+		})
+	}
+}
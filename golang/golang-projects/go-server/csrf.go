@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// csrfCookie holds a random value the CSRF token of the forms is derived from
+const csrfCookie = "csrf"
+
+// csrfField is the hidden field of the forms that carries the CSRF token
+const csrfField = "csrf_token"
+
+// csrf protects the forms with signed double submit tokens: the cookie holds a random
+// value and the form its HMAC. Another site can make the browser send the cookie, but
+// cannot read it to compute the token, nor forge a cookie that matches without the key.
+type csrf struct {
+	key []byte
+}
+
+// token returns the CSRF token of the forms, and sets the cookie it is derived from when the client has none
+func (c *csrf) token(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(csrfCookie); err == nil && cookie.Value != "" {
+		return c.sign(cookie.Value)
+	}
+
+	value := randomID()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return c.sign(value)
+}
+
+// valid tells if the token of the submitted form matches the cookie
+func (c *csrf) valid(r *http.Request) bool {
+	cookie, err := r.Cookie(csrfCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	token, err := hex.DecodeString(r.PostFormValue(csrfField))
+	if err != nil {
+		return false
+	}
+
+	expected, _ := hex.DecodeString(c.sign(cookie.Value))
+	return hmac.Equal(token, expected)
+}
+
+// sign returns the HMAC of the cookie value as hex
+func (c *csrf) sign(value string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Maximum lengths of the fields of the form, in characters
+const (
+	maxNameLength    = 100
+	maxAddressLength = 200
+)
+
+// maxFormBody bounds the urlencoded and JSON bodies, which carry no file
+const maxFormBody = 1 << 20
+
+// maxMultipartMemory is the part of a multipart body kept in memory, the rest of the files goes to temporary files
+const maxMultipartMemory = 1 << 20
+
+// jsonSubmission is the body of a form sent as JSON
+type jsonSubmission struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// submissionForm holds the values of the form
+type submissionForm struct {
+	Name    string
+	Address string
+}
+
+// formPage is the data of the form page
+type formPage struct {
+	CSRFField  string
+	CSRFToken  string
+	Values     submissionForm
+	Errors     map[string]string
+	MaxName    int
+	MaxAddress int
+}
+
+// validate trims the values and returns the error of every invalid field by field name, nil when the form is valid
+func (f *submissionForm) validate() map[string]string {
+	errs := make(map[string]string)
+
+	f.Name = strings.TrimSpace(f.Name)
+	f.Address = strings.TrimSpace(f.Address)
+
+	if message := validateField(f.Name, maxNameLength); message != "" {
+		errs["name"] = "Name " + message
+	}
+
+	if message := validateField(f.Address, maxAddressLength); message != "" {
+		errs["address"] = "Address " + message
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// validateField tells what is wrong with a required value, empty when nothing is
+func validateField(value string, maxLength int) string {
+	switch {
+	case value == "":
+		return "is required"
+	case !utf8.ValidString(value):
+		return "is not valid text"
+	case utf8.RuneCountInString(value) > maxLength:
+		return fmt.Sprintf("must be at most %d characters", maxLength)
+	case strings.IndexFunc(value, unicode.IsControl) >= 0:
+		return "cannot contain control characters"
+	}
+
+	return ""
+}
+
+// Response is what the server sends beck to the user
+// Requests is something that the user sends to the server
+func (a *app) formHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		a.renderForm(w, r, http.StatusOK, submissionForm{}, nil)
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		a.formError(w, r, http.StatusMethodNotAllowed, "The form only accepts GET and POST requests.")
+		return
+	}
+
+	// User will submit something and there will be a POST
+	// request and then that will parse the form
+	form, files, status, message := a.readForm(w, r)
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+
+	if status != 0 {
+		a.formError(w, r, status, message)
+		return
+	}
+
+	errs := form.validate()
+	if len(files) > maxAttachments {
+		if errs == nil {
+			errs = make(map[string]string)
+		}
+
+		errs[attachmentsField] = fmt.Sprintf("At most %d files can be attached", maxAttachments)
+	}
+
+	// The form is shown again with the errors and what was typed
+	if errs != nil {
+		if wantsJSON(r) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"error": "invalid submission", "fields": errs})
+		} else {
+			a.renderForm(w, r, http.StatusUnprocessableEntity, form, errs)
+		}
+
+		return
+	}
+
+	attachments, err := a.uploads.saveAll(files)
+	if err != nil {
+		log.Printf("Error could not save the attachments (request %s): %v", requestIDFrom(r.Context()), err)
+		a.formError(w, r, http.StatusInternalServerError, "The attachments could not be saved, please try again later.")
+		return
+	}
+
+	submission := &Submission{
+		Name:        form.Name,
+		Address:     form.Address,
+		Attachments: attachments,
+		RequestID:   requestIDFrom(r.Context()),
+	}
+
+	if err := a.store.Add(r.Context(), submission); err != nil {
+		a.uploads.removeAll(attachments)
+
+		log.Printf("Error could not save the submission (request %s): %v", submission.RequestID, err)
+		a.formError(w, r, http.StatusInternalServerError, "The form could not be saved, please try again later.")
+		return
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusCreated, submission)
+		return
+	}
+
+	a.pages.render(w, http.StatusCreated, pageSuccess, submission)
+}
+
+// readForm reads the values and the files of the body according to its content type.
+// It returns the status and the message of the error when the body cannot be used.
+func (a *app) readForm(w http.ResponseWriter, r *http.Request) (submissionForm, []*multipart.FileHeader, int, string) {
+	var form submissionForm
+
+	switch requestMediaType(r) {
+	case mediaJSON:
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormBody)
+
+		var body jsonSubmission
+
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(&body); err != nil {
+			if isTooLarge(err) {
+				return form, nil, http.StatusRequestEntityTooLarge, fmt.Sprintf("The body must be at most %d bytes.", maxFormBody)
+			}
+
+			return form, nil, http.StatusBadRequest, fmt.Sprintf("The JSON body is invalid: %v.", err)
+		}
+
+		// A cross-site page cannot send a JSON body without a CORS preflight, which
+		// the server does not answer, so a JSON request needs no CSRF token
+		return submissionForm{Name: body.Name, Address: body.Address}, nil, 0, ""
+	case mediaForm:
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormBody)
+
+		if err := r.ParseForm(); err != nil {
+			if isTooLarge(err) {
+				return form, nil, http.StatusRequestEntityTooLarge, fmt.Sprintf("The form must be at most %d bytes.", maxFormBody)
+			}
+
+			return form, nil, http.StatusBadRequest, "The form could not be read, please send it again."
+		}
+	case mediaMultipart:
+		r.Body = http.MaxBytesReader(w, r.Body, a.maxUploadSize)
+
+		if err := r.ParseMultipartForm(maxMultipartMemory); err != nil {
+			if isTooLarge(err) {
+				return form, nil, http.StatusRequestEntityTooLarge, fmt.Sprintf("The form and its attachments must be at most %d bytes.", a.maxUploadSize)
+			}
+
+			return form, nil, http.StatusBadRequest, "The form could not be read, please send it again."
+		}
+	default:
+		return form, nil, http.StatusUnsupportedMediaType, "The form must be sent as application/x-www-form-urlencoded, multipart/form-data or application/json."
+	}
+
+	// The token proves that the form was sent from our own page
+	if !a.csrf.valid(r) {
+		return form, nil, http.StatusForbidden, "The form expired or was sent from another site, please fill it in again."
+	}
+
+	// Getting values coming from the form
+	form.Name = r.PostFormValue("name")
+	form.Address = r.PostFormValue("address")
+
+	var files []*multipart.FileHeader
+	if r.MultipartForm != nil {
+		files = r.MultipartForm.File[attachmentsField]
+	}
+
+	return form, files, 0, ""
+}
+
+// formError answers the error as JSON or as the error page, depending on what the client accepts
+func (a *app) formError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if wantsJSON(r) {
+		writeJSONError(w, status, message)
+		return
+	}
+
+	a.pages.renderError(w, r, status, message)
+}
+
+// isTooLarge tells if reading the body failed because of http.MaxBytesReader
+func isTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "request body too large")
+}
+
+// renderForm writes the form page with the values and the errors of the last submission
+func (a *app) renderForm(w http.ResponseWriter, r *http.Request, status int, values submissionForm, errs map[string]string) {
+	a.pages.render(w, status, pageForm, formPage{
+		CSRFField:  csrfField,
+		CSRFToken:  a.csrf.token(w, r),
+		Values:     values,
+		Errors:     errs,
+		MaxName:    maxNameLength,
+		MaxAddress: maxAddressLength,
+	})
+}
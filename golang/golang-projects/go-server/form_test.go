@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// testCSRFKey signs the CSRF tokens of the tests
+const testCSRFKey = "0123456789abcdef0123456789abcdef"
+
+// newTestApp returns an app with a jsonl store and an upload directory of its own
+func newTestApp(t *testing.T) *app {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	store, err := openJSONLStore(filepath.Join(dir, "submissions.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	pages, err := loadPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &app{
+		store:         store,
+		pages:         pages,
+		csrf:          &csrf{key: []byte(testCSRFKey)},
+		uploads:       &uploads{dir: dir},
+		maxUploadSize: 1 << 20,
+	}
+}
+
+// csrfTokenField finds the CSRF token in the hidden field of the form page
+var csrfTokenField = regexp.MustCompile(`name = "` + csrfField + `" value = "([0-9a-f]+)"`)
+
+// fetchForm gets the form page and returns the CSRF cookie and token it hands out
+func fetchForm(t *testing.T, a *app) (*http.Cookie, string) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	a.formHandler(w, httptest.NewRequest(http.MethodGet, "/form", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /form = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrfCookie {
+		t.Fatalf("GET /form sets the cookies %v, want %s", cookies, csrfCookie)
+	}
+
+	match := csrfTokenField.FindStringSubmatch(w.Body.String())
+	if match == nil {
+		t.Fatalf("GET /form has no %s field", csrfField)
+	}
+
+	return cookies[0], match[1]
+}
+
+// postForm sends the values urlencoded with the cookie, when it is set
+func postForm(a *app, values url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", mediaForm)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+
+	w := httptest.NewRecorder()
+	a.formHandler(w, r)
+
+	return w
+}
+
+func TestFormCSRF(t *testing.T) {
+	a := newTestApp(t)
+	cookie, token := fetchForm(t, a)
+	otherCookie, otherToken := fetchForm(t, a)
+
+	// Another key computes other tokens from the same cookie
+	forged := (&csrf{key: []byte(strings.Repeat("x", minCSRFKeyLength))}).sign(cookie.Value)
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		token  string
+		status int
+	}{
+		{name: "valid", cookie: cookie, token: token, status: http.StatusCreated},
+		{name: "valid again", cookie: cookie, token: token, status: http.StatusCreated},
+		{name: "no cookie", token: token, status: http.StatusForbidden},
+		{name: "no token", cookie: cookie, status: http.StatusForbidden},
+		{name: "token of another cookie", cookie: cookie, token: otherToken, status: http.StatusForbidden},
+		{name: "cookie of another token", cookie: otherCookie, token: token, status: http.StatusForbidden},
+		{name: "forged token", cookie: cookie, token: forged, status: http.StatusForbidden},
+		{name: "not hex", cookie: cookie, token: "not-a-token", status: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values := url.Values{"name": {"Ann"}, "address": {"1 Main St"}}
+			if test.token != "" {
+				values.Set(csrfField, test.token)
+			}
+
+			if w := postForm(a, values, test.cookie); w.Code != test.status {
+				t.Errorf("POST /form = %d, want %d", w.Code, test.status)
+			}
+		})
+	}
+}
+
+func TestFormCSRFCookie(t *testing.T) {
+	a := newTestApp(t)
+
+	// A client that already has a cookie keeps it, and gets the same token
+	cookie, token := fetchForm(t, a)
+
+	r := httptest.NewRequest(http.MethodGet, "/form", nil)
+	r.AddCookie(cookie)
+
+	w := httptest.NewRecorder()
+	a.formHandler(w, r)
+
+	if cookies := w.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("GET /form with a cookie sets %v, want no cookie", cookies)
+	}
+
+	if !strings.Contains(w.Body.String(), token) {
+		t.Errorf("GET /form with a cookie does not render the token %s", token)
+	}
+
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("cookie = %+v, want HttpOnly and SameSite=Strict", cookie)
+	}
+}
+
+func TestFormValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		form    submissionForm
+		errs    []string
+		trimmed submissionForm
+	}{
+		{name: "valid", form: submissionForm{Name: "  Ann ", Address: "1 Main St\t"}, trimmed: submissionForm{Name: "Ann", Address: "1 Main St"}},
+		{name: "empty", form: submissionForm{Name: " ", Address: ""}, errs: []string{"address", "name"}},
+		{name: "longest", form: submissionForm{Name: strings.Repeat("é", maxNameLength), Address: "1 Main St"}, trimmed: submissionForm{Name: strings.Repeat("é", maxNameLength), Address: "1 Main St"}},
+		{name: "too long", form: submissionForm{Name: strings.Repeat("é", maxNameLength+1), Address: "1 Main St"}, errs: []string{"name"}},
+		{name: "control character", form: submissionForm{Name: "Ann", Address: "1 Main\x00St"}, errs: []string{"address"}},
+		{name: "invalid utf-8", form: submissionForm{Name: "Ann\xff", Address: "1 Main St"}, errs: []string{"name"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := test.form
+			errs := form.validate()
+
+			if len(errs) != len(test.errs) {
+				t.Fatalf("validate(%+v) = %v, want errors for %v", test.form, errs, test.errs)
+			}
+
+			for _, field := range test.errs {
+				if errs[field] == "" {
+					t.Errorf("validate(%+v) = %v, want an error for %s", test.form, errs, field)
+				}
+			}
+
+			if test.errs == nil && form != test.trimmed {
+				t.Errorf("validate(%+v) left %+v, want %+v", test.form, form, test.trimmed)
+			}
+		})
+	}
+}
+
+func TestFormInvalidRendersValues(t *testing.T) {
+	a := newTestApp(t)
+	cookie, token := fetchForm(t, a)
+
+	// What was typed is shown again, escaped
+	values := url.Values{csrfField: {token}, "name": {"<script>alert(1)</script>"}, "address": {""}}
+
+	w := postForm(a, values, cookie)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("POST /form = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+
+	body := w.Body.String()
+	if strings.Contains(body, "<script>alert(1)</script>") || !strings.Contains(body, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Errorf("POST /form does not escape the name:\n%s", body)
+	}
+
+	if !strings.Contains(body, "Address is required") {
+		t.Errorf("POST /form does not show the error of the address:\n%s", body)
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/rmarasigan/freecodecamp/go-server
 
 go 1.17
+
+require github.com/mattn/go-sqlite3 v1.14.0
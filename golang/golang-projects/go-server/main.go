@@ -1,30 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
-// Response is what the server sends beck to the user
-// Requests is something that the user sends to the server
-func formHandler(w http.ResponseWriter, r *http.Request) {
-	// User will submit something and there will be a POST
-	// request and then that will parse the form
-	if err := r.ParseForm(); err != nil {
-		// write to w
-		fmt.Fprintf(w, "ParseForm() error: %v", err)
-		return
-	}
-	fmt.Fprintf(w, "POST request successful")
-
-	// Getting values coming from form.html
-	name := r.FormValue("name")
-	address := r.FormValue("address")
-
-	// write to w
-	fmt.Fprintf(w, "Name = %s\n", name)
-	fmt.Fprintf(w, "Address = %s\n", address)
+// app holds what the handlers share
+type app struct {
+	store submissionStore
+	pages pages
+	csrf  *csrf
+	// uploads saves the files attached to the forms
+	uploads *uploads
+	// maxUploadSize bounds the size of a multipart form, attachments included
+	maxUploadSize int64
 }
 
 // Response is what the server sends beck to the user
@@ -46,19 +40,118 @@ func helloHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
-	// Checking out the static directory
-	fileServer := http.FileServer(http.Dir("./static"))
+	cfg, err := loadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := openStore(cfg.Store, cfg.StorePath)
+	if err != nil {
+		log.Fatalf("Error could not open the %s store: %v", cfg.Store, err)
+	}
+
+	// The store is closed whatever happens next, so the submissions it buffers are not lost
+	err = run(cfg, store)
+
+	if closeErr := store.Close(); closeErr != nil {
+		log.Printf("Error could not close the store: %v", closeErr)
+	}
+
+	if err != nil {
+		log.Printf("Error %v", err)
+		os.Exit(1)
+	}
+
+	log.Printf("Server stopped\n")
+}
+
+// run serves the requests with the store until the server fails or the process is interrupted
+func run(cfg *config, store submissionStore) error {
+	pageTemplates, err := loadPages()
+	if err != nil {
+		return fmt.Errorf("could not parse the templates: %v", err)
+	}
+
+	csrfKey := []byte(cfg.CSRFKey)
+	if len(csrfKey) == 0 {
+		// The tokens of a random key are only valid on this instance and until it restarts
+		log.Printf("No CSRF key given, using a random one: set %sCSRF_KEY when running several instances\n", envPrefix)
+		csrfKey = []byte(randomID())
+	}
+
+	if err := os.MkdirAll(cfg.UploadDir, 0750); err != nil {
+		return fmt.Errorf("could not create the upload directory: %v", err)
+	}
+
+	a := &app{
+		store:         store,
+		pages:         pageTemplates,
+		csrf:          &csrf{key: csrfKey},
+		uploads:       &uploads{dir: cfg.UploadDir},
+		maxUploadSize: cfg.MaxUploadSize,
+	}
+
+	// Checking out the static directory, or the copy built into the binary
+	staticFS, staticSource := os.DirFS(cfg.StaticDir), cfg.StaticDir
+	if cfg.StaticEmbed {
+		staticFS, staticSource = embeddedStatic(), "the embedded static files"
+	}
+
+	mux := http.NewServeMux()
 	// Handling root route which is the `/`
-	http.Handle("/", fileServer)
-	// Handles /form and  will show the form.html
-	http.HandleFunc("/form", formHandler)
+	mux.Handle("/", newStaticHandler(staticFS, cfg.SPA))
+	// Handles /form and  will show the form, or save it when it is submitted
+	mux.HandleFunc("/form", a.formHandler)
+	// The form used to be a static page, it needs a CSRF token now
+	mux.Handle("/form.html", http.RedirectHandler("/form", http.StatusMovedPermanently))
+	// Handles the JSON API of the submissions, which tells what every user typed so it needs a token
+	if cfg.APIToken != "" {
+		auth := requireToken(cfg.APIToken)
+		mux.Handle("/api/submissions", auth(http.HandlerFunc(a.submissionsHandler)))
+		mux.Handle("/api/submissions/", auth(http.HandlerFunc(a.submissionHandler)))
+	}
 	// Handles /hello and will show the index.html
-	http.HandleFunc("/hello", helloHandler)
+	mux.HandleFunc("/hello", helloHandler)
+
+	// Every request gets an ID and an access log line, and a panic answers 500 instead of dropping the connection
+	handler := chain(mux, requestID, accessLog(os.Stdout), recoverPanic)
 
-	fmt.Printf("Starting server at port 8080\n")
+	// The timeouts keep slow or idle clients from holding the connections forever
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Starting server at %s, serving %s\n", cfg.Addr, staticSource)
 
 	// This will create the server
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatal(err)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
 	}
+
+	log.Printf("Shutting down, waiting up to %s for the requests in flight\n", cfg.ShutdownTimeout)
+
+	// Stops accepting connections and waits for the requests in flight to complete
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("could not drain the requests in flight: %v", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// requestIDHeader carries the ID of a request from the client or the load balancer, and back in the response
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the IDs accepted from the clients, longer ones are replaced
+const maxRequestIDLength = 128
+
+// middleware wraps a handler with a behavior shared by every route
+type middleware func(http.Handler) http.Handler
+
+// chain wraps the handler with the middlewares, the first one runs first
+func chain(handler http.Handler, middlewares ...middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// contextKey is the type of the values the middlewares store in the request context
+type contextKey string
+
+// requestIDKey is the context key of the request ID
+const requestIDKey contextKey = "request-id"
+
+// requestIDFrom returns the ID of the request, empty outside of the requestID middleware
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// requestID keeps the X-Request-ID of the request, or assigns a new one, and sends it back in the response
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = randomID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// validRequestID tells if the ID sent by the client can be used as is, so that it cannot inject anything in the logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// randomID returns 16 random bytes as hex, used for the request and submission IDs
+func randomID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		// The time is unique enough when the system runs out of randomness
+		return hex.EncodeToString([]byte(time.Now().UTC().Format(time.RFC3339Nano)))
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// accessLogEntry is a line of the access log
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	RequestID  string  `json:"request_id"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMS float64 `json:"duration_ms"`
+	RemoteAddr string  `json:"remote_addr"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+}
+
+// accessLog writes a JSON line to w for every request once it is answered
+func accessLog(w io.Writer) middleware {
+	var mu sync.Mutex
+	encoder := json.NewEncoder(w)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(recorder, r)
+
+			entry := accessLogEntry{
+				Time:       start.UTC().Format(time.RFC3339Nano),
+				RequestID:  requestIDFrom(r.Context()),
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Status:     recorder.Status(),
+				Bytes:      recorder.bytes,
+				DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+				RemoteAddr: r.RemoteAddr,
+				UserAgent:  r.UserAgent(),
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err := encoder.Encode(entry); err != nil {
+				log.Printf("Error could not write the access log: %v", err)
+			}
+		})
+	}
+}
+
+// recoverPanic answers 500 when the handler panics, and logs the panic with its stack
+func recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+
+			// The server aborts the response on purpose with this one, it is not a bug
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			log.Printf("Error panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, requestIDFrom(r.Context()), err, debug.Stack())
+
+			// Once the status is sent, the client can only tell something went wrong from the cut response
+			if !recorder.wroteHeader {
+				http.Error(recorder, "500 internal server error", http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// statusRecorder remembers the status and the size of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// WriteHeader records the status before sending it
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the size of the body, a write without a status sends 200
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	if !s.wroteHeader {
+		s.WriteHeader(http.StatusOK)
+	}
+
+	n, err := s.ResponseWriter.Write(data)
+	s.bytes += int64(n)
+
+	return n, err
+}
+
+// Status returns the status of the response, 200 when the handler wrote nothing
+func (s *statusRecorder) Status() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+
+	return s.status
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
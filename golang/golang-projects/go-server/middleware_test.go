@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureLog sends the log to a buffer until the end of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	return &buf
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		kept bool
+	}{
+		{name: "valid", id: "d85f9df86a001328495005efb762c4d0", kept: true},
+		{name: "longest", id: strings.Repeat("a", maxRequestIDLength), kept: true},
+		{name: "punctuation", id: "lb-1/2024:abc_DEF.42", kept: true},
+		{name: "missing", id: ""},
+		{name: "too long", id: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "space", id: "abc def"},
+		{name: "newline", id: "abc\n{\"status\":200}"},
+		{name: "not ascii", id: "abcé"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var seen string
+			handler := requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = requestIDFrom(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.id != "" {
+				r.Header.Set(requestIDHeader, test.id)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if sent := w.Header().Get(requestIDHeader); sent != seen {
+				t.Errorf("the response has the ID %q, the handler %q", sent, seen)
+			}
+
+			if test.kept && seen != test.id {
+				t.Errorf("requestID(%q) = %q, want it kept", test.id, seen)
+			}
+
+			if !test.kept && (seen == test.id || !validRequestID(seen) || len(seen) != 32) {
+				t.Errorf("requestID(%q) = %q, want a new random ID", test.id, seen)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+		bytes   int64
+	}{
+		{
+			name:    "implicit 200",
+			handler: func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "hello!") },
+			status:  http.StatusOK,
+			bytes:   6,
+		},
+		{
+			name:    "no body",
+			handler: func(w http.ResponseWriter, r *http.Request) {},
+			status:  http.StatusOK,
+		},
+		{
+			name: "status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, "created")
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			status: http.StatusCreated,
+			bytes:  7,
+		},
+		{
+			name:    "error",
+			handler: func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) },
+			status:  http.StatusNotFound,
+			bytes:   int64(len("404 page not found\n")),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var logged bytes.Buffer
+			handler := chain(test.handler, requestID, accessLog(&logged))
+
+			r := httptest.NewRequest(http.MethodPost, "/form?page=2", nil)
+			r.Header.Set(requestIDHeader, "abc123")
+			r.Header.Set("User-Agent", "curl/8.0")
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			var entry accessLogEntry
+			if err := json.Unmarshal(logged.Bytes(), &entry); err != nil {
+				t.Fatalf("access log %q: %v", logged.String(), err)
+			}
+
+			if entry.Status != test.status || entry.Bytes != test.bytes {
+				t.Errorf("access log = status %d, %d bytes, want %d, %d", entry.Status, entry.Bytes, test.status, test.bytes)
+			}
+
+			if entry.RequestID != "abc123" || entry.Method != http.MethodPost || entry.Path != "/form" || entry.UserAgent != "curl/8.0" || entry.RemoteAddr == "" {
+				t.Errorf("access log = %+v", entry)
+			}
+
+			if strings.Count(logged.String(), "\n") != 1 {
+				t.Errorf("access log = %q, want a single line", logged.String())
+			}
+		})
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+		body    string
+	}{
+		{
+			name:    "before the header",
+			handler: func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			status:  http.StatusInternalServerError,
+			body:    "500 internal server error\n",
+		},
+		{
+			// The status is already sent, only the response is cut short
+			name: "after the header",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "partial")
+				panic("boom")
+			},
+			status: http.StatusOK,
+			body:   "partial",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logged := captureLog(t)
+
+			w := httptest.NewRecorder()
+			recoverPanic(test.handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+			if w.Code != test.status || w.Body.String() != test.body {
+				t.Errorf("panic = %d %q, want %d %q", w.Code, w.Body, test.status, test.body)
+			}
+
+			if !strings.Contains(logged.String(), "Error panic serving GET /boom") || !strings.Contains(logged.String(), "boom") {
+				t.Errorf("log = %q, want the panic and its stack", logged)
+			}
+		})
+	}
+}
+
+func TestRecoverPanicAbortHandler(t *testing.T) {
+	logged := captureLog(t)
+
+	handler := recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	w := httptest.NewRecorder()
+
+	// The server needs the panic to abort the response, it must go through
+	func() {
+		defer func() {
+			if err := recover(); err != http.ErrAbortHandler {
+				t.Errorf("recover() = %v, want http.ErrAbortHandler", err)
+			}
+		}()
+
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	if w.Code == http.StatusInternalServerError || w.Body.Len() != 0 {
+		t.Errorf("http.ErrAbortHandler = %d %q, want no response", w.Code, w.Body)
+	}
+
+	if logged.Len() != 0 {
+		t.Errorf("http.ErrAbortHandler logged %q, want nothing", logged)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media types the form endpoint reads and answers with
+const (
+	mediaJSON      = "application/json"
+	mediaHTML      = "text/html"
+	mediaForm      = "application/x-www-form-urlencoded"
+	mediaMultipart = "multipart/form-data"
+)
+
+// requestMediaType returns the media type of the body, without its parameters, empty when it is missing or invalid
+func requestMediaType(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+
+	return mediaType
+}
+
+// wantsJSON tells if the client prefers a JSON response to an HTML one according to
+// its Accept header. Without a preference, JSON requests get JSON and the others HTML.
+func wantsJSON(r *http.Request) bool {
+	jsonQuality := acceptQuality(r.Header.Values("Accept"), mediaJSON)
+	htmlQuality := acceptQuality(r.Header.Values("Accept"), mediaHTML)
+
+	if jsonQuality == htmlQuality {
+		return requestMediaType(r) == mediaJSON
+	}
+
+	return jsonQuality > htmlQuality
+}
+
+// acceptQuality returns the quality the Accept headers give to the media type, from the
+// most specific range that matches it. No header accepts everything with the quality 1.
+func acceptQuality(headers []string, mediaType string) float64 {
+	if len(headers) == 0 {
+		return 1
+	}
+
+	mainType := strings.SplitN(mediaType, "/", 2)[0]
+	quality, specificity := 0.0, -1
+
+	for _, header := range headers {
+		for _, accepted := range strings.Split(header, ",") {
+			params := strings.Split(accepted, ";")
+			accepted := strings.ToLower(strings.TrimSpace(params[0]))
+
+			var matched int
+			switch accepted {
+			case mediaType:
+				matched = 2
+			case mainType + "/*":
+				matched = 1
+			case "*/*":
+				matched = 0
+			default:
+				continue
+			}
+
+			if matched < specificity {
+				continue
+			}
+
+			specificity, quality = matched, parseQuality(params[1:])
+		}
+	}
+
+	return quality
+}
+
+// acceptsEncoding tells if the Accept-Encoding headers allow the content coding, e.g. gzip.
+// The coding must be listed or matched by *, with a quality above 0.
+func acceptsEncoding(headers []string, coding string) bool {
+	quality, wildcard := -1.0, -1.0
+
+	for _, header := range headers {
+		for _, accepted := range strings.Split(header, ",") {
+			params := strings.Split(accepted, ";")
+
+			switch strings.ToLower(strings.TrimSpace(params[0])) {
+			case coding:
+				quality = parseQuality(params[1:])
+			case "*":
+				wildcard = parseQuality(params[1:])
+			}
+		}
+	}
+
+	// The coding itself wins over *, e.g. "*, br;q=0" refuses br
+	if quality >= 0 {
+		return quality > 0
+	}
+
+	return wildcard > 0
+}
+
+// parseQuality returns the q parameter among the parameters of an Accept value, 1 when it is missing or invalid
+func parseQuality(params []string) float64 {
+	quality := 1.0
+
+	for _, param := range params {
+		if value := strings.TrimSpace(param); strings.HasPrefix(value, "q=") {
+			if q, err := strconv.ParseFloat(value[2:], 64); err == nil {
+				quality = q
+			}
+		}
+	}
+
+	return quality
+}
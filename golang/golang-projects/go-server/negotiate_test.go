@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWantsJSON(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+		json        bool
+	}{
+		{accept: "", contentType: mediaForm, json: false},
+		{accept: "", contentType: mediaJSON, json: true},
+		{accept: "*/*", contentType: mediaJSON + "; charset=utf-8", json: true},
+		{accept: "application/json", contentType: mediaForm, json: true},
+		{accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", contentType: mediaForm, json: false},
+		{accept: "text/html;q=0.5, application/json", contentType: mediaForm, json: true},
+		{accept: "application/json;q=0.5, text/*", contentType: mediaJSON, json: false},
+		{accept: "*/*;q=0.1, application/json;q=0", contentType: mediaJSON, json: false},
+		{accept: "image/png", contentType: mediaJSON, json: true},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/form", nil)
+		r.Header.Set("Content-Type", test.contentType)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+
+		if json := wantsJSON(r); json != test.json {
+			t.Errorf("wantsJSON(Accept %q, Content-Type %q) = %v, want %v", test.accept, test.contentType, json, test.json)
+		}
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		headers []string
+		coding  string
+		accepts bool
+	}{
+		{headers: nil, coding: "gzip", accepts: false},
+		{headers: []string{"gzip, deflate, br"}, coding: "br", accepts: true},
+		{headers: []string{"gzip", "br"}, coding: "br", accepts: true},
+		{headers: []string{"GZIP;q=0.5"}, coding: "gzip", accepts: true},
+		{headers: []string{"gzip;q=0"}, coding: "gzip", accepts: false},
+		{headers: []string{"*"}, coding: "br", accepts: true},
+		{headers: []string{"*, br;q=0"}, coding: "br", accepts: false},
+		{headers: []string{"identity"}, coding: "gzip", accepts: false},
+	}
+
+	for _, test := range tests {
+		if accepts := acceptsEncoding(test.headers, test.coding); accepts != test.accepts {
+			t.Errorf("acceptsEncoding(%q, %s) = %v, want %v", test.headers, test.coding, accepts, test.accepts)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"crypto/sha256"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// staticFiles are the files of static/ built into the binary, served with --static-embed
+//
+//go:embed static
+var staticFiles embed.FS
+
+// indexFile is served for a directory, and for the unknown pages of a single page application
+const indexFile = "index.html"
+
+// cacheControls are the Cache-Control headers of the static files by extension. The pages are
+// checked again on every request so that a deploy shows up at once, the assets are kept longer.
+var cacheControls = map[string]string{
+	".html":  "no-cache",
+	".css":   "public, max-age=86400",
+	".js":    "public, max-age=86400",
+	".json":  "public, max-age=3600",
+	".png":   "public, max-age=604800",
+	".jpg":   "public, max-age=604800",
+	".jpeg":  "public, max-age=604800",
+	".gif":   "public, max-age=604800",
+	".svg":   "public, max-age=604800",
+	".webp":  "public, max-age=604800",
+	".ico":   "public, max-age=604800",
+	".woff":  "public, max-age=2592000",
+	".woff2": "public, max-age=2592000",
+	".ttf":   "public, max-age=2592000",
+}
+
+// defaultCacheControl is the Cache-Control header of the extensions missing from cacheControls
+const defaultCacheControl = "public, max-age=3600"
+
+// immutableCacheControl is the Cache-Control header of the fingerprinted files, a new version
+// of the file gets a new name so the browsers can keep it for a year without checking it again
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// fingerprint finds the hash of the content the bundlers put before the extension of
+// a name, e.g. app.3f2a9c1b.js or app-3f2a9c1b.css
+var fingerprint = regexp.MustCompile(`[.-]([0-9a-f]+)\.[^./]+$`)
+
+// fingerprintLengths are the lengths of the hashes written by the bundlers, in hex digits,
+// which keeps a number such as the date of report-20261018.pdf from passing for a hash
+var fingerprintLengths = map[int]bool{8: true, 10: true, 12: true, 16: true, 20: true, 32: true, 40: true, 64: true}
+
+// precompressed are the compressed variants looked for next to a file, the preferred first
+var precompressed = []struct {
+	encoding  string
+	extension string
+}{
+	{encoding: "br", extension: ".br"},
+	{encoding: "gzip", extension: ".gz"},
+}
+
+// staticHandler serves the files of a directory or of the embedded files. Unlike
+// http.FileServer it never lists a directory, sets the cache headers of every file
+// and serves the .br and .gz files next to a file to the clients that accept them.
+type staticHandler struct {
+	files fs.FS
+	// spa serves index.html for the unknown paths without extension, which are the routes of the application
+	spa bool
+	// hashes caches the ETags of the files without modification time, which are the embedded ones and never change
+	hashes sync.Map
+}
+
+// newStaticHandler serves the files, falling back to index.html for the unknown pages when spa is set
+func newStaticHandler(files fs.FS, spa bool) *staticHandler {
+	return &staticHandler{files: files, spa: spa}
+}
+
+// embeddedStatic returns the embedded static/ directory
+func embeddedStatic() fs.FS {
+	files, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		// The directory is embedded at build time, it cannot be missing
+		panic(err)
+	}
+
+	return files
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method is not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Cleaning an absolute path removes every .., so the name cannot leave the files
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+
+	info, err := fs.Stat(h.files, name)
+	if err == nil && info.IsDir() {
+		// The relative links of the index need the trailing slash
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			target := path.Base(r.URL.Path) + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+
+		// A directory is only served through its index, its files are never listed
+		name = path.Join(name, indexFile)
+		info, err = fs.Stat(h.files, name)
+	}
+
+	if err == nil && info.IsDir() {
+		err = fs.ErrNotExist
+	}
+
+	// A missing asset, e.g. /app.js, stays a 404 instead of getting the page
+	if errors.Is(err, fs.ErrNotExist) && h.spa && path.Ext(name) == "" {
+		name = indexFile
+		info, err = fs.Stat(h.files, name)
+	}
+
+	if err != nil {
+		staticError(w, r, err)
+		return
+	}
+
+	h.serveFile(w, r, name, info)
+}
+
+// serveFile writes the file, or its compressed variant, with its cache headers.
+// http.ServeContent answers the conditional and range requests.
+func (h *staticHandler) serveFile(w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo) {
+	header := w.Header()
+	header.Set("Cache-Control", cacheControl(name))
+
+	served, encoding := name, ""
+
+	// The type of a compressed file cannot be sniffed, so only the known types have variants
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		header.Set("Content-Type", contentType)
+
+		for _, variant := range precompressed {
+			variantInfo, err := fs.Stat(h.files, name+variant.extension)
+			if err != nil || !variantInfo.Mode().IsRegular() {
+				continue
+			}
+
+			// The response depends on the header as soon as there is a variant, even when it is not sent
+			if header.Get("Vary") == "" {
+				header.Set("Vary", "Accept-Encoding")
+			}
+
+			if encoding == "" && acceptsEncoding(r.Header.Values("Accept-Encoding"), variant.encoding) {
+				served, encoding, info = name+variant.extension, variant.encoding, variantInfo
+			}
+		}
+	}
+
+	file, err := h.files.Open(served)
+	if err != nil {
+		staticError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		staticError(w, r, fmt.Errorf("%s cannot seek", served))
+		return
+	}
+
+	etag, err := h.etag(served, info, content)
+	if err != nil {
+		staticError(w, r, err)
+		return
+	}
+
+	header.Set("ETag", etag)
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// etag identifies the version of the file by its modification time and size, or by a hash of
+// its content when it has no modification time. Every variant of a file gets its own ETag.
+func (h *staticHandler) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()), nil
+	}
+
+	if etag, ok := h.hashes.Load(name); ok {
+		return etag.(string), nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := fmt.Sprintf(`"%x"`, hash.Sum(nil)[:16])
+	h.hashes.Store(name, etag)
+
+	return etag, nil
+}
+
+// cacheControl returns the Cache-Control header of the file. The pages are never fingerprinted,
+// their links must keep working.
+func cacheControl(name string) string {
+	if path.Ext(name) != ".html" && fingerprinted(path.Base(name)) {
+		return immutableCacheControl
+	}
+
+	if value, ok := cacheControls[strings.ToLower(path.Ext(name))]; ok {
+		return value
+	}
+
+	return defaultCacheControl
+}
+
+// fingerprinted tells if the name holds a hash of the content. A hash has a length the bundlers
+// use and at least one letter, so that a date or a counter is not taken for one.
+func fingerprinted(name string) bool {
+	match := fingerprint.FindStringSubmatch(name)
+
+	return match != nil && fingerprintLengths[len(match[1])] && strings.IndexAny(match[1], "abcdef") >= 0
+}
+
+// staticError answers 404 for a missing file, 403 for a forbidden one, and 500 for the other errors
+func staticError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.NotFound(w, r)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+	default:
+		log.Printf("Error could not serve %s (request %s): %v", r.URL.Path, requestIDFrom(r.Context()), err)
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// testStatic are the files served by the static tests
+var testStatic = fstest.MapFS{
+	"index.html":             {Data: []byte("<h1>home</h1>")},
+	"docs/index.html":        {Data: []byte("<h1>docs</h1>")},
+	"empty/.keep":            {Data: []byte{}},
+	"app.css":                {Data: []byte("body { color: black }"), ModTime: time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)},
+	"app.css.gz":             {Data: []byte("gzip of app.css")},
+	"app.css.br":             {Data: []byte("br of app.css")},
+	"assets/app.3f2a9c1b.js": {Data: []byte("console.log(1)")},
+	"notes.txt":              {Data: []byte("notes")},
+}
+
+func TestCacheControl(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "index.html", value: "no-cache"},
+		{name: "app.css", value: "public, max-age=86400"},
+		{name: "APP.JS", value: "public, max-age=86400"},
+		{name: "logo.png", value: "public, max-age=604800"},
+		{name: "fonts/inter.woff2", value: "public, max-age=2592000"},
+		{name: "notes.txt", value: defaultCacheControl},
+		{name: "assets/app.3f2a9c1b.js", value: immutableCacheControl},
+		{name: "assets/app-3f2a9c1b5d7e.css", value: immutableCacheControl},
+		{name: "assets/vendor.0123456789abcdef0123.js", value: immutableCacheControl},
+		{name: "fonts/inter.0123abcd.woff2", value: immutableCacheControl},
+		// A date or a number is not a hash, such a file may well be replaced in place
+		{name: "report-20261018.pdf", value: defaultCacheControl},
+		{name: "photo-12345678.jpg", value: "public, max-age=604800"},
+		{name: "changelog.20261018.txt", value: defaultCacheControl},
+		// Not the length of a hash, or not hex
+		{name: "assets/app.3f2a9c.js", value: "public, max-age=86400"},
+		{name: "assets/app.3f2a9c1bd.js", value: "public, max-age=86400"},
+		{name: "assets/app.deadbeefs.js", value: "public, max-age=86400"},
+		{name: "assets/app-3F2A9C1B.js", value: "public, max-age=86400"},
+		{name: "assets/bootstrap-5.min.css", value: "public, max-age=86400"},
+		// A hash in the name of a directory does not make the files fingerprinted
+		{name: "v-0123abcd.3/app.js", value: "public, max-age=86400"},
+		{name: "page.0123abcd.html", value: "no-cache"},
+	}
+
+	for _, test := range tests {
+		if value := cacheControl(test.name); value != test.value {
+			t.Errorf("cacheControl(%s) = %q, want %q", test.name, value, test.value)
+		}
+	}
+}
+
+func TestStaticHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		spa      bool
+		status   int
+		body     string
+		location string
+		cache    string
+	}{
+		{name: "root", path: "/", status: http.StatusOK, body: "<h1>home</h1>", cache: "no-cache"},
+		{name: "file", path: "/app.css", status: http.StatusOK, body: "body { color: black }", cache: "public, max-age=86400"},
+		{name: "fingerprinted", path: "/assets/app.3f2a9c1b.js", status: http.StatusOK, body: "console.log(1)", cache: immutableCacheControl},
+		{name: "head", method: http.MethodHead, path: "/notes.txt", status: http.StatusOK, cache: defaultCacheControl},
+		{name: "directory index", path: "/docs/", status: http.StatusOK, body: "<h1>docs</h1>", cache: "no-cache"},
+		{name: "directory without slash", path: "/docs?page=2", status: http.StatusMovedPermanently, location: "/docs/?page=2"},
+		{name: "directory without index", path: "/empty/", status: http.StatusNotFound},
+		{name: "index file", path: "/index.html", status: http.StatusOK, body: "<h1>home</h1>", cache: "no-cache"},
+		{name: "traversal", path: "/../../etc/passwd", status: http.StatusNotFound},
+		{name: "missing", path: "/settings", status: http.StatusNotFound},
+		{name: "spa route", path: "/app/settings", spa: true, status: http.StatusOK, body: "<h1>home</h1>", cache: "no-cache"},
+		{name: "spa missing asset", path: "/app/main.js", spa: true, status: http.StatusNotFound},
+		{name: "post", method: http.MethodPost, path: "/app.css", status: http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			w := httptest.NewRecorder()
+			newStaticHandler(testStatic, test.spa).ServeHTTP(w, httptest.NewRequest(method, test.path, nil))
+
+			if w.Code != test.status {
+				t.Fatalf("%s %s = %d, want %d", method, test.path, w.Code, test.status)
+			}
+
+			if test.body != "" && w.Body.String() != test.body {
+				t.Errorf("%s %s = %q, want %q", method, test.path, w.Body, test.body)
+			}
+
+			if location := w.Header().Get("Location"); location != test.location {
+				t.Errorf("%s %s redirects to %q, want %q", method, test.path, location, test.location)
+			}
+
+			if cache := w.Header().Get("Cache-Control"); test.cache != "" && cache != test.cache {
+				t.Errorf("%s %s has Cache-Control %q, want %q", method, test.path, cache, test.cache)
+			}
+		})
+	}
+}
+
+func TestStaticPrecompressed(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+		body           string
+	}{
+		{acceptEncoding: "", encoding: "", body: "body { color: black }"},
+		{acceptEncoding: "gzip, deflate", encoding: "gzip", body: "gzip of app.css"},
+		{acceptEncoding: "gzip, deflate, br", encoding: "br", body: "br of app.css"},
+		{acceptEncoding: "*, br;q=0", encoding: "gzip", body: "gzip of app.css"},
+		{acceptEncoding: "identity", encoding: "", body: "body { color: black }"},
+	}
+
+	handler := newStaticHandler(testStatic, false)
+	etags := make(map[string]string)
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/app.css", nil)
+		if test.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		header := w.Header()
+		if header.Get("Content-Encoding") != test.encoding || w.Body.String() != test.body {
+			t.Errorf("Accept-Encoding %q = %q encoded %q, want %q encoded %q", test.acceptEncoding, w.Body, header.Get("Content-Encoding"), test.body, test.encoding)
+		}
+
+		// The type is the one of the original file, and the caches must tell the variants apart
+		if header.Get("Content-Type") != "text/css; charset=utf-8" || header.Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q has Content-Type %q and Vary %q", test.acceptEncoding, header.Get("Content-Type"), header.Get("Vary"))
+		}
+
+		if other, ok := etags[header.Get("ETag")]; ok && other != test.encoding {
+			t.Errorf("the %q and %q variants share the ETag %s", other, test.encoding, header.Get("ETag"))
+		}
+		etags[header.Get("ETag")] = test.encoding
+	}
+}
+
+func TestStaticNotModified(t *testing.T) {
+	handler := newStaticHandler(testStatic, false)
+
+	// The ETag of app.css comes from its modification time, the one of notes.txt from its content
+	for _, path := range []string{"/app.css", "/notes.txt"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("GET %s has no ETag", path)
+		}
+
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("If-None-Match", etag)
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("GET %s with If-None-Match %s = %d with %d bytes, want %d", path, etag, w.Code, w.Body.Len(), http.StatusNotModified)
+		}
+	}
+}
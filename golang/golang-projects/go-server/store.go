@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errNotFound is returned by the stores for an unknown submission ID
+var errNotFound = errors.New("submission not found")
+
+// Submission is a form sent to /form
+type Submission struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	CreatedAt time.Time `json:"created_at"`
+	// Attachments are the files uploaded with the form
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// RequestID ties the submission to the line of the access log
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// submissionStore keeps the submissions. The stores must be safe for concurrent use.
+type submissionStore interface {
+	// Add saves a new submission, setting its ID and CreatedAt
+	Add(ctx context.Context, submission *Submission) error
+	// List returns every submission, the oldest first
+	List(ctx context.Context) ([]Submission, error)
+	// Get returns the submission with the ID, or errNotFound
+	Get(ctx context.Context, id string) (Submission, error)
+	// Delete removes the submission with the ID, or returns errNotFound
+	Delete(ctx context.Context, id string) error
+	Close() error
+}
+
+// storeDrivers open the stores by name, other builds add their own, e.g. sqlite
+var storeDrivers = map[string]func(path string) (submissionStore, error){
+	"jsonl": openJSONLStore,
+}
+
+// defaultStorePaths are the files of the stores when no path is given
+var defaultStorePaths = map[string]string{
+	"jsonl": "submissions.jsonl",
+}
+
+// openStore opens the store of the driver, at its default path when path is empty
+func openStore(driver, path string) (submissionStore, error) {
+	open, ok := storeDrivers[driver]
+	if !ok {
+		names := make([]string, 0, len(storeDrivers))
+		for name := range storeDrivers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("unknown store %q, expected one of %s", driver, strings.Join(names, ", "))
+	}
+
+	if path == "" {
+		path = defaultStorePaths[driver]
+	}
+
+	return open(path)
+}
+
+// jsonlStore keeps the submissions in memory and in a file, one JSON object per line.
+// The new submissions are appended to the file, a deletion rewrites it.
+type jsonlStore struct {
+	mu          sync.Mutex
+	path        string
+	file        *os.File
+	submissions []Submission
+	// size is the length of the complete lines of the file, where the next line is written
+	size int64
+	// partial tells that a failed write may have left part of a line after size
+	partial bool
+	// unterminated tells that the last line of the file has no newline, e.g. it was edited by hand
+	unterminated bool
+}
+
+// openJSONLStore reads the submissions of the file, which is created when it does not exist
+func openJSONLStore(path string) (submissionStore, error) {
+	store := &jsonlStore{path: path}
+
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	store.file = file
+	return store, nil
+}
+
+// load reads the submissions of the file, a missing file has none. A last line that cannot be
+// read is a write cut short, e.g. by a crash or a full disk: it is dropped from the file so that
+// the next submission starts on a line of its own. An unreadable line before it is an error.
+func (s *jsonlStore) load() error {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	// read is the offset after the current line, whatever its line ending, and
+	// s.size the one after the last readable line
+	var read int64
+	var incomplete error
+
+	for line := 1; ; line++ {
+		raw, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if len(raw) == 0 {
+			break
+		}
+
+		read += int64(len(raw))
+		text := bytes.TrimSpace(raw)
+
+		if len(text) == 0 {
+			if incomplete == nil {
+				s.size, s.unterminated = read, false
+			}
+			continue
+		}
+
+		if incomplete != nil {
+			return incomplete
+		}
+
+		var submission Submission
+		if err := json.Unmarshal(text, &submission); err != nil {
+			incomplete = fmt.Errorf("%s line %d: %v", s.path, line, err)
+			continue
+		}
+
+		s.submissions = append(s.submissions, submission)
+		s.size, s.unterminated = read, raw[len(raw)-1] != '\n'
+	}
+
+	if incomplete == nil {
+		return nil
+	}
+
+	log.Printf("Dropping the incomplete last line of the store, %v", incomplete)
+	return os.Truncate(s.path, s.size)
+}
+
+// Add appends the submission to the file
+func (s *jsonlStore) Add(ctx context.Context, submission *Submission) error {
+	submission.ID = randomID()
+	submission.CreatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(submission)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The line must not be appended to what a failed write left, it would make both unreadable
+	if s.partial {
+		if err := s.file.Truncate(s.size); err != nil {
+			return fmt.Errorf("could not remove the line a failed write left: %v", err)
+		}
+
+		s.partial = false
+	}
+
+	line := append(data, '\n')
+	if s.unterminated {
+		line = append([]byte{'\n'}, line...)
+	}
+
+	if _, err := s.file.Write(line); err != nil {
+		s.partial = true
+
+		// The next Add tries again when the file cannot be truncated now
+		if truncateErr := s.file.Truncate(s.size); truncateErr == nil {
+			s.partial = false
+		}
+
+		return err
+	}
+
+	s.size += int64(len(line))
+	s.unterminated = false
+	s.submissions = append(s.submissions, *submission)
+	return nil
+}
+
+// List returns a copy of the submissions
+func (s *jsonlStore) List(ctx context.Context) ([]Submission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Submission{}, s.submissions...), nil
+}
+
+// Get returns the submission with the ID
+func (s *jsonlStore) Get(ctx context.Context, id string) (Submission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.index(id); i >= 0 {
+		return s.submissions[i], nil
+	}
+
+	return Submission{}, errNotFound
+}
+
+// Delete removes the submission and rewrites the file without it
+func (s *jsonlStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return errNotFound
+	}
+
+	submissions := append(append([]Submission{}, s.submissions[:i]...), s.submissions[i+1:]...)
+	if err := s.rewrite(submissions); err != nil {
+		return err
+	}
+
+	s.submissions = submissions
+	return nil
+}
+
+// Close closes the file
+func (s *jsonlStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+// index returns the position of the submission with the ID, or -1
+func (s *jsonlStore) index(id string) int {
+	for i := range s.submissions {
+		if s.submissions[i].ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// rewrite replaces the file with the submissions, through a temporary file so that
+// a crash cannot leave it half written, and reopens it for the next additions
+func (s *jsonlStore) rewrite(submissions []Submission) error {
+	temp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	writer := bufio.NewWriter(temp)
+	encoder := json.NewEncoder(writer)
+
+	for i := range submissions {
+		if err := encoder.Encode(&submissions[i]); err != nil {
+			temp.Close()
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		temp.Close()
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp.Name(), s.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file.Close()
+	s.file = file
+	s.size, s.partial, s.unterminated = info.Size(), false, false
+
+	return nil
+}
@@ -0,0 +1,165 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	// Registers the sqlite3 driver of database/sql, it needs cgo
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// The SQLite store is only built with -tags sqlite, so that the default build needs no C compiler
+func init() {
+	storeDrivers["sqlite"] = openSQLiteStore
+	defaultStorePaths["sqlite"] = "submissions.db"
+}
+
+// sqliteSchema creates the table of the submissions when it does not exist
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS submissions (
+	id          TEXT PRIMARY KEY,
+	name        TEXT NOT NULL,
+	address     TEXT NOT NULL,
+	created_at  TIMESTAMP NOT NULL,
+	request_id  TEXT NOT NULL DEFAULT '',
+	attachments TEXT NOT NULL DEFAULT '[]'
+)`
+
+// sqliteMigrations add the columns the first versions of the table did not have
+var sqliteMigrations = []string{
+	`ALTER TABLE submissions ADD COLUMN attachments TEXT NOT NULL DEFAULT '[]'`,
+}
+
+// sqliteColumns are the columns of a submission, in the order scanSubmission reads them
+const sqliteColumns = "id, name, address, created_at, request_id, attachments"
+
+// sqliteStore keeps the submissions in an SQLite database
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// openSQLiteStore opens the database file, creating it and its table when they do not exist
+func openSQLiteStore(path string) (submissionStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, more connections only wait for each other
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	for _, migration := range sqliteMigrations {
+		// A column that already exists was added by the schema or an earlier start
+		if _, err := db.Exec(migration); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+// Add inserts the submission
+func (s *sqliteStore) Add(ctx context.Context, submission *Submission) error {
+	submission.ID = randomID()
+	submission.CreatedAt = time.Now().UTC()
+
+	attachments, err := json.Marshal(submission.Attachments)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, "INSERT INTO submissions (id, name, address, created_at, request_id, attachments) VALUES (?, ?, ?, ?, ?, ?)",
+		submission.ID, submission.Name, submission.Address, submission.CreatedAt, submission.RequestID, string(attachments))
+
+	return err
+}
+
+// List returns every submission, the oldest first
+func (s *sqliteStore) List(ctx context.Context) ([]Submission, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+sqliteColumns+" FROM submissions ORDER BY created_at, rowid")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	submissions := []Submission{}
+
+	for rows.Next() {
+		submission, err := scanSubmission(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		submissions = append(submissions, submission)
+	}
+
+	return submissions, rows.Err()
+}
+
+// Get returns the submission with the ID
+func (s *sqliteStore) Get(ctx context.Context, id string) (Submission, error) {
+	submission, err := scanSubmission(s.db.QueryRowContext(ctx, "SELECT "+sqliteColumns+" FROM submissions WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return Submission{}, errNotFound
+	}
+
+	return submission, err
+}
+
+// Delete removes the submission with the ID
+func (s *sqliteStore) Delete(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM submissions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return errNotFound
+	}
+
+	return nil
+}
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSubmission reads a row of the sqliteColumns
+func scanSubmission(row rowScanner) (Submission, error) {
+	var submission Submission
+	var attachments string
+
+	err := row.Scan(&submission.ID, &submission.Name, &submission.Address, &submission.CreatedAt, &submission.RequestID, &attachments)
+	if err != nil {
+		return Submission{}, err
+	}
+
+	if err := json.Unmarshal([]byte(attachments), &submission.Attachments); err != nil {
+		return Submission{}, fmt.Errorf("invalid attachments of the submission %s: %v", submission.ID, err)
+	}
+
+	return submission, nil
+}
+
+// Close closes the database
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
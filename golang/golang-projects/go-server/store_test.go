@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpenJSONLStore(t *testing.T) {
+	const (
+		ann = `{"id":"1","name":"Ann","address":"1 Main St","created_at":"2026-10-18T08:00:00Z"}` + "\n"
+		bob = `{"id":"2","name":"Bob","address":"2 Main St","created_at":"2026-10-18T08:01:00Z"}` + "\n"
+	)
+
+	tests := []struct {
+		name    string
+		content string
+		ids     []string
+		// kept is what is left of the file once it is opened
+		kept string
+		err  bool
+	}{
+		{name: "missing"},
+		{name: "complete", content: ann + "\n" + bob, ids: []string{"1", "2"}, kept: ann + "\n" + bob},
+		{name: "cut short", content: ann + bob[:20], ids: []string{"1"}, kept: ann},
+		{name: "cut short after a blank line", content: ann + "\n" + bob[:20], ids: []string{"1"}, kept: ann + "\n"},
+		{name: "only line cut short", content: bob[:20], kept: ""},
+		{name: "invalid last line", content: ann + "{}}\n\n", ids: []string{"1"}, kept: ann},
+		{name: "invalid line", content: ann + bob[:20] + "\n" + bob, err: true},
+		// The offsets count the whole line ending
+		{name: "crlf", content: crlf(ann) + crlf(bob), ids: []string{"1", "2"}, kept: crlf(ann) + crlf(bob)},
+		{name: "crlf cut short", content: crlf(ann) + "\r\n" + bob[:20], ids: []string{"1"}, kept: crlf(ann) + "\r\n"},
+		{name: "last line without newline", content: ann + strings.TrimSuffix(bob, "\n"), ids: []string{"1", "2"}, kept: ann + strings.TrimSuffix(bob, "\n")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "submissions.jsonl")
+			if test.content != "" {
+				if err := os.WriteFile(path, []byte(test.content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			store, err := openJSONLStore(path)
+			if test.err {
+				if err == nil {
+					store.Close()
+					t.Fatalf("openJSONLStore() of %q = nil, want an error", test.content)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("openJSONLStore() of %q = %v", test.content, err)
+			}
+			defer store.Close()
+
+			submissions, _ := store.List(context.Background())
+
+			var ids []string
+			for _, submission := range submissions {
+				ids = append(ids, submission.ID)
+			}
+
+			if !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("List() = %v, want %v", ids, test.ids)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(content) != test.kept {
+				t.Errorf("file = %q, want %q", content, test.kept)
+			}
+		})
+	}
+}
+
+// crlf replaces the newline ending the line with CRLF
+func crlf(line string) string {
+	return strings.TrimSuffix(line, "\n") + "\r\n"
+}
+
+// submissionNames returns the names of the submissions of the store
+func submissionNames(t *testing.T, store submissionStore) []string {
+	t.Helper()
+
+	submissions, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, submission := range submissions {
+		names = append(names, submission.Name)
+	}
+
+	return names
+}
+
+func TestJSONLStoreFailedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "submissions.jsonl")
+
+	opened, err := openJSONLStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := opened.(*jsonlStore)
+
+	if err := store.Add(context.Background(), &Submission{Name: "Ann", Address: "1 Main St"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A write cut short by a full disk leaves part of a line, and the file cannot even be
+	// truncated through a handle that cannot write
+	writable := store.file
+	appendFile(t, path, `{"id":"2","name":"Bo`)
+
+	readOnly, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.file = readOnly
+
+	if err := store.Add(context.Background(), &Submission{Name: "Bob", Address: "2 Main St"}); err == nil {
+		t.Fatal("Add() through a read-only file = nil, want an error")
+	}
+
+	readOnly.Close()
+	store.file = writable
+
+	// The next submission removes what the failed write left before it is appended
+	if err := store.Add(context.Background(), &Submission{Name: "Carol", Address: "3 Main St"}); err != nil {
+		t.Fatalf("Add() after a failed write = %v", err)
+	}
+	store.Close()
+
+	reopened, err := openJSONLStore(path)
+	if err != nil {
+		t.Fatalf("openJSONLStore() after a failed write = %v", err)
+	}
+	defer reopened.Close()
+
+	if names, want := submissionNames(t, reopened), []string{"Ann", "Carol"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List() = %q, want %q", names, want)
+	}
+}
+
+// appendFile appends the content to the file
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONLStoreAddAfterLoad(t *testing.T) {
+	const ann = `{"id":"1","name":"Ann","address":"1 Main St","created_at":"2026-10-18T08:00:00Z"}`
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "crlf cut short", content: crlf(ann+"\n") + `{"id":"2","na`},
+		{name: "last line without newline", content: ann},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "submissions.jsonl")
+			if err := os.WriteFile(path, []byte(test.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err := openJSONLStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := store.Add(context.Background(), &Submission{Name: "Bob", Address: "2 Main St"}); err != nil {
+				t.Fatal(err)
+			}
+			store.Close()
+
+			// The new line starts on a line of its own
+			store, err = openJSONLStore(path)
+			if err != nil {
+				t.Fatalf("openJSONLStore() after Add = %v", err)
+			}
+			defer store.Close()
+
+			if names, want := submissionNames(t, store), []string{"Ann", "Bob"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("List() = %q, want %q", names, want)
+			}
+		})
+	}
+}
+
+func TestJSONLStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "submissions.jsonl")
+
+	// The next submission must not be appended to the line cut short
+	content := `{"id":"1","name":"Ann","address":"1 Main St","created_at":"2026-10-18T08:00:00Z"}` + "\n" + `{"id":"2","na`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := openJSONLStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added := &Submission{Name: "Bob", Address: "2 Main St"}
+	if err := store.Add(context.Background(), added); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = openJSONLStore(path)
+	if err != nil {
+		t.Fatalf("openJSONLStore() after Add = %v", err)
+	}
+	defer store.Close()
+
+	submissions, _ := store.List(context.Background())
+	if len(submissions) != 2 || submissions[0].ID != "1" || submissions[1].ID != added.ID {
+		t.Errorf("List() = %+v, want Ann and Bob", submissions)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// templateFiles are built into the binary, so the pages do not depend on the working directory
+//
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+// Names of the pages, every one is rendered inside templates/layout.html
+const (
+	pageForm    = "form.html"
+	pageSuccess = "success.html"
+	pageError   = "error.html"
+)
+
+// pages holds the parsed templates by page name
+type pages map[string]*template.Template
+
+// errorPage is the data of the error page
+type errorPage struct {
+	Status     int
+	StatusText string
+	Message    string
+	RequestID  string
+}
+
+// loadPages parses every page together with the layout
+func loadPages() (pages, error) {
+	parsed := make(pages)
+
+	for _, name := range []string{pageForm, pageSuccess, pageError} {
+		page, err := template.ParseFS(templateFiles, "templates/layout.html", "templates/"+name)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed[name] = page
+	}
+
+	return parsed, nil
+}
+
+// render writes the page with the status. The page is rendered before anything is sent,
+// so that a failing template answers 500 instead of half a page.
+func (p pages) render(w http.ResponseWriter, status int, name string, data interface{}) {
+	var page bytes.Buffer
+
+	if err := p[name].ExecuteTemplate(&page, "layout", data); err != nil {
+		log.Printf("Error could not render %s: %v", name, err)
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(page.Bytes())
+}
+
+// renderError writes the error page with the status and the message
+func (p pages) renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	p.render(w, status, pageError, errorPage{
+		Status:     status,
+		StatusText: http.StatusText(status),
+		Message:    message,
+		RequestID:  requestIDFrom(r.Context()),
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// attachmentsField is the multipart field of the files sent with a form
+const attachmentsField = "attachments"
+
+// maxAttachments bounds the number of files of a single submission
+const maxAttachments = 10
+
+// maxExtensionLength bounds the extension kept from the name of an uploaded file
+const maxExtensionLength = 10
+
+// Attachment is a file uploaded with a submission
+type Attachment struct {
+	// File is the name of the file in the upload directory, chosen by the server
+	File string `json:"file"`
+	// Name is the name of the file on the computer of the user
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// uploads saves the attached files to a directory
+type uploads struct {
+	dir string
+}
+
+// saveAll saves the files, and removes those already saved when one of them fails
+func (u *uploads) saveAll(files []*multipart.FileHeader) ([]Attachment, error) {
+	var attachments []Attachment
+
+	for _, file := range files {
+		attachment, err := u.save(file)
+		if err != nil {
+			u.removeAll(attachments)
+			return nil, err
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
+
+// save copies the file to the upload directory under a random name. The name of the user
+// is only kept as metadata, so that it cannot overwrite or escape anything.
+func (u *uploads) save(header *multipart.FileHeader) (Attachment, error) {
+	src, err := header.Open()
+	if err != nil {
+		return Attachment{}, err
+	}
+	defer src.Close()
+
+	// The content type sent by the client cannot be trusted, the first bytes tell the real one
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(src, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return Attachment{}, err
+	}
+	sniff = sniff[:n]
+
+	attachment := Attachment{
+		File:        randomID() + safeExtension(header.Filename),
+		Name:        filepath.Base(strings.ReplaceAll(header.Filename, "\\", "/")),
+		ContentType: http.DetectContentType(sniff),
+	}
+
+	dst, err := os.OpenFile(filepath.Join(u.dir, attachment.File), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	size, err := io.Copy(dst, io.MultiReader(bytes.NewReader(sniff), src))
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(filepath.Join(u.dir, attachment.File))
+		return Attachment{}, fmt.Errorf("could not save %s: %v", attachment.Name, err)
+	}
+
+	attachment.Size = size
+	return attachment, nil
+}
+
+// removeAll deletes the files of the attachments, e.g. once their submission is deleted
+func (u *uploads) removeAll(attachments []Attachment) {
+	for _, attachment := range attachments {
+		if err := os.Remove(filepath.Join(u.dir, attachment.File)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error could not remove the attachment %s: %v", attachment.File, err)
+		}
+	}
+}
+
+// safeExtension returns the extension of the name when it only has letters and digits, empty otherwise
+func safeExtension(name string) string {
+	extension := strings.ToLower(filepath.Ext(name))
+	if len(extension) < 2 || len(extension) > maxExtensionLength {
+		return ""
+	}
+
+	for _, c := range extension[1:] {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return ""
+		}
+	}
+
+	return extension
+}
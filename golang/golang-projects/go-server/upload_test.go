@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngHeader are the first bytes of a PNG file, enough to sniff its type
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// testFile is a file attached to a multipart form
+type testFile struct {
+	name    string
+	content []byte
+}
+
+// postMultipart sends the fields and the files as a multipart form with the CSRF cookie of the app
+func postMultipart(t *testing.T, a *app, fields map[string]string, files []testFile) *httptest.ResponseRecorder {
+	t.Helper()
+
+	cookie, token := fetchForm(t, a)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField(csrfField, token)
+
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+
+	for _, file := range files {
+		part, err := writer.CreateFormFile(attachmentsField, file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(file.content)
+	}
+	writer.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/form", &body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	r.Header.Set("Accept", mediaJSON)
+	r.AddCookie(cookie)
+
+	w := httptest.NewRecorder()
+	a.formHandler(w, r)
+
+	return w
+}
+
+// uploadedFiles returns the names of the files of the upload directory, the store left aside
+func uploadedFiles(t *testing.T, a *app) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(a.uploads.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Name() != "submissions.jsonl" {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names
+}
+
+func TestFormUploads(t *testing.T) {
+	a := newTestApp(t)
+
+	files := []testFile{
+		{name: "logo.PNG", content: pngHeader},
+		// The name of the user can neither escape the directory nor pick the extension
+		{name: `..\..\run.sh;.exe `, content: []byte("#!/bin/sh\necho hello\n")},
+	}
+
+	w := postMultipart(t, a, map[string]string{"name": "Ann", "address": "1 Main St"}, files)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /form = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+
+	var submission Submission
+	if err := json.Unmarshal(w.Body.Bytes(), &submission); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(submission.Attachments) != 2 {
+		t.Fatalf("attachments = %+v, want 2", submission.Attachments)
+	}
+
+	tests := []struct {
+		attachment  Attachment
+		name        string
+		extension   string
+		contentType string
+		size        int
+	}{
+		{attachment: submission.Attachments[0], name: "logo.PNG", extension: ".png", contentType: "image/png", size: len(pngHeader)},
+		{attachment: submission.Attachments[1], name: "run.sh;.exe ", extension: "", contentType: "text/plain; charset=utf-8", size: len(files[1].content)},
+	}
+
+	for _, test := range tests {
+		attachment := test.attachment
+
+		if attachment.Name != test.name || filepath.Ext(attachment.File) != test.extension || attachment.ContentType != test.contentType || attachment.Size != int64(test.size) {
+			t.Errorf("attachment = %+v, want the name %q, the extension %q, %s and %d bytes", attachment, test.name, test.extension, test.contentType, test.size)
+		}
+
+		info, err := os.Stat(filepath.Join(a.uploads.dir, attachment.File))
+		if err != nil || info.Size() != int64(test.size) {
+			t.Errorf("%s is not saved: %v", attachment.File, err)
+		}
+	}
+
+	// Deleting the submission deletes its files
+	r := httptest.NewRequest(http.MethodDelete, "/api/submissions/"+submission.ID, nil)
+	w = httptest.NewRecorder()
+	a.submissionHandler(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	if names := uploadedFiles(t, a); len(names) != 0 {
+		t.Errorf("the files %v are left after DELETE", names)
+	}
+}
+
+func TestFormUploadsRefused(t *testing.T) {
+	tooMany := make([]testFile, maxAttachments+1)
+	for i := range tooMany {
+		tooMany[i] = testFile{name: "note.txt", content: []byte("note")}
+	}
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+		files  []testFile
+		status int
+	}{
+		{name: "too many files", fields: map[string]string{"name": "Ann", "address": "1 Main St"}, files: tooMany, status: http.StatusUnprocessableEntity},
+		{name: "invalid form", fields: map[string]string{"name": "Ann"}, files: tooMany[:1], status: http.StatusUnprocessableEntity},
+		{name: "too large", fields: map[string]string{"name": "Ann", "address": "1 Main St"}, files: []testFile{{name: "big.bin", content: make([]byte, 2<<20)}}, status: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := newTestApp(t)
+
+			w := postMultipart(t, a, test.fields, test.files)
+			if w.Code != test.status {
+				t.Errorf("POST /form = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+
+			if names := uploadedFiles(t, a); len(names) != 0 {
+				t.Errorf("the refused form saved %v", names)
+			}
+		})
+	}
+}
+
+func TestFormBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		accept      string
+		body        string
+		status      int
+		// json tells if the response must be JSON rather than HTML
+		json bool
+	}{
+		// A JSON body cannot be sent cross-site without a preflight, so it needs no CSRF token
+		{name: "json", contentType: mediaJSON, body: `{"name": "Ann", "address": "1 Main St"}`, status: http.StatusCreated, json: true},
+		{name: "json asking for html", contentType: mediaJSON, accept: "text/html", body: `{"name": "Ann", "address": "1 Main St"}`, status: http.StatusCreated},
+		{name: "invalid json", contentType: mediaJSON, body: `{"name": "Ann"`, status: http.StatusBadRequest, json: true},
+		{name: "unknown json field", contentType: mediaJSON, body: `{"name": "Ann", "address": "1 Main St", "admin": true}`, status: http.StatusBadRequest, json: true},
+		{name: "invalid json submission", contentType: mediaJSON, body: `{"name": "Ann"}`, status: http.StatusUnprocessableEntity, json: true},
+		{name: "json too large", contentType: mediaJSON, body: `{"name": "` + strings.Repeat("a", maxFormBody) + `"}`, status: http.StatusRequestEntityTooLarge, json: true},
+		{name: "urlencoded too large", contentType: mediaForm, body: "name=" + strings.Repeat("a", maxFormBody), status: http.StatusRequestEntityTooLarge},
+		{name: "unsupported", contentType: "text/plain", body: "Ann, 1 Main St", status: http.StatusUnsupportedMediaType},
+		{name: "unsupported asking for json", contentType: "text/plain", accept: mediaJSON, body: "Ann, 1 Main St", status: http.StatusUnsupportedMediaType, json: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := newTestApp(t)
+
+			r := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader(test.body))
+			r.Header.Set("Content-Type", test.contentType)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+
+			w := httptest.NewRecorder()
+			a.formHandler(w, r)
+
+			if w.Code != test.status {
+				t.Errorf("POST /form = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+
+			if json := strings.HasPrefix(w.Header().Get("Content-Type"), mediaJSON); json != test.json {
+				t.Errorf("POST /form answers %s, want JSON %v", w.Header().Get("Content-Type"), test.json)
+			}
+		})
+	}
+}
+
+func TestSafeExtension(t *testing.T) {
+	tests := []struct {
+		name      string
+		extension string
+	}{
+		{name: "photo.JPG", extension: ".jpg"},
+		{name: "archive.tar.gz", extension: ".gz"},
+		{name: "notes", extension: ""},
+		{name: "notes.", extension: ""},
+		{name: "page.ht-ml", extension: ""},
+		{name: "run.sh;.exe ", extension: ""},
+		{name: "data.abcdefghijk", extension: ""},
+		{name: "data.abcdefghi", extension: ".abcdefghi"},
+	}
+
+	for _, test := range tests {
+		if extension := safeExtension(test.name); extension != test.extension {
+			t.Errorf("safeExtension(%q) = %q, want %q", test.name, extension, test.extension)
+		}
+	}
+}